@@ -0,0 +1,107 @@
+package run
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/unpack"
+)
+
+type cacheFlags struct {
+	CacheDir string `prog:"cache-dir '' where host dirs of non-vendor targets are cached, default to the user cache dir" env:"GO_PACK_CACHE_DIR" complete:"dir"`
+	MaxSize  string `prog:"max-size '' max size of the cache, e.g. 512M, 2G, default 1G" env:"GO_PACK_CACHE_MAX_SIZE"`
+}
+
+var cacheArgs cacheFlags
+
+func cacheCmd(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "requires one of: ls,gc,clean\n")
+		os.Exit(1)
+	}
+	cacheDir := cacheArgs.CacheDir
+	if cacheDir == "" {
+		var err error
+		cacheDir, err = unpack.DefaultCacheDir()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+	switch args[0] {
+	case "ls":
+		entries, err := unpack.ListCache(cacheDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		var total int64
+		for _, entry := range entries {
+			total += entry.Size
+			fmt.Printf("%s go%s %s %s %s\n", entry.Key, entry.GoVersion, formatSize(entry.Size), entry.LastUsed.Format("2006-01-02 15:04:05"), entry.Digest)
+		}
+		fmt.Printf("total %d entries, %s in %s\n", len(entries), formatSize(total), cacheDir)
+	case "gc":
+		maxSize, err := parseSize(cacheArgs.MaxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "-max-size: %v\n", err)
+			os.Exit(1)
+		}
+		removed, err := unpack.GCCache(cacheDir, maxSize)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+		for _, entry := range removed {
+			fmt.Printf("removed %s %s\n", entry.Key, formatSize(entry.Size))
+		}
+	case "clean":
+		err := unpack.CleanCache(cacheDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown cache cmd: %s, requires one of: ls,gc,clean\n", args[0])
+		os.Exit(1)
+	}
+}
+
+var sizeUnits = []string{"B", "K", "M", "G", "T"}
+
+// parseSize parses sizes like 1024, 512K, 2G, empty means 0
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(s)), "B")
+	if s == "" {
+		return 0, nil
+	}
+	var shift uint
+	for i := len(sizeUnits) - 1; i > 0; i-- {
+		if strings.HasSuffix(s, sizeUnits[i]) {
+			s = strings.TrimSuffix(s, sizeUnits[i])
+			shift = uint(i) * 10
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size: %s", s)
+	}
+	return n << shift, nil
+}
+
+func formatSize(size int64) string {
+	i := 0
+	f := float64(size)
+	for f >= 1024 && i < len(sizeUnits)-1 {
+		f /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", size)
+	}
+	return fmt.Sprintf("%.1f%s", f, sizeUnits[i])
+}
@@ -0,0 +1,81 @@
+package run
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/tar"
+	"github.com/xhd2015/go-vendor-pack/unpack"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+type extractFlags struct {
+	InputDataFile   string   `prog:"input-data-file '' pack as base64 data file, raw archive or generated go file, - for stdin" required:"true" complete:"file"`
+	Overwrite       string   `prog:"overwrite '' when a file exists: overwrite, skip-existing, error-on-existing" values:"overwrite,skip-existing,error-on-existing"`
+	StripComponents int      `prog:"strip-components '' remove this many leading path elements"`
+	Include         []string `prog:"include '' only extract matching paths, e.g. vendor/golang.org/*"`
+}
+
+var extractArgs extractFlags
+
+func extractCmd(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
+	if len(args) == 0 || args[0] == "" {
+		fmt.Fprintf(os.Stderr, "requires dir\n")
+		os.Exit(1)
+	}
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "requires only 1 dir\n")
+		os.Exit(1)
+	}
+	dir := args[0]
+	policy, err := tar.ParseOverwritePolicy(extractArgs.Overwrite)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	if extractArgs.StripComponents < 0 {
+		fmt.Fprintf(os.Stderr, "invalid strip-components: %d\n", extractArgs.StripComponents)
+		os.Exit(1)
+	}
+	inputData, err := readInputFile(extractArgs.InputDataFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	opts := &tar.UntarOptions{
+		Policy:          policy,
+		StripComponents: extractArgs.StripComponents,
+	}
+	if len(extractArgs.Include) > 0 {
+		opts.Include = includeMatcher(extractArgs.Include)
+	}
+	raw, err := unpack.DecodePackData(inputData)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	err = tar.UntarFS(bytes.NewReader(raw), writefs.SysFS{}, dir, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+}
+
+// includeMatcher matches a name if itself or any
+// of its parent dirs matches one of the patterns
+func includeMatcher(patterns []string) func(name string, dir bool) bool {
+	return func(name string, dir bool) bool {
+		for p := name; p != "." && p != "/"; p = path.Dir(p) {
+			for _, pattern := range patterns {
+				if ok, _ := path.Match(strings.TrimSuffix(pattern, "/"), p); ok {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
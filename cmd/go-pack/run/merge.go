@@ -0,0 +1,137 @@
+package run
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/pack"
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/unpack"
+)
+
+type mergeFlags struct {
+	Output        string `prog:"o '' output file" required:"true" complete:"file"`
+	UnionStrategy string `prog:"union-strategy '' when packs have different versions of a module: highest, first, fail" values:"highest,first,fail"`
+}
+
+var mergeArgs mergeFlags
+
+type splitFlags struct {
+	OutputDir string            `prog:"output-dir '' output dir" required:"true" complete:"dir"`
+	Group     map[string]string `prog:"group '' split modules into one pack, e.g. NAME=MODULE1,MODULE2"`
+}
+
+var splitArgs splitFlags
+
+func mergeCmd(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "requires at least 2 packs\n")
+		os.Exit(1)
+	}
+	strategy, err := packfs.ParseUnionStrategy(mergeArgs.UnionStrategy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	packs := make([]packfs.FS, 0, len(args))
+	for _, file := range args {
+		fs, err := readPackFile(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(1)
+		}
+		packs = append(packs, fs)
+	}
+	data, conflicts, err := pack.Merge(packs, &pack.MergeOptions{Strategy: strategy})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	for _, conflict := range conflicts {
+		fmt.Fprintf(os.Stderr, "conflict %s\n", conflict.String())
+	}
+	err = ioutil.WriteFile(mergeArgs.Output, data, 0755)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+}
+
+func splitCmd(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "requires 1 pack\n")
+		os.Exit(1)
+	}
+	groups, err := parseGroups(splitArgs.Group)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	fs, err := readPackFile(args[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	packs, err := pack.Split(fs, &pack.SplitOptions{Groups: groups})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	err = os.MkdirAll(splitArgs.OutputDir, 0755)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	names := make([]string, 0, len(packs))
+	for name := range packs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		file := filepath.Join(splitArgs.OutputDir, strings.ReplaceAll(name, "/", "_")+".data")
+		err := ioutil.WriteFile(file, packs[name], 0755)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(1)
+		}
+		fmt.Println(file)
+	}
+}
+
+// parseGroups parses NAME=MODULE1,MODULE2
+func parseGroups(m map[string]string) (map[string][]string, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	groups := make(map[string][]string, len(m))
+	for name, mods := range m {
+		list := commaList(mods)
+		if len(list) == 0 {
+			return nil, fmt.Errorf("invalid group: %s, expecting NAME=MODULE1,MODULE2", name)
+		}
+		groups[name] = list
+	}
+	return groups, nil
+}
+
+// readPackFile reads a pack in any form accepted by unpack.DecodePackData
+func readPackFile(file string) (packfs.FS, error) {
+	data, err := readInputFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return unpack.NewTarFSFromData(data)
+}
+
+// readInputFile reads file, - means stdin
+func readInputFile(file string) ([]byte, error) {
+	if file == "-" {
+		return ioutil.ReadAll(os.Stdin)
+	}
+	return ioutil.ReadFile(file)
+}
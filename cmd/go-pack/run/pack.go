@@ -4,63 +4,171 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/xhd2015/go-vendor-pack/pack"
 	"github.com/xhd2015/go-vendor-pack/prog"
+	"github.com/xhd2015/go-vendor-pack/unpack"
 )
 
-// example:
-//   $ pack
-//   $ unpack
-
-type Prog struct {
-
-	// dir string, pkg string, varName string, dstFile string
-
-	Dir                       string `prog:"dir '' working directory"`
-	Pkg                       string `prog:"pkg '' package name"`
-	Var                       string `prog:"var '' var name"`
-	Output                    string `prog:"o '' output file"`
-	OutputDataFile            string `prog:"output-data-file '' output data file"`
-	RunGoModTidy              bool   `prog:"run-go-mod-tidy false run go mod tidy before pack"`
-	RunGoModVendor            bool   `prog:"run-go-mod-vendor false run go mod vendor before pack"`
-	ModuleWhitelist           string `prog:"module-whitelist '' module whitelist,separated by comma"`
-	RemoveNonWhitelistVendors bool   `prog:"rm-non-whitelist-vendors false remove non-whitelist vendors"`
-
-	// for unpack
-	InputDataFile      string `prog:"input-data-file '' input data file"`
-	UnpackIgnoreSums   bool   `prog:"unpack-ignore-sums false ignore sums when unpack(deprecated,use -ignore-updating-sums instead)"`
-	IgnoreUpdatingSums bool   `prog:"ignore-updating-sums false ignore sums when unpack"`
-	OptionalSumModules string `prog:"optional-sum-modules '' a list of modules whose sum will be ignored"`
+type packFlags struct {
+	Pkg                       string   `prog:"pkg '' package name"`
+	Var                       string   `prog:"var '' var name"`
+	Output                    string   `prog:"o '' output file" complete:"file"`
+	OutputDataFile            string   `prog:"output-data-file '' output data file" complete:"file"`
+	RunGoModTidy              bool     `prog:"run-go-mod-tidy false run go mod tidy before pack"`
+	RunGoModVendor            bool     `prog:"run-go-mod-vendor false run go mod vendor before pack"`
+	ModuleWhitelist           string   `prog:"module-whitelist '' module whitelist,separated by comma"`
+	RemoveNonWhitelistVendors bool     `prog:"rm-non-whitelist-vendors false remove non-whitelist vendors"`
+	RejectEscapingSymlinks    bool     `prog:"reject-escaping-symlinks false fail if a symlink points outside of its module"`
+	Base                      string   `prog:"base '' base pack data file, emit a delta relative to it" complete:"file"`
+	GenAccessors              bool     `prog:"gen-accessors false generate FS, GoList, Unpack, Digest and PackTimeUTC next to the var"`
+	Config                    string   `prog:"config '' config file, default to go-pack.json or go-pack.yaml in the dir" complete:"file"`
+	Profile                   string   `prog:"profile '' profiles in the config to pack, separated by comma, default all"`
+	Exclude                   []string `prog:"exclude '' exclude matching paths from the pack, e.g. vendor/**/testdata or *_test.go"`
+	Platform                  []string `prog:"platform '' restrict the generated code to platforms, e.g. linux/amd64"`
+	CompressionLevel          int      `prog:"compression-level '' gzip level, 1(fastest) to 9(best)" values:"1,2,3,4,5,6,7,8,9"`
 }
 
-var progArgs Prog
+var packArgs packFlags
 
-var commands = map[string]func(comm string, args []string, extraArgs []string){
-	"help":     help,
-	"version":  version,
-	"pack":     packCmd,
-	"unpack":   unpackCmd,
-	"show-env": showEnv,
+var commands = []*prog.Command{
+	{
+		Name:  "pack",
+		Args:  "DIR",
+		Short: "pack modules vendored in DIR into a go file",
+		Long: `pack modules vendored in DIR into a go file.
+flags override go-pack.json or go-pack.yaml in DIR, every profile
+in the config is packed unless -profile is given.`,
+		Examples: []string{
+			"go-pack pack -pkg mypkg -var packData -o mypkg/pack.go ./src",
+			"go-pack pack -profile linux ./src",
+			"go-pack pack -base old.data -output-data-file new.data -pkg mypkg -var packData -o mypkg/pack.go ./src",
+		},
+		Flags:       &packArgs,
+		ArgComplete: "dir",
+		Run:         packCmd,
+	},
+	{
+		Name:  "unpack",
+		Args:  "DIR",
+		Short: "unpack a pack into the module in DIR",
+		Examples: []string{
+			"go-pack unpack -input-data-file pack.data ./target",
+			"go-pack unpack -input-data-file pack.data -dry-run ./target",
+			"go-pack unpack -input-data-file mypkg/pack.go -use-go-work ./target",
+			"go-pack unpack -input-data-file - -force-upgrade-modules golang.org/x/mod ./target < pack.data",
+		},
+		Flags:       &unpackArgs,
+		ArgComplete: "dir",
+		Run:         unpackCmd,
+	},
+	{
+		Name:        "uninstall",
+		Args:        "DIR",
+		Short:       "revert a previous unpack of DIR",
+		Flags:       &uninstallArgs,
+		ArgComplete: "dir",
+		Run:         uninstallCmd,
+	},
+	{
+		Name:  "cache",
+		Args:  "ls|gc|clean",
+		Short: "manage host dirs cached for non-vendor targets",
+		Examples: []string{
+			"go-pack cache ls",
+			"go-pack cache -max-size 2G gc",
+		},
+		Flags:     &cacheArgs,
+		ArgValues: []string{"ls", "gc", "clean"},
+		Run:       cacheCmd,
+	},
+	{
+		Name:  "extract",
+		Args:  "DIR",
+		Short: "extract the pack as a plain directory",
+		Examples: []string{
+			"go-pack extract -input-data-file pack.data -include 'vendor/golang.org/*' ./out",
+		},
+		Flags:       &extractArgs,
+		ArgComplete: "dir",
+		Run:         extractCmd,
+	},
+	{
+		Name:  "merge",
+		Args:  "PACK1 PACK2...",
+		Short: "merge packs into one, modules found in several packs are resolved by strategy",
+		Examples: []string{
+			"go-pack merge -o all.data -union-strategy highest a.data b.data",
+		},
+		Flags:       &mergeArgs,
+		ArgComplete: "file",
+		Run:         mergeCmd,
+	},
+	{
+		Name:  "split",
+		Args:  "PACK",
+		Short: "split a pack into one pack per module or group",
+		Examples: []string{
+			"go-pack split -output-dir out -group x=golang.org/x/mod,golang.org/x/tools all.data",
+		},
+		Flags:       &splitArgs,
+		ArgComplete: "file",
+		Run:         splitCmd,
+	},
+	{
+		Name:  "version",
+		Short: "print version",
+		Run:   version,
+	},
+	{
+		Name:  "show-env",
+		Short: "print environment variables",
+		Run:   showEnv,
+	},
 }
 
 func Main() {
-	prog.Run(&progArgs, &prog.RunOptions{
-		Usage: usage,
-		AfterFlagParse: func() {
+	prog.Run(nil, &prog.RunOptions{
+		Name: "go-pack",
+		Cmds: commands,
+		Examples: []string{
+			"go-pack pack -pkg mypkg -var packData -o mypkg/pack.go ./src",
+			"go-pack unpack -input-data-file pack.data ./target",
+		},
+		Default: defaultCommand,
+		Completers: map[string]prog.Completer{
+			"module": completeModules,
 		},
-		Commands: commands,
-		Default:  defaultCommand,
 	})
 }
 
-func help(commd string, args []string, extraArgs []string) {
-	flag.Usage()
-	os.Exit(0)
+// completeModules lists modules in the pack given by -input-data-file
+func completeModules(ctx *prog.CompleteContext) []string {
+	file := ctx.Flags["input-data-file"]
+	if file == "" {
+		return nil
+	}
+	fs, err := readPackFile(file)
+	if err != nil {
+		return nil
+	}
+	goList, err := unpack.ReadGoList(fs)
+	if err != nil {
+		return nil
+	}
+	var mods []string
+	for _, mod := range goList.Modules {
+		if mod.ModulePublic != nil && !mod.Main {
+			mods = append(mods, mod.Path)
+		}
+	}
+	sort.Strings(mods)
+	return mods
 }
 
-func packCmd(commd string, args []string, extraArgs []string) {
+func packCmd(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
 	if len(args) == 0 {
 		fmt.Fprintf(os.Stderr, "requires dir\n")
 		os.Exit(1)
@@ -70,31 +178,114 @@ func packCmd(commd string, args []string, extraArgs []string) {
 		os.Exit(1)
 	}
 	dir := args[0]
-	if progArgs.Pkg == "" {
-		fmt.Fprintf(os.Stderr, "requires pkg\n")
-		os.Exit(1)
-	}
-	if progArgs.Var == "" {
-		fmt.Fprintf(os.Stderr, "requires var\n")
+	configs, err := packConfigs(flagSet, dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
-	if progArgs.Output == "" {
-		fmt.Fprintf(os.Stderr, "requires output\n")
-		os.Exit(1)
+	for _, config := range configs {
+		if config.Pkg == "" {
+			fmt.Fprintf(os.Stderr, "requires pkg\n")
+			os.Exit(1)
+		}
+		if config.Var == "" {
+			fmt.Fprintf(os.Stderr, "requires var\n")
+			os.Exit(1)
+		}
+		if config.Output == "" {
+			fmt.Fprintf(os.Stderr, "requires output\n")
+			os.Exit(1)
+		}
 	}
-	err := pack.PackAsBase64ToCode(dir, progArgs.Pkg, progArgs.Var, progArgs.Output, &pack.Options{
-		OutputDataFile:            progArgs.OutputDataFile,
-		RunGoModTidy:              progArgs.RunGoModTidy,
-		RunGoModVendor:            progArgs.RunGoModVendor,
-		ModuleWhitelist:           commaListToMap(progArgs.ModuleWhitelist),
-		RemoveNonWhitelistVendors: progArgs.RemoveNonWhitelistVendors,
-	})
+	err = pack.PackConfigs(dir, configs)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v", err)
 		os.Exit(1)
 	}
 }
 
+// packConfigs reads go-pack.json or go-pack.yaml, selects profiles
+// and applies flags set on the command line over them
+func packConfigs(flagSet *flag.FlagSet, dir string) ([]*pack.Config, error) {
+	var config *pack.Config
+	var err error
+	if packArgs.Config != "" {
+		config, err = pack.LoadConfigFile(packArgs.Config)
+	} else {
+		config, err = pack.LoadConfig(dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		config = &pack.Config{}
+	}
+	names := []string{""}
+	if packArgs.Profile != "" {
+		names = strings.Split(packArgs.Profile, ",")
+	} else if len(config.Profiles) > 0 {
+		names = config.ProfileNames()
+	}
+	setFlags := make(map[string]bool)
+	flagSet.Visit(func(f *flag.Flag) {
+		setFlags[f.Name] = true
+	})
+	if len(names) > 1 && (setFlags["o"] || setFlags["output-data-file"]) {
+		return nil, fmt.Errorf("-o and -output-data-file cannot be used with multiple profiles: %s", strings.Join(names, ","))
+	}
+	configs := make([]*pack.Config, 0, len(names))
+	for _, name := range names {
+		c, err := config.Profile(strings.TrimSpace(name))
+		if err != nil {
+			return nil, err
+		}
+		for flagName := range setFlags {
+			switch flagName {
+			case "pkg":
+				c.Pkg = packArgs.Pkg
+			case "var":
+				c.Var = packArgs.Var
+			case "o":
+				c.Output = packArgs.Output
+			case "output-data-file":
+				c.OutputDataFile = packArgs.OutputDataFile
+			case "base":
+				c.Base = packArgs.Base
+			case "run-go-mod-tidy":
+				c.RunGoModTidy = &packArgs.RunGoModTidy
+			case "run-go-mod-vendor":
+				c.RunGoModVendor = &packArgs.RunGoModVendor
+			case "module-whitelist":
+				c.ModuleWhitelist = commaList(packArgs.ModuleWhitelist)
+			case "rm-non-whitelist-vendors":
+				c.RemoveNonWhitelistVendors = &packArgs.RemoveNonWhitelistVendors
+			case "reject-escaping-symlinks":
+				c.RejectEscapingSymlinks = &packArgs.RejectEscapingSymlinks
+			case "gen-accessors":
+				c.GenAccessors = &packArgs.GenAccessors
+			case "exclude":
+				c.Excludes = packArgs.Exclude
+			case "platform":
+				c.Platforms = packArgs.Platform
+			case "compression-level":
+				c.CompressionLevel = &packArgs.CompressionLevel
+			}
+		}
+		configs = append(configs, c)
+	}
+	return configs, nil
+}
+
+func commaList(s string) []string {
+	mapping := commaListToMap(s)
+	list := make([]string, 0, len(mapping))
+	for e := range mapping {
+		list = append(list, e)
+	}
+	sort.Strings(list)
+	return list
+}
+
 func commaListToMap(s string) map[string]bool {
 	if s == "" {
 		return nil
@@ -110,10 +301,10 @@ func commaListToMap(s string) map[string]bool {
 	return mapping
 }
 
-func showEnv(commd string, args []string, extraArgs []string) {
+func showEnv(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
 	fmt.Println(strings.Join(os.Environ(), "\n"))
 }
-func version(commd string, args []string, extraArgs []string) {
+func version(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
 	fmt.Println("v1.0.0")
 }
 
@@ -126,20 +317,3 @@ func defaultCommand(commd string, args []string, extraArgs []string) {
 	flag.Usage()
 	os.Exit(1)
 }
-
-func usage(defaultUsage func()) func() {
-	return func() {
-		fmt.Sprintf(strings.Join([]string{
-			"supported commands: pack,unpack\n",
-			"    pack DIR -dst X\n",
-			"        build the package with generated mock stubs,default output is exec.bin or debug.bin if -debug\n",
-			"    unpack DIR[--] [EXEC_ARGS]\n",
-			"    help\n",
-			"        show help message\n",
-		}, "\n"))
-		defaultUsage()
-		fmt.Sprintf(strings.Join([]string{
-			"examples:\n",
-		}, "\n"))
-	}
-}
@@ -0,0 +1,40 @@
+package run
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/xhd2015/go-vendor-pack/unpack"
+)
+
+type uninstallFlags struct {
+	NonVendorHostDir string `prog:"non-vendor-host-dir '' host dir used by unpack for non-vendor targets" complete:"dir"`
+	CacheDir         string `prog:"cache-dir '' cache dir used by unpack" env:"GO_PACK_CACHE_DIR" complete:"dir"`
+}
+
+var uninstallArgs uninstallFlags
+
+func uninstallCmd(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
+	if len(args) == 0 || args[0] == "" {
+		fmt.Fprintf(os.Stderr, "requires dir\n")
+		os.Exit(1)
+	}
+	if len(args) > 1 {
+		fmt.Fprintf(os.Stderr, "requires only 1 dir\n")
+		os.Exit(1)
+	}
+	dir := args[0]
+	opts := &unpack.UninstallOptions{
+		NonVendorHostDir: uninstallArgs.NonVendorHostDir,
+		CacheDir:         uninstallArgs.CacheDir,
+	}
+	res, err := unpack.Uninstall(dir, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	for _, mod := range res.Modules {
+		fmt.Printf("uninstalled %s\n", mod)
+	}
+}
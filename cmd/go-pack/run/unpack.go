@@ -1,14 +1,45 @@
 package run
 
 import (
+	"flag"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"time"
 
+	"github.com/xhd2015/go-vendor-pack/packfs"
 	"github.com/xhd2015/go-vendor-pack/unpack"
 )
 
-func unpackCmd(commd string, args []string, extraArgs []string) {
+type unpackFlags struct {
+	InputDataFile      string `prog:"input-data-file '' pack as base64 data file, raw archive or generated go file, - for stdin" required:"true" complete:"file"`
+	Base               string `prog:"base '' base pack data file, the input is a delta applied on it" complete:"file"`
+	UnpackIgnoreSums   bool   `prog:"unpack-ignore-sums false ignore sums when unpack(deprecated,use -ignore-updating-sums instead)"`
+	IgnoreUpdatingSums bool   `prog:"ignore-updating-sums false ignore sums when unpack"`
+	OptionalSumModules string `prog:"optional-sum-modules '' a list of modules whose sum will be ignored" complete:"module"`
+	DryRun             bool   `prog:"dry-run false print what unpack would change without touching disk"`
+	ConflictPolicy     string `prog:"conflict-policy '' when target requires a different version: keep-target, take-pack, take-newer, fail-on-downgrade, fail-on-any-mismatch" values:"keep-target,take-pack,take-newer,fail-on-downgrade,fail-on-any-mismatch"`
+	OverlayDir         string `prog:"overlay-dir '' unpack into this scratch dir and print a file for go build -overlay, the target is not modified" complete:"dir"`
+
+	NonVendorHostDir       string            `prog:"non-vendor-host-dir '' where to put modules when the target is not vendor-style, default to a host dir in the cache" complete:"dir"`
+	ForceUpgradeAllModules bool              `prog:"force-upgrade-all-modules false upgrade all modules even if the target has them"`
+	ForceUpgradeModules    string            `prog:"force-upgrade-modules '' modules to upgrade even if the target has them, separated by comma" complete:"module"`
+	ForceUpgradeModulePkgs map[string]string `prog:"force-upgrade-module-pkgs '' packages of a module to upgrade, relative to the module, e.g. MODULE=PKG1,PKG2"`
+	ModuleConflictPolicy   map[string]string `prog:"module-conflict-policy '' conflict policy of a module, overrides -conflict-policy, e.g. MODULE=take-pack"`
+	UseModCache            bool              `prog:"use-mod-cache false install modules into the module cache, build the target with -mod=mod"`
+	ModCacheDir            string            `prog:"mod-cache-dir '' module cache dir, default to go env GOMODCACHE" complete:"dir"`
+	UseGoWork              bool              `prog:"use-go-work false add modules as use directives of a go.work, go.mod and go.sum of the target are not touched"`
+	GoWorkFile             string            `prog:"go-work-file '' the go.work to create or merge, default to go.work in the host dir, the target is not touched" complete:"file"`
+	GoVersion              string            `prog:"go-version '' go version written to generated go.mod and go.work, default to go version"`
+	LockTimeout            time.Duration     `prog:"lock-timeout '' how long to wait for other unpacks of the same target, negative disables locking"`
+
+	CacheDir      string `prog:"cache-dir '' where host dirs of non-vendor targets are cached, default to the user cache dir" env:"GO_PACK_CACHE_DIR" complete:"dir"`
+	NoCache       bool   `prog:"no-cache false use a temp host dir for non-vendor targets instead of the cache"`
+	StableModTime bool   `prog:"stable-mod-time false set mtime of unpacked files to the time in the pack"`
+}
+
+var unpackArgs unpackFlags
+
+func unpackCmd(commd string, flagSet *flag.FlagSet, args []string, extraArgs []string) {
 	if len(args) == 0 || args[0] == "" {
 		fmt.Fprintf(os.Stderr, "requires dir\n")
 		os.Exit(1)
@@ -18,23 +49,115 @@ func unpackCmd(commd string, args []string, extraArgs []string) {
 		os.Exit(1)
 	}
 	dir := args[0]
-	inputFile := progArgs.InputDataFile
-	if inputFile == "" {
-		fmt.Fprintf(os.Stderr, "requires -input-data-file")
+	inputFile := unpackArgs.InputDataFile
+	conflictPolicy, err := unpack.ParseConflictPolicy(unpackArgs.ConflictPolicy)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
 		os.Exit(1)
-
 	}
-	inputData, err := ioutil.ReadFile(inputFile)
+	moduleConflictPolicies, err := parseModuleConflictPolicies(unpackArgs.ModuleConflictPolicy)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v", err)
 		os.Exit(1)
 	}
-	err = unpack.UnpackFromBase64Decode(string(inputData), dir, &unpack.Options{
-		IgnoreUpdatingSums: progArgs.UnpackIgnoreSums,
-		OptionalSumModules: commaListToMap(progArgs.OptionalSumModules),
-	})
+	opts := &unpack.Options{
+		NonVendorHostDir:       unpackArgs.NonVendorHostDir,
+		ForceUpgradeAllModules: unpackArgs.ForceUpgradeAllModules,
+		ForceUpgradeModules:    commaListToMap(unpackArgs.ForceUpgradeModules),
+		ForceUpgradeModulePkgs: parseModulePkgs(unpackArgs.ForceUpgradeModulePkgs),
+		IgnoreSums:             unpackArgs.UnpackIgnoreSums,
+		IgnoreUpdatingSums:     unpackArgs.IgnoreUpdatingSums,
+		OptionalSumModules:     commaListToMap(unpackArgs.OptionalSumModules),
+		UseModCache:            unpackArgs.UseModCache,
+		ModCacheDir:            unpackArgs.ModCacheDir,
+		UseGoWork:              unpackArgs.UseGoWork,
+		GoWorkFile:             unpackArgs.GoWorkFile,
+		GoVersion:              unpackArgs.GoVersion,
+		ConflictPolicy:         conflictPolicy,
+		ModuleConflictPolicies: moduleConflictPolicies,
+		StableModTime:          unpackArgs.StableModTime,
+		CacheDir:               unpackArgs.CacheDir,
+		NoCache:                unpackArgs.NoCache,
+		LockTimeout:            unpackArgs.LockTimeout,
+	}
+	fs, err := readPackFile(inputFile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "%v", err)
 		os.Exit(1)
 	}
+	if unpackArgs.Base != "" {
+		base, err := readPackFile(unpackArgs.Base)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(1)
+		}
+		fs, err = packfs.ApplyDelta(base, fs)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(1)
+		}
+	} else if packfs.IsDelta(fs) {
+		fmt.Fprintf(os.Stderr, "%s is a delta pack, requires -base\n", inputFile)
+		os.Exit(1)
+	}
+	if unpackArgs.DryRun {
+		plan, err := unpack.Plan(fs, dir, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(1)
+		}
+		fmt.Print(plan.String())
+		return
+	}
+	if unpackArgs.OverlayDir != "" {
+		res, err := unpack.UnpackOverlay(fs, dir, unpackArgs.OverlayDir, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v", err)
+			os.Exit(1)
+		}
+		for _, env := range res.Env {
+			fmt.Printf("export %s\n", env)
+		}
+		fmt.Printf("go build -overlay=%s\n", res.OverlayFile)
+		return
+	}
+	res, err := unpack.Unpack(fs, dir, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v", err)
+		os.Exit(1)
+	}
+	for _, decision := range unpack.Downgrades(res.Decisions) {
+		fmt.Fprintf(os.Stderr, "warning: %s downgraded from %s to %s, use -conflict-policy to keep or refuse\n", decision.Path, decision.TargetVersion, decision.PackVersion)
+	}
+	if !res.Changed() {
+		fmt.Printf("%s is up to date\n", dir)
+	}
+}
+
+// parseModulePkgs parses MODULE=PKG1,PKG2
+func parseModulePkgs(m map[string]string) map[string]map[string]bool {
+	if len(m) == 0 {
+		return nil
+	}
+	res := make(map[string]map[string]bool, len(m))
+	for mod, pkgs := range m {
+		res[mod] = commaListToMap(pkgs)
+	}
+	return res
+}
+
+// parseModuleConflictPolicies parses MODULE=POLICY
+func parseModuleConflictPolicies(m map[string]string) (map[string]unpack.ConflictPolicy, error) {
+	if len(m) == 0 {
+		return nil, nil
+	}
+	res := make(map[string]unpack.ConflictPolicy, len(m))
+	for mod, s := range m {
+		policy, err := unpack.ParseConflictPolicy(s)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", mod, err)
+		}
+		res[mod] = policy
+	}
+	return res, nil
 }
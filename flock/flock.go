@@ -0,0 +1,84 @@
+// Package flock provides advisory file locks shared across processes,
+// flock(2) is used on unix, other platforms fall back to an exclusively
+// created file, which is left behind if the holder crashes.
+package flock
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const maxRetryInterval = 100 * time.Millisecond
+
+// File is a held lock
+type File struct {
+	name string
+	f    *os.File
+}
+
+// TimeoutError is returned when the lock is still held by another process after timeout
+type TimeoutError struct {
+	File    string
+	Timeout time.Duration
+	Holder  string // pid of the holder, if known
+}
+
+func (c *TimeoutError) Error() string {
+	msg := fmt.Sprintf("timeout after %v waiting for lock %s", c.Timeout, c.File)
+	if c.Holder != "" {
+		msg += ", held by pid " + c.Holder
+	}
+	return msg
+}
+
+func IsTimeout(err error) bool {
+	var timeoutErr *TimeoutError
+	return errors.As(err, &timeoutErr)
+}
+
+// Lock acquires an exclusive lock on file, creating it and its dir if missing.
+// The file is writable by every user, so processes of different users exclude each other.
+// It waits until the lock is released by other holders, timeout <= 0 means wait forever.
+func Lock(file string, timeout time.Duration) (*File, error) {
+	err := os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return nil, err
+	}
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	interval := time.Millisecond
+	for {
+		f, ok, err := lockFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("lock %s: %w", file, err)
+		}
+		if ok {
+			// record the holder, for error messages of waiters
+			if f.Truncate(0) == nil {
+				f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+			}
+			return &File{name: file, f: f}, nil
+		}
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			holder, _ := ioutil.ReadFile(file)
+			return nil, &TimeoutError{File: file, Timeout: timeout, Holder: strings.TrimSpace(string(holder))}
+		}
+		time.Sleep(interval)
+		if interval < maxRetryInterval {
+			interval *= 2
+		}
+	}
+}
+
+// Unlock releases the lock, the lock file is kept
+func (c *File) Unlock() error {
+	return unlockFile(c.name, c.f)
+}
@@ -0,0 +1,27 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package flock
+
+import (
+	"os"
+)
+
+func lockFile(name string) (*os.File, bool, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0666)
+	if err != nil {
+		if os.IsExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return f, true, nil
+}
+
+func unlockFile(name string, f *os.File) error {
+	err := f.Close()
+	if err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,68 @@
+package flock
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// go test -run TestLockTimeout -v ./flock
+func TestLockTimeout(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "locks", "a.lock")
+	l, err := Lock(file, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Lock(file, 50*time.Millisecond)
+	if !IsTimeout(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "timeout", true, err)
+	}
+	if err.(*TimeoutError).Holder == "" {
+		t.Fatalf("expect holder pid in error, actual:%v", err)
+	}
+	err = l.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err = Lock(file, 50*time.Millisecond)
+	if err != nil {
+		t.Fatalf("expect lock after unlock, actual:%v", err)
+	}
+	l.Unlock()
+}
+
+// go test -run TestLockExclusive -v ./flock
+func TestLockExclusive(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.lock")
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	holders := 0
+	maxHolders := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l, err := Lock(file, 10*time.Second)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			mutex.Lock()
+			holders++
+			if holders > maxHolders {
+				maxHolders = holders
+			}
+			mutex.Unlock()
+			time.Sleep(5 * time.Millisecond)
+			mutex.Lock()
+			holders--
+			mutex.Unlock()
+			l.Unlock()
+		}()
+	}
+	wg.Wait()
+	if maxHolders != 1 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "maxHolders", 1, maxHolders)
+	}
+}
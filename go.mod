@@ -4,5 +4,7 @@ go 1.14
 
 require (
 	github.com/xhd2015/go-inspect v0.0.52
+	golang.org/x/mod v0.10.0
 	golang.org/x/tools v0.8.0 // indirect
-)
\ No newline at end of file
+	gopkg.in/yaml.v3 v3.0.1
+)
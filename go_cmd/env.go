@@ -0,0 +1,22 @@
+package go_cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// GoEnv returns the value of `go env KEY`
+func GoEnv(key string) (string, error) {
+	var buf bytes.Buffer
+	var errBuf bytes.Buffer
+	cmd := exec.Command("go", "env", key)
+	cmd.Stdout = &buf
+	cmd.Stderr = &errBuf
+	err := cmd.Run()
+	if err != nil {
+		return "", fmt.Errorf("go env %s: %w %s", key, err, errBuf.Bytes())
+	}
+	return strings.TrimSpace(buf.String()), nil
+}
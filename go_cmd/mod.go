@@ -8,6 +8,7 @@ import (
 	"os/exec"
 
 	"github.com/xhd2015/go-vendor-pack/go_cmd/model"
+	"golang.org/x/mod/modfile"
 )
 
 func ParseGoMod(dirOrFile string) (*model.GoMod, error) {
@@ -54,3 +55,63 @@ func ParseGoModContent(content string) (*model.GoMod, error) {
 	}
 	return goMod, nil
 }
+
+// EditGoModContent parses content with golang.org/x/mod/modfile and applies edit,
+// it does not require the go command.
+func EditGoModContent(goModFile string, content []byte, edit func(f *modfile.File) error) ([]byte, *model.GoMod, error) {
+	f, err := modfile.Parse(goModFile, content, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = edit(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("edit %s: %w", goModFile, err)
+	}
+	f.Cleanup()
+	newContent, err := f.Format()
+	if err != nil {
+		return nil, nil, err
+	}
+	return newContent, ModFileToGoMod(f), nil
+}
+
+// ModFileToGoMod converts to the `go mod edit -json` form
+func ModFileToGoMod(f *modfile.File) *model.GoMod {
+	goMod := &model.GoMod{}
+	if f.Module != nil {
+		goMod.Module = model.ModPath{
+			Path:       f.Module.Mod.Path,
+			Deprecated: f.Module.Deprecated,
+		}
+	}
+	if f.Go != nil {
+		goMod.Go = f.Go.Version
+	}
+	for _, req := range f.Require {
+		goMod.Require = append(goMod.Require, model.Require{
+			Path:     req.Mod.Path,
+			Version:  req.Mod.Version,
+			Indirect: req.Indirect,
+		})
+	}
+	for _, exclude := range f.Exclude {
+		goMod.Exclude = append(goMod.Exclude, model.GoModule{
+			Path:    exclude.Mod.Path,
+			Version: exclude.Mod.Version,
+		})
+	}
+	for _, rep := range f.Replace {
+		goMod.Replace = append(goMod.Replace, model.Replace{
+			Old: model.GoModule{Path: rep.Old.Path, Version: rep.Old.Version},
+			New: model.GoModule{Path: rep.New.Path, Version: rep.New.Version},
+		})
+	}
+	for _, retract := range f.Retract {
+		goMod.Retract = append(goMod.Retract, model.Retract{
+			Low:       retract.Low,
+			High:      retract.High,
+			Rationale: retract.Rationale,
+		})
+	}
+	return goMod
+}
@@ -0,0 +1,231 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/tar"
+	"gopkg.in/yaml.v3"
+)
+
+// config files looked up in the pack source dir, in order
+var CONFIG_FILES = []string{"go-pack.json", "go-pack.yaml", "go-pack.yml"}
+
+// Config is go-pack.json or go-pack.yaml in the pack source dir,
+// Profiles are named outputs packed from the same vendor tree,
+// unset fields of a profile default to the top level ones
+type Config struct {
+	Pkg            string `json:"pkg,omitempty" yaml:"pkg,omitempty"`
+	Var            string `json:"var,omitempty" yaml:"var,omitempty"`
+	Output         string `json:"output,omitempty" yaml:"output,omitempty"`                 // generated go file, relative to the config dir
+	OutputDataFile string `json:"outputDataFile,omitempty" yaml:"outputDataFile,omitempty"` // relative to the config dir
+	Base           string `json:"base,omitempty" yaml:"base,omitempty"`                     // base pack data file to make a delta, relative to the config dir
+
+	ModuleWhitelist           []string `json:"moduleWhitelist,omitempty" yaml:"moduleWhitelist,omitempty"`
+	RemoveNonWhitelistVendors *bool    `json:"removeNonWhitelistVendors,omitempty" yaml:"removeNonWhitelistVendors,omitempty"`
+	Excludes                  []string `json:"excludes,omitempty" yaml:"excludes,omitempty"`
+	CompressionLevel          *int     `json:"compressionLevel,omitempty" yaml:"compressionLevel,omitempty"` // 0 means the gzip default
+	Platforms                 []string `json:"platforms,omitempty" yaml:"platforms,omitempty"`
+
+	// pointers tell unset from false, so a profile can turn off a top level one
+	RunGoModTidy           *bool `json:"runGoModTidy,omitempty" yaml:"runGoModTidy,omitempty"`
+	RunGoModVendor         *bool `json:"runGoModVendor,omitempty" yaml:"runGoModVendor,omitempty"`
+	RejectEscapingSymlinks *bool `json:"rejectEscapingSymlinks,omitempty" yaml:"rejectEscapingSymlinks,omitempty"`
+	GenAccessors           *bool `json:"genAccessors,omitempty" yaml:"genAccessors,omitempty"`
+
+	Profiles map[string]*Config `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+}
+
+// LoadConfig reads the config file in dir, returns nil if there is none
+func LoadConfig(dir string) (*Config, error) {
+	for _, name := range CONFIG_FILES {
+		file := filepath.Join(dir, name)
+		_, err := os.Stat(file)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		return LoadConfigFile(file)
+	}
+	return nil, nil
+}
+
+// LoadConfigFile reads a json or yaml config by its extension,
+// output paths are resolved against the dir of file
+func LoadConfigFile(file string) (*Config, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var config Config
+	if strings.HasSuffix(file, ".json") {
+		err = json.Unmarshal(data, &config)
+	} else {
+		err = yaml.Unmarshal(data, &config)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", file, err)
+	}
+	config.resolvePaths(filepath.Dir(file))
+	return &config, nil
+}
+
+func (c *Config) resolvePaths(dir string) {
+	resolve := func(p string) string {
+		if p == "" || filepath.IsAbs(p) {
+			return p
+		}
+		return filepath.Join(dir, p)
+	}
+	c.Output = resolve(c.Output)
+	c.OutputDataFile = resolve(c.OutputDataFile)
+	c.Base = resolve(c.Base)
+	for _, profile := range c.Profiles {
+		if profile != nil {
+			profile.resolvePaths(dir)
+		}
+	}
+}
+
+// ProfileNames returns sorted names of profiles
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the config of profile name merged over the top level,
+// an empty name returns the top level
+func (c *Config) Profile(name string) (*Config, error) {
+	merged := *c
+	merged.Profiles = nil
+	if name == "" {
+		return &merged, nil
+	}
+	profile := c.Profiles[name]
+	if profile == nil {
+		return nil, fmt.Errorf("profile not found: %s, available: %s", name, strings.Join(c.ProfileNames(), ","))
+	}
+	setString := func(dst *string, src string) {
+		if src != "" {
+			*dst = src
+		}
+	}
+	setList := func(dst *[]string, src []string) {
+		if len(src) > 0 {
+			*dst = src
+		}
+	}
+	setString(&merged.Pkg, profile.Pkg)
+	setString(&merged.Var, profile.Var)
+	setString(&merged.Output, profile.Output)
+	setString(&merged.OutputDataFile, profile.OutputDataFile)
+	setString(&merged.Base, profile.Base)
+	setList(&merged.ModuleWhitelist, profile.ModuleWhitelist)
+	setList(&merged.Excludes, profile.Excludes)
+	setList(&merged.Platforms, profile.Platforms)
+	setBool := func(dst **bool, src *bool) {
+		if src != nil {
+			*dst = src
+		}
+	}
+	if profile.CompressionLevel != nil {
+		merged.CompressionLevel = profile.CompressionLevel
+	}
+	setBool(&merged.RemoveNonWhitelistVendors, profile.RemoveNonWhitelistVendors)
+	setBool(&merged.RunGoModTidy, profile.RunGoModTidy)
+	setBool(&merged.RunGoModVendor, profile.RunGoModVendor)
+	setBool(&merged.RejectEscapingSymlinks, profile.RejectEscapingSymlinks)
+	setBool(&merged.GenAccessors, profile.GenAccessors)
+	return &merged, nil
+}
+
+// Options converts c to Options of PackAsBase64ToCode
+func (c *Config) Options() *Options {
+	var whitelist map[string]bool
+	if len(c.ModuleWhitelist) > 0 {
+		whitelist = make(map[string]bool, len(c.ModuleWhitelist))
+		for _, mod := range c.ModuleWhitelist {
+			whitelist[mod] = true
+		}
+	}
+	isTrue := func(b *bool) bool {
+		return b != nil && *b
+	}
+	var compressionLevel int
+	if c.CompressionLevel != nil {
+		compressionLevel = *c.CompressionLevel
+	}
+	return &Options{
+		OutputDataFile:            c.OutputDataFile,
+		RunGoModTidy:              isTrue(c.RunGoModTidy),
+		RunGoModVendor:            isTrue(c.RunGoModVendor),
+		ModuleWhitelist:           whitelist,
+		RemoveNonWhitelistVendors: isTrue(c.RemoveNonWhitelistVendors),
+		RejectEscapingSymlinks:    isTrue(c.RejectEscapingSymlinks),
+		GenAccessors:              isTrue(c.GenAccessors),
+		Excludes:                  c.Excludes,
+		CompressionLevel:          compressionLevel,
+		Platforms:                 c.Platforms,
+	}
+}
+
+// PackConfigs packs each config from dir, go mod tidy and go mod vendor
+// run once before the first one, so all of them share the vendor tree
+func PackConfigs(dir string, configs []*Config) error {
+	if len(configs) > 1 {
+		outputs := make(map[string]bool, 2*len(configs))
+		for _, config := range configs {
+			if config.Options().RemoveNonWhitelistVendors {
+				return fmt.Errorf("removeNonWhitelistVendors cannot be used with multiple profiles, which share the vendor tree")
+			}
+			for _, output := range []string{config.Output, config.OutputDataFile} {
+				if output == "" {
+					continue
+				}
+				output = filepath.Clean(output)
+				if outputs[output] {
+					return fmt.Errorf("profiles cannot share the output %s", output)
+				}
+				outputs[output] = true
+			}
+		}
+	}
+	for i, config := range configs {
+		if config.Pkg == "" || config.Var == "" || config.Output == "" {
+			return fmt.Errorf("requires pkg, var and output")
+		}
+		opts := config.Options()
+		if config.Base != "" {
+			data, err := ioutil.ReadFile(config.Base)
+			if err != nil {
+				return err
+			}
+			opts.Base, err = tar.NewTarFS(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data)))
+			if err != nil {
+				return fmt.Errorf("reading base %s: %w", config.Base, err)
+			}
+		}
+		if i > 0 {
+			opts.RunGoModTidy = false
+			opts.RunGoModVendor = false
+		}
+		err := PackAsBase64ToCode(dir, config.Pkg, config.Var, config.Output, opts)
+		if err != nil {
+			return fmt.Errorf("packing %s: %w", config.Output, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package pack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// go test -run TestLoadConfig -v ./pack
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "go-pack.yaml"), []byte(`pkg: vendors
+var: Pack
+excludes: [vendor/*/testdata]
+genAccessors: true
+profiles:
+  base:
+    output: base/pack.go
+    moduleWhitelist: [golang.org/x/mod]
+  full:
+    var: FullPack
+    output: full/pack.go
+    platforms: [linux/amd64, darwin]
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := config.ProfileNames(); !reflect.DeepEqual(names, []string{"base", "full"}) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "profiles", []string{"base", "full"}, names)
+	}
+	full, err := config.Profile("full")
+	if err != nil {
+		t.Fatal(err)
+	}
+	yes := true
+	expect := &Config{
+		Pkg:          "vendors",
+		Var:          "FullPack",
+		Output:       filepath.Join(dir, "full/pack.go"),
+		Excludes:     []string{"vendor/*/testdata"},
+		Platforms:    []string{"linux/amd64", "darwin"},
+		GenAccessors: &yes,
+	}
+	if !reflect.DeepEqual(full, expect) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "full", expect, full)
+	}
+	base, err := config.Profile("base")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if base.Var != "Pack" || !reflect.DeepEqual(base.ModuleWhitelist, []string{"golang.org/x/mod"}) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "base", "Pack with whitelist", base)
+	}
+	_, err = config.Profile("missing")
+	if err == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "profile not found", err)
+	}
+}
+
+// go test -run TestConfigProfileOverrides -v ./pack
+func TestConfigProfileOverrides(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "go-pack.json"), []byte(`{
+	"pkg": "vendors",
+	"var": "Pack",
+	"output": "pack.go",
+	"genAccessors": true,
+	"runGoModTidy": true,
+	"compressionLevel": 9,
+	"profiles": {
+		"plain": {"output": "plain/pack.go", "genAccessors": false, "compressionLevel": 0},
+		"same": {"output": "pack.go"}
+	}
+}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config, err := LoadConfig(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := config.Profile("plain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := plain.Options()
+	if opts.GenAccessors || !opts.RunGoModTidy || opts.CompressionLevel != 0 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "plain", "genAccessors off, runGoModTidy kept, default compression", opts)
+	}
+	top, err := config.Profile("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts := top.Options(); !opts.GenAccessors || opts.CompressionLevel != 9 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "top", "genAccessors on, compression 9", opts)
+	}
+
+	same, err := config.Profile("same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// rejected before anything is packed
+	err = PackConfigs(dir, []*Config{top, same})
+	if err == nil || !strings.Contains(err.Error(), "cannot share the output") {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "cannot share the output", err)
+	}
+}
+
+// go test -run TestExcludeMatcher -v ./pack
+func TestExcludeMatcher(t *testing.T) {
+	exclude := excludeMatcher([]string{"vendor/*/testdata", "vendor/**/internal/gen", "*.md", "*_test.go"})
+	tests := map[string]bool{
+		"vendor/a/testdata":                 true,
+		"vendor/a/testdata/x.go":            true,
+		"vendor/a/b/testdata/x.go":          false,
+		"vendor/a/internal/gen/x.go":        true,
+		"vendor/a/b/c/internal/gen":         true,
+		"vendor/a/b/internal/generate/x.go": false,
+		"internal/gen/x.go":                 false,
+		"README.md":                         true,
+		"vendor/a/b/README.md":              true,
+		"vendor/a/b/a_test.go":              true,
+		"vendor/a/a.go":                     false,
+	}
+	for name, expect := range tests {
+		if actual := exclude(name); actual != expect {
+			t.Fatalf("expect %s = %+v, actual:%+v", name, expect, actual)
+		}
+	}
+}
+
+// go test -run TestGenHeaderPlatforms -v ./pack
+func TestGenHeaderPlatforms(t *testing.T) {
+	header, err := genHeader([]string{"linux/amd64", "darwin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"//go:build (linux && amd64) || darwin\n", "// +build linux,amd64 darwin\n\n"} {
+		if !strings.Contains(header, line) {
+			t.Fatalf("expect %s = %+v, actual:%+v", "header", line, header)
+		}
+	}
+	_, err = genHeader([]string{"linux/"})
+	if err == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "invalid platform", err)
+	}
+}
@@ -0,0 +1,64 @@
+package pack
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+	"github.com/xhd2015/go-vendor-pack/packfs"
+)
+
+// Delta returns a base64 encoded delta pack holding files of target that are
+// added or changed since base, and go.delta.json, see packfs.ApplyDelta
+func Delta(base packfs.FS, target packfs.FS) ([]byte, error) {
+	baseGoList, err := readGoListFS(base)
+	if err != nil {
+		return nil, err
+	}
+	if baseGoList == nil || baseGoList.Digest == "" {
+		return nil, fmt.Errorf("base pack has no digest")
+	}
+	if packfs.IsDelta(base) || packfs.IsDelta(target) {
+		return nil, fmt.Errorf("delta of delta packs is not supported")
+	}
+	targetGoListJSON, err := target.ReadFile(FILE_GO_LIST_JSON)
+	if err != nil {
+		return nil, err
+	}
+	targetGoList, err := readGoListFS(target)
+	if err != nil {
+		return nil, err
+	}
+	changed, deleted, err := packfs.DeltaNames(base, target)
+	if err != nil {
+		return nil, err
+	}
+	contentDigest, err := packfs.ContentDigest(target)
+	if err != nil {
+		return nil, err
+	}
+	deltaJSON, err := json.Marshal(&pack_model.Delta{
+		BaseDigest:    baseGoList.Digest,
+		Digest:        targetGoList.Digest,
+		ContentDigest: contentDigest,
+		Deleted:       deleted,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// changed entries and their parent dirs
+	included := make(map[string]bool, len(changed))
+	for _, name := range changed {
+		for p := name; p != "." && !included[p]; p = path.Dir(p) {
+			included[p] = true
+		}
+	}
+	return writePackFS(target, nil, map[string][]byte{
+		FILE_GO_LIST_JSON:      targetGoListJSON,
+		packfs.FILE_DELTA_JSON: deltaJSON,
+	}, func(name string, dir bool) bool {
+		return included[name]
+	})
+}
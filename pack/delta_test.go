@@ -0,0 +1,79 @@
+package pack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+)
+
+// go test -run TestDelta -v ./pack
+func TestDelta(t *testing.T) {
+	base := packtest.New(t, &packtest.Pack{
+		Digest:      "base",
+		PackTimeUTC: "2024-01-01 00:00:00",
+		Modules: map[string]string{
+			"example.com/a": "v1.0.0",
+			"example.com/b": "v1.0.0",
+		},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go":   "package a // v1.0.0",
+			"vendor/example.com/a/old.go": "package a",
+			"vendor/example.com/b/b.go":   "package b",
+		},
+	})
+	target := packtest.New(t, &packtest.Pack{
+		Digest:      "target",
+		PackTimeUTC: "2024-01-01 00:00:00",
+		Modules: map[string]string{
+			"example.com/a": "v1.1.0",
+			"example.com/b": "v1.0.0",
+		},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go":   "package a // v1.1.0",
+			"vendor/example.com/a/new.go": "package a",
+			"vendor/example.com/b/b.go":   "package b",
+		},
+	})
+
+	data, err := Delta(base, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deltaPack := packtest.Decode(t, data)
+	_, err = deltaPack.ReadFile("vendor/example.com/b/b.go")
+	if !packfs.IsNotExists(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "unchanged b.go in delta", "not exists", err)
+	}
+
+	result, err := packfs.ApplyDelta(base, deltaPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTestFile(t, result, "vendor/example.com/a/a.go", "package a // v1.1.0")
+	expectTestFile(t, result, "vendor/example.com/a/new.go", "package a")
+	expectTestFile(t, result, "vendor/example.com/b/b.go", "package b")
+	expectTestFile(t, result, "go.mod.versions", "example.com/a v1.1.0\nexample.com/b v1.0.0")
+	_, err = result.ReadFile("vendor/example.com/a/old.go")
+	if !packfs.IsNotExists(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "old.go", "not exists", err)
+	}
+	resultGoList, err := readGoListFS(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetGoList, err := readGoListFS(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resultGoList.Digest != targetGoList.Digest {
+		t.Fatalf("expect %s = %+v, actual:%+v", "digest", targetGoList.Digest, resultGoList.Digest)
+	}
+
+	// applying on another base fails
+	_, err = packfs.ApplyDelta(target, deltaPack)
+	if err == nil || !strings.Contains(err.Error(), "delta requires base") {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "delta requires base", err)
+	}
+}
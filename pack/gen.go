@@ -0,0 +1,278 @@
+package pack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"go/ast"
+	"go/build/constraint"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/tar"
+)
+
+const codeHeader = "// Code generated by github.com/xhd2015/go-vendor-pack/cmd/go-pack. DO NOT EDIT.\n"
+
+type genOptions struct {
+	// accessors: only one pack with accessors is allowed per package
+	accessors bool
+	platforms []string
+}
+
+// genCode generates `var varName = "data"`, optionally with accessors
+func genCode(pkg string, varName string, data []byte, opts *genOptions) (string, error) {
+	if opts == nil {
+		opts = &genOptions{}
+	}
+	err := checkGenNames(pkg, varName)
+	if err != nil {
+		return "", err
+	}
+	header, err := genHeader(opts.platforms)
+	if err != nil {
+		return "", err
+	}
+	if !opts.accessors {
+		return fmt.Sprintf(`%spackage %s
+
+var %s = "%s"
+`, header, pkg, varName, string(data)), nil
+	}
+	fs, err := tar.NewTarFS(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data)))
+	if err != nil {
+		return "", err
+	}
+	goList, err := readGoListFS(fs)
+	if err != nil {
+		return "", err
+	}
+	if goList == nil {
+		return "", fmt.Errorf("missing %s", FILE_GO_LIST_JSON)
+	}
+	// unexported vars, prefixed to not conflict with the package
+	prefix := "_" + strings.ToLower(varName[:1]) + varName[1:]
+	return fmt.Sprintf(`%[1]spackage %[2]s
+
+import (
+	"sync"
+
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/unpack"
+)
+
+// Digest and PackTimeUTC are from go.list.json of %[3]s
+const (
+	Digest      = %[4]q
+	PackTimeUTC = %[5]q
+)
+
+var %[3]s = "%[6]s"
+
+var (
+	%[7]sFSOnce sync.Once
+	%[7]sFS     packfs.FS
+	%[7]sFSErr  error
+
+	%[7]sGoListOnce sync.Once
+	%[7]sGoList     *pack_model.GoList
+	%[7]sGoListErr  error
+)
+
+// FS returns the pack decoded from %[3]s, it panics if %[3]s is corrupted
+func FS() packfs.FS {
+	%[7]sFSOnce.Do(func() {
+		%[7]sFS, %[7]sFSErr = unpack.NewTarFSWithBase64Decode(%[3]s)
+	})
+	if %[7]sFSErr != nil {
+		panic(%[7]sFSErr)
+	}
+	return %[7]sFS
+}
+
+// GoList returns go.list.json of the pack, read once
+func GoList() (*pack_model.GoList, error) {
+	%[7]sGoListOnce.Do(func() {
+		%[7]sGoList, %[7]sGoListErr = unpack.ReadGoList(FS())
+	})
+	return %[7]sGoList, %[7]sGoListErr
+}
+
+// Unpack unpacks the pack into dir, see unpack.Unpack
+func Unpack(dir string, opts *unpack.Options) error {
+	_, err := unpack.Unpack(FS(), dir, opts)
+	return err
+}
+`, header, pkg, varName, goList.Digest, goList.PackTimeUTC, string(data), prefix), nil
+}
+
+// accessorNames are declared by the generated accessors
+var accessorNames = []string{"FS", "GoList", "Unpack", "Digest", "PackTimeUTC"}
+
+func checkGenNames(pkg string, varName string) error {
+	if !token.IsIdentifier(pkg) {
+		return fmt.Errorf("invalid package name: %q", pkg)
+	}
+	if !token.IsIdentifier(varName) {
+		return fmt.Errorf("invalid var name: %q", varName)
+	}
+	return nil
+}
+
+// checkAccessorConflicts fails if another file of pkg next to dstFile declares
+// one of accessorNames for any of platforms, i.e. the package already has a pack with accessors
+func checkAccessorConflicts(pkg string, dstFile string, platforms []string) error {
+	dir := filepath.Dir(dstFile)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	absDstFile, err := filepath.Abs(dstFile)
+	if err != nil {
+		return err
+	}
+	for _, info := range files {
+		name := info.Name()
+		if info.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file := filepath.Join(dir, name)
+		absFile, err := filepath.Abs(file)
+		if err != nil {
+			return err
+		}
+		if absFile == absDstFile {
+			continue
+		}
+		f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ParseComments)
+		if err != nil {
+			return err
+		}
+		if f.Name.Name != pkg || !platformsOverlap(platforms, buildConstraint(f)) {
+			continue
+		}
+		for _, accessor := range accessorNames {
+			if declares(f, accessor) {
+				return fmt.Errorf("%s already declares %s, only one pack per package can have accessors", file, accessor)
+			}
+		}
+	}
+	return nil
+}
+
+// buildConstraint returns the //go:build or // +build constraint of f, nil if none
+func buildConstraint(f *ast.File) constraint.Expr {
+	for _, group := range f.Comments {
+		if group.Pos() > f.Package {
+			break
+		}
+		for _, c := range group.List {
+			if !constraint.IsGoBuild(c.Text) && !constraint.IsPlusBuild(c.Text) {
+				continue
+			}
+			expr, err := constraint.Parse(c.Text)
+			if err == nil {
+				return expr
+			}
+		}
+	}
+	return nil
+}
+
+// platformsOverlap reports whether a file restricted to platforms, all if empty,
+// may be built together with a file constrained by expr, nil if unconstrained
+func platformsOverlap(platforms []string, expr constraint.Expr) bool {
+	if len(platforms) == 0 || expr == nil {
+		return true
+	}
+	for _, platform := range platforms {
+		parts := strings.Split(strings.TrimSpace(platform), "/")
+		archs := []string{""}
+		if len(parts) == 2 {
+			archs = []string{parts[1]}
+		} else {
+			// any arch, try the ones expr mentions
+			archs = append(archs, constraintTags(expr)...)
+		}
+		for _, arch := range archs {
+			ok := expr.Eval(func(tag string) bool {
+				return tag == parts[0] || (arch != "" && tag == arch)
+			})
+			if ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func constraintTags(expr constraint.Expr) []string {
+	switch e := expr.(type) {
+	case *constraint.TagExpr:
+		return []string{e.Tag}
+	case *constraint.NotExpr:
+		return constraintTags(e.X)
+	case *constraint.AndExpr:
+		return append(constraintTags(e.X), constraintTags(e.Y)...)
+	case *constraint.OrExpr:
+		return append(constraintTags(e.X), constraintTags(e.Y)...)
+	}
+	return nil
+}
+
+// declares reports whether f declares name at the package level
+func declares(f *ast.File, name string) bool {
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil && decl.Name.Name == name {
+				return true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.ValueSpec:
+					for _, ident := range spec.Names {
+						if ident.Name == name {
+							return true
+						}
+					}
+				case *ast.TypeSpec:
+					if spec.Name.Name == name {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// genHeader adds build constraints for platforms like linux/amd64 or darwin
+func genHeader(platforms []string) (string, error) {
+	if len(platforms) == 0 {
+		return codeHeader, nil
+	}
+	var exprs, plusBuild []string
+	for _, platform := range platforms {
+		parts := strings.Split(strings.TrimSpace(platform), "/")
+		if len(parts) > 2 || parts[0] == "" || (len(parts) == 2 && parts[1] == "") {
+			return "", fmt.Errorf("invalid platform: %s, expecting GOOS or GOOS/GOARCH", platform)
+		}
+		if len(parts) == 1 {
+			exprs = append(exprs, parts[0])
+		} else {
+			exprs = append(exprs, "("+parts[0]+" && "+parts[1]+")")
+		}
+		plusBuild = append(plusBuild, strings.Join(parts, ","))
+	}
+	return fmt.Sprintf("%s\n//go:build %s\n// +build %s\n\n", codeHeader, strings.Join(exprs, " || "), strings.Join(plusBuild, " ")), nil
+}
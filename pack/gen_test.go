@@ -0,0 +1,145 @@
+package pack
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+)
+
+// go test -run TestGenCodeAccessors -v ./pack
+func TestGenCodeAccessors(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{
+		PackTimeUTC: "2024-01-01 00:00:00",
+		Modules:     map[string]string{"example.com/a": "v1.0.0"},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go": "package a",
+		},
+	})
+	goList, err := readGoListFS(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := writePackFS(fs, goList, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	packed := packtest.Decode(t, data)
+	packedGoList, err := readGoListFS(packed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, err := genCode("vendors", "Pack", data, &genOptions{accessors: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := parser.ParseFile(token.NewFileSet(), "pack.go", code, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	funcs := make(map[string]bool)
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok {
+			funcs[fn.Name.Name] = true
+		}
+	}
+	for _, name := range []string{"FS", "GoList", "Unpack"} {
+		if !funcs[name] {
+			t.Fatalf("expect %s = %+v, actual:%+v", "func "+name, true, false)
+		}
+	}
+	expectDigest := `Digest      = "` + packedGoList.Digest + `"`
+	if !strings.Contains(code, expectDigest) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "code", expectDigest, code[:500])
+	}
+}
+
+// go test -run TestGenCodeCompiles -v ./pack
+func TestGenCodeCompiles(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{
+		PackTimeUTC: "2024-01-01 00:00:00",
+		Modules:     map[string]string{"example.com/a": "v1.0.0"},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go": "package a",
+		},
+	})
+	goList, err := readGoListFS(fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := writePackFS(fs, goList, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// inside the module, so the generated imports resolve
+	dir, err := ioutil.TempDir("testdata", "gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for _, varName := range []string{"Pack", "pack"} {
+		code, err := genCode("vendors", varName, data, &genOptions{accessors: true, platforms: []string{"linux", "darwin", "windows"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(dir, "pack.go"), []byte(code), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cmd := exec.Command("go", "vet", ".")
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("go vet %s: %v\n%s", varName, err, out)
+		}
+	}
+
+	for _, varName := range []string{"", "1Pack", "Pack-A"} {
+		_, err := genCode("vendors", varName, data, &genOptions{accessors: true})
+		if err == nil {
+			t.Fatalf("expect %s = %+v, actual:%+v", "var "+varName, "invalid var name", err)
+		}
+	}
+}
+
+// go test -run TestCheckAccessorConflicts -v ./pack
+func TestCheckAccessorConflicts(t *testing.T) {
+	dir := t.TempDir()
+	err := ioutil.WriteFile(filepath.Join(dir, "linux.go"), []byte("//go:build linux && amd64\n\npackage vendors\n\nfunc FS() {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "other.go"), []byte("package other\n\nfunc FS() {}\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		platforms []string
+		conflict  bool
+	}{
+		{nil, true},
+		{[]string{"linux"}, true},
+		{[]string{"linux/amd64"}, true},
+		{[]string{"linux/arm64"}, false},
+		{[]string{"darwin", "windows/amd64"}, false},
+	}
+	for _, tt := range tests {
+		err := checkAccessorConflicts("vendors", filepath.Join(dir, "pack.go"), tt.platforms)
+		if (err != nil) != tt.conflict {
+			t.Fatalf("expect %s = %+v, actual:%+v", strings.Join(tt.platforms, ","), tt.conflict, err)
+		}
+	}
+	// regenerating a file never conflicts with itself
+	err = checkAccessorConflicts("vendors", filepath.Join(dir, "linux.go"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,331 @@
+package pack
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/tar"
+	"golang.org/x/mod/module"
+)
+
+type MergeOptions struct {
+	Strategy packfs.UnionStrategy
+}
+
+// Merge merges packs into one base64 encoded pack, modules
+// found in several packs are resolved by opts.Strategy
+func Merge(packs []packfs.FS, opts *MergeOptions) ([]byte, []*packfs.UnionConflict, error) {
+	var unionOpts packfs.UnionOptions
+	if opts != nil {
+		unionOpts.Strategy = opts.Strategy
+	}
+	union, err := packfs.UnionWithOptions(&unionOpts, packs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	goList, err := readGoListFS(union)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := writePackFS(union, goList, nil, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, union.Conflicts(), nil
+}
+
+type SplitOptions struct {
+	// Groups maps name of each output pack to its modules,
+	// if empty, each module goes to a pack named by its path
+	Groups map[string][]string
+}
+
+// Split splits a pack into base64 encoded packs, each with vendor/<module>,
+// original go.mod of modules, go.list.json, go.sum and go.mod.versions
+// of its modules only. Files of the main module are dropped.
+func Split(fsys packfs.FS, opts *SplitOptions) (map[string][]byte, error) {
+	versionsData, err := fsys.ReadFile("go.mod.versions")
+	if err != nil {
+		return nil, err
+	}
+	versions := parseVersionLines(string(versionsData))
+	goSum, err := fsys.ReadFile("go.sum")
+	if err != nil {
+		return nil, err
+	}
+	goList, err := readGoListFS(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups map[string][]string
+	if opts != nil && len(opts.Groups) > 0 {
+		groups = opts.Groups
+		for name, mods := range groups {
+			for _, mod := range mods {
+				if _, ok := versions[mod]; !ok {
+					return nil, fmt.Errorf("group %s: module not found in pack: %s", name, mod)
+				}
+			}
+		}
+	} else {
+		groups = make(map[string][]string, len(versions))
+		for mod := range versions {
+			groups[mod] = []string{mod}
+		}
+	}
+	allModules := make([]string, 0, len(versions))
+	for mod := range versions {
+		allModules = append(allModules, mod)
+	}
+	// longer first, so nested modules own their files
+	sort.Slice(allModules, func(i, j int) bool {
+		return len(allModules[i]) > len(allModules[j])
+	})
+
+	result := make(map[string][]byte, len(groups))
+	for name, mods := range groups {
+		modSet := make(map[string]bool, len(mods))
+		for _, mod := range mods {
+			modSet[mod] = true
+		}
+		var versionLines []string
+		for _, mod := range mods {
+			versionLines = append(versionLines, mod+" "+versions[mod])
+		}
+		sort.Strings(versionLines)
+		var sumLines []string
+		for _, line := range strings.Split(string(goSum), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 || !modSet[fields[0]] {
+				continue
+			}
+			if version := versions[fields[0]]; fields[1] == version || fields[1] == version+"/go.mod" {
+				sumLines = append(sumLines, line)
+			}
+		}
+		var groupGoList *pack_model.GoList
+		if goList != nil {
+			copied := *goList
+			copied.Modules = nil
+			for _, mod := range goList.Modules {
+				if mod != nil && mod.ModulePublic != nil && modSet[mod.Path] {
+					copied.Modules = append(copied.Modules, mod)
+				}
+			}
+			groupGoList = &copied
+		}
+		include, err := splitIncluder(modSet, allModules)
+		if err != nil {
+			return nil, err
+		}
+		data, err := writePackFS(fsys, groupGoList, map[string][]byte{
+			"go.mod.versions": []byte(strings.Join(versionLines, "\n")),
+			"go.sum":          []byte(strings.Join(sumLines, "\n")),
+		}, include)
+		if err != nil {
+			return nil, fmt.Errorf("split %s: %w", name, err)
+		}
+		result[name] = data
+	}
+	return result, nil
+}
+
+// splitIncluder includes vendor/<module> and modcache/<escaped module> of
+// modules in modSet, and their parent dirs
+func splitIncluder(modSet map[string]bool, allModules []string) (func(name string, dir bool) bool, error) {
+	var roots []string
+	for mod := range modSet {
+		escPath, err := module.EscapePath(mod)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, path.Join("vendor", mod), path.Join(pack_model.DIR_MOD_CACHE, escPath))
+	}
+	return func(name string, dir bool) bool {
+		for _, root := range roots {
+			if dir && strings.HasPrefix(root, name+"/") {
+				return true
+			}
+		}
+		if strings.HasPrefix(name, "vendor/") {
+			rel := strings.TrimPrefix(name, "vendor/")
+			for _, mod := range allModules {
+				if rel == mod || strings.HasPrefix(rel, mod+"/") {
+					return modSet[mod]
+				}
+			}
+		}
+		for _, root := range roots {
+			if name == root || strings.HasPrefix(name, root+"/") {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func readGoListFS(fsys packfs.FS) (*pack_model.GoList, error) {
+	data, err := fsys.ReadFile(FILE_GO_LIST_JSON)
+	if err != nil {
+		if packfs.IsNotExists(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var goList *pack_model.GoList
+	err = json.Unmarshal(data, &goList)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", FILE_GO_LIST_JSON, err)
+	}
+	return goList, nil
+}
+
+func parseVersionLines(s string) map[string]string {
+	m := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		sp := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if sp[0] == "" {
+			continue
+		}
+		var version string
+		if len(sp) == 2 {
+			version = strings.TrimSpace(sp[1])
+		}
+		m[sp[0]] = version
+	}
+	return m
+}
+
+// writePackFS writes files of fsys accepted by include as a base64 encoded pack,
+// files in meta replace those of fsys. Like PackAsBase64, go.list.json comes
+// last, with the digest of everything before it
+func writePackFS(fsys packfs.FS, goList *pack_model.GoList, meta map[string][]byte, include func(name string, dir bool) bool) ([]byte, error) {
+	h := md5.New()
+	var buf bytes.Buffer
+	writer := base64.NewEncoder(base64.StdEncoding, &buf)
+	twWriter, flush, close := tar.WrapTarWriter(io.MultiWriter(writer, h))
+
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+		for _, e := range entries {
+			name := e.Name()
+			if dir != "." {
+				name = dir + "/" + e.Name()
+			}
+			if _, ok := meta[name]; ok || name == FILE_GO_LIST_JSON {
+				continue
+			}
+			isSymlink := e.Type()&fs.ModeSymlink != 0
+			isDir := e.IsDir() && !isSymlink
+			if include != nil && !include(name, isDir) {
+				continue
+			}
+			if isSymlink {
+				if sfs, ok := fsys.(packfs.SymlinkFS); ok {
+					link, err := sfs.Readlink(name)
+					if err != nil {
+						return err
+					}
+					err = tar.TarAddSymlink(twWriter, name, link)
+					if err != nil {
+						return err
+					}
+					continue
+				}
+			}
+			if isDir {
+				err := tar.TarAddDir(twWriter, name, 0755)
+				if err != nil {
+					return err
+				}
+				err = walk(name)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			content, err := fsys.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			var mode fs.FileMode = 0644
+			info, err := packfs.Stat(fsys, name)
+			if err != nil {
+				return err
+			}
+			if info != nil && info.Mode()&0111 != 0 {
+				mode = 0755
+			}
+			err = tar.TarAddFile(twWriter, name, int64(len(content)), mode, bytes.NewReader(content))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(".")
+	if err != nil {
+		close()
+		return nil, err
+	}
+	metaNames := make([]string, 0, len(meta))
+	for name := range meta {
+		metaNames = append(metaNames, name)
+	}
+	sort.Strings(metaNames)
+	for _, name := range metaNames {
+		var mode fs.FileMode = 0644
+		if name == FILE_GO_LIST_JSON {
+			// same as PackAsBase64
+			mode = 0755
+		}
+		err := tar.TarAddFile(twWriter, name, int64(len(meta[name])), mode, bytes.NewReader(meta[name]))
+		if err != nil {
+			close()
+			return nil, err
+		}
+	}
+	if goList != nil {
+		flush()
+		copied := *goList
+		copied.Digest = hex.EncodeToString(h.Sum(nil))
+		goListJSON, err := json.Marshal(&copied)
+		if err != nil {
+			close()
+			return nil, err
+		}
+		err = tar.TarAddFile(twWriter, FILE_GO_LIST_JSON, int64(len(goListJSON)), 0755, bytes.NewReader(goListJSON))
+		if err != nil {
+			close()
+			return nil, err
+		}
+	}
+	err = close()
+	if err != nil {
+		return nil, err
+	}
+	err = writer.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,104 @@
+package pack
+
+import (
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+)
+
+func expectTestFile(t *testing.T, fs packfs.FS, file string, expect string) {
+	t.Helper()
+	content, err := fs.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expect {
+		t.Fatalf("expect %s = %+v, actual:%+v", file, expect, string(content))
+	}
+}
+
+// go test -run TestMergeAndSplit -v ./pack
+func TestMergeAndSplit(t *testing.T) {
+	base := packtest.New(t, &packtest.Pack{
+		PackTimeUTC: "2024-01-01 00:00:00",
+		Modules: map[string]string{
+			"example.com/a": "v1.0.0",
+			"example.com/b": "v1.2.0",
+		},
+		Files: map[string]string{
+			"go.mod":                    "module base",
+			"vendor/example.com/a/a.go": "package a // v1.0.0",
+			"vendor/example.com/b/b.go": "package b // v1.2.0",
+		},
+	})
+	feature := packtest.New(t, &packtest.Pack{
+		PackTimeUTC: "2024-01-01 00:00:00",
+		Modules: map[string]string{
+			"example.com/a":     "v1.1.0",
+			"example.com/a/sub": "v0.1.0",
+		},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go":     "package a // v1.1.0",
+			"vendor/example.com/a/sub/s.go": "package sub",
+		},
+	})
+
+	_, _, err := Merge([]packfs.FS{base, feature}, &MergeOptions{Strategy: packfs.UnionStrategy_Fail})
+	if err == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "modules with different versions", err)
+	}
+
+	data, conflicts, err := Merge([]packfs.FS{base, feature}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0].Module != "example.com/a" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "conflicts", "example.com/a", conflicts)
+	}
+	merged := packtest.Decode(t, data)
+	expectTestFile(t, merged, "go.mod", "module base")
+	expectTestFile(t, merged, "vendor/example.com/a/a.go", "package a // v1.1.0")
+	expectTestFile(t, merged, "vendor/example.com/a/sub/s.go", "package sub")
+	expectTestFile(t, merged, "vendor/example.com/b/b.go", "package b // v1.2.0")
+	expectTestFile(t, merged, "go.mod.versions", "example.com/a v1.1.0\nexample.com/a/sub v0.1.0\nexample.com/b v1.2.0")
+	goList, err := readGoListFS(merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if goList.Digest == "" || len(goList.Modules) != 3 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "go.list.json", "digest and 3 modules", goList)
+	}
+
+	packs, err := Split(merged, &SplitOptions{Groups: map[string][]string{
+		"a": {"example.com/a"},
+		"b": {"example.com/b", "example.com/a/sub"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := packtest.Decode(t, packs["a"])
+	expectTestFile(t, a, "vendor/example.com/a/a.go", "package a // v1.1.0")
+	expectTestFile(t, a, "go.mod.versions", "example.com/a v1.1.0")
+	expectTestFile(t, a, "go.sum", "example.com/a v1.1.0 h1:example.com/a@v1.1.0")
+	for _, file := range []string{"vendor/example.com/a/sub/s.go", "vendor/example.com/b/b.go", "go.mod"} {
+		_, err := a.ReadFile(file)
+		if !packfs.IsNotExists(err) {
+			t.Fatalf("expect %s = %+v, actual:%+v", file, "not exists", err)
+		}
+	}
+	b := packtest.Decode(t, packs["b"])
+	expectTestFile(t, b, "vendor/example.com/a/sub/s.go", "package sub")
+	expectTestFile(t, b, "vendor/example.com/b/b.go", "package b // v1.2.0")
+	_, err = b.ReadFile("vendor/example.com/a/a.go")
+	if !packfs.IsNotExists(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "a.go", "not exists", err)
+	}
+	goList, err = readGoListFS(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goList.Modules) != 2 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "len(modules)", 2, len(goList.Modules))
+	}
+}
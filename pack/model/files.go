@@ -0,0 +1,10 @@
+package model
+
+// DIR_MOD_CACHE holds the original go.mod of packed modules, laid out
+// like $GOMODCACHE/cache/download:
+//
+//	modcache/<escaped module>/@v/<escaped version>.mod
+//
+// vendor/ does not keep go.mod, but the module cache needs the exact
+// file to match the `/go.mod` line in go.sum.
+const DIR_MOD_CACHE = "modcache"
@@ -13,3 +13,12 @@ type Module struct {
 	*model.ModulePublic
 	Packages []*model.PackagePublic
 }
+
+// Delta is go.delta.json of a delta pack, which holds only files
+// added or changed since the base pack
+type Delta struct {
+	BaseDigest    string   // GoList.Digest of the base pack
+	Digest        string   // GoList.Digest of the result
+	ContentDigest string   // see packfs.ContentDigest, verified after applying
+	Deleted       []string // files and dirs removed from the base
+}
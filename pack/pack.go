@@ -3,6 +3,7 @@ package pack
 import (
 	tarlib "archive/tar"
 	"bytes"
+	"compress/gzip"
 	"crypto/md5"
 	"encoding/base64"
 	"encoding/hex"
@@ -23,8 +24,10 @@ import (
 	"github.com/xhd2015/go-inspect/sh"
 	"github.com/xhd2015/go-vendor-pack/go_cmd"
 	"github.com/xhd2015/go-vendor-pack/go_cmd/model"
+	"github.com/xhd2015/go-vendor-pack/packfs"
 
 	"github.com/xhd2015/go-vendor-pack/tar"
+	"golang.org/x/mod/module"
 )
 
 type Options struct {
@@ -33,6 +36,22 @@ type Options struct {
 	RunGoModVendor            bool
 	ModuleWhitelist           map[string]bool
 	RemoveNonWhitelistVendors bool
+	// RejectEscapingSymlinks fails pack if a symlink points outside
+	// of its module root, symlinks in the main module must stay inside dir
+	RejectEscapingSymlinks bool
+	// Base makes a delta pack relative to it, see Delta
+	Base packfs.FS
+	// GenAccessors generates FS, GoList, Unpack, Digest and PackTimeUTC next to
+	// the var, cannot be used with Base, at most one pack per package per platform
+	GenAccessors bool
+	// Excludes are path.Match patterns of paths in the pack not packed, e.g. vendor/**/testdata,
+	// *_test.go. A matching dir excludes everything under it, see excludeMatcher
+	Excludes []string
+	// CompressionLevel is the gzip level, 0 means default
+	CompressionLevel int
+	// Platforms restricts the generated code by build
+	// constraints, e.g. linux/amd64, darwin
+	Platforms []string
 }
 
 // f, err := os.OpenFile(dstFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0755)
@@ -43,16 +62,33 @@ type Options struct {
 //
 // defer f.Close()
 func PackAsBase64ToCode(dir string, pkg string, varName string, dstFile string, opts *Options) error {
+	if opts != nil && opts.GenAccessors && opts.Base != nil {
+		return fmt.Errorf("accessors cannot be generated for delta packs")
+	}
+	// checked before packing, which takes long
+	err := checkGenNames(pkg, varName)
+	if err != nil {
+		return err
+	}
+	if opts != nil && opts.GenAccessors {
+		err := checkAccessorConflicts(pkg, dstFile, opts.Platforms)
+		if err != nil {
+			return err
+		}
+	}
 	data, err := PackAsBase64(dir, opts)
 	if err != nil {
 		return err
 	}
-	dataStr := string(data)
-	code := fmt.Sprintf(`// Code generated by github.com/xhd2015/go-vendor-pack/cmd/go-pack. DO NOT EDIT.
-package %s
-
-var %s = "%s"
-`, pkg, varName, dataStr)
+	var genOpts genOptions
+	if opts != nil {
+		genOpts.accessors = opts.GenAccessors
+		genOpts.platforms = opts.Platforms
+	}
+	code, err := genCode(pkg, varName, data, &genOpts)
+	if err != nil {
+		return err
+	}
 	if opts != nil && opts.OutputDataFile != "" {
 		err := ioutil.WriteFile(opts.OutputDataFile, data, 0755)
 		if err != nil {
@@ -141,11 +177,24 @@ func PackAsBase64(dir string, opts *Options) ([]byte, error) {
 		}
 	}
 
+	modCacheGoMods, err := readModCacheGoMods(modules)
+	if err != nil {
+		return nil, fmt.Errorf("reading go.mod from module cache: %w", err)
+	}
+
 	h := md5.New()
 	var buf bytes.Buffer
 	writer := base64.NewEncoder(base64.StdEncoding, &buf)
 	// NOTE: when pack, always set clearModTime to be true
-	err = tarFilesAndVendors(dir, io.MultiWriter(writer, h), FILE_GO_LIST_JSON, opts.ModuleWhitelist, true /*clear mod time*/, func(twWriter *tarlib.Writer) error {
+	var checkSymlink func(relPath string, target string) error
+	if opts.RejectEscapingSymlinks {
+		checkSymlink = escapingSymlinkChecker(modules)
+	}
+	compressionLevel := opts.CompressionLevel
+	if compressionLevel == 0 {
+		compressionLevel = gzip.DefaultCompression
+	}
+	err = tarFilesAndVendors(dir, io.MultiWriter(writer, h), FILE_GO_LIST_JSON, opts.ModuleWhitelist, modCacheGoMods, true /*clear mod time*/, checkSymlink, excludeMatcher(opts.Excludes), compressionLevel, func(twWriter *tarlib.Writer) error {
 		digest := hex.EncodeToString(h.Sum(nil))
 
 		var prevDigest string
@@ -193,6 +242,13 @@ func PackAsBase64(dir string, opts *Options) ([]byte, error) {
 	if err := writer.Close(); err != nil {
 		return nil, err
 	}
+	if opts.Base != nil {
+		target, err := tar.NewTarFS(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(buf.Bytes())))
+		if err != nil {
+			return nil, err
+		}
+		return Delta(opts.Base, target)
+	}
 
 	return buf.Bytes(), nil
 }
@@ -253,8 +309,53 @@ func cleanVendors(dir string, moduleWhitelist map[string]bool) error {
 	}
 	return nil
 }
-func tarFilesAndVendors(dir string, writer io.Writer, excludeFile string, moduleWhitelist map[string]bool, clearModTime bool, afterWritten func(twWriter *tarlib.Writer) error) error {
-	twWriter, flush, close := tar.WrapTarWriter(writer)
+
+// readModCacheGoMods reads the original go.mod of each module from
+// $GOMODCACHE/cache/download, so that unpack can install modules into
+// the module cache. Modules not downloaded are skipped.
+// returns pack file name -> content
+func readModCacheGoMods(modules []*pack_model.Module) (map[string][]byte, error) {
+	if len(modules) == 0 {
+		return nil, nil
+	}
+	modCacheDir, err := go_cmd.GoEnv("GOMODCACHE")
+	if err != nil {
+		return nil, err
+	}
+	if modCacheDir == "" {
+		return nil, nil
+	}
+	files := make(map[string][]byte)
+	for _, mod := range modules {
+		if mod.Main || mod.Version == "" {
+			continue
+		}
+		escPath, err := module.EscapePath(mod.Path)
+		if err != nil {
+			return nil, err
+		}
+		escVersion, err := module.EscapeVersion(mod.Version)
+		if err != nil {
+			return nil, err
+		}
+		modFile := path.Join("cache", "download", escPath, "@v", escVersion+".mod")
+		content, err := ioutil.ReadFile(filepath.Join(modCacheDir, modFile))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		files[path.Join(pack_model.DIR_MOD_CACHE, escPath, "@v", escVersion+".mod")] = content
+	}
+	return files, nil
+}
+
+func tarFilesAndVendors(dir string, writer io.Writer, excludeFile string, moduleWhitelist map[string]bool, extraFiles map[string][]byte, clearModTime bool, checkSymlink func(relPath string, target string) error, exclude func(relPath string) bool, compressionLevel int, afterWritten func(twWriter *tarlib.Writer) error) error {
+	twWriter, flush, close, err := tar.WrapTarWriterLevel(writer, compressionLevel)
+	if err != nil {
+		return err
+	}
 	defer close()
 
 	// if no whitelist, pack all
@@ -262,8 +363,9 @@ func tarFilesAndVendors(dir string, writer io.Writer, excludeFile string, module
 		err := tar.TarAppend(dir, twWriter, &tar.TarOptions{
 			ClearModTime: clearModTime,
 			ShouldInclude: func(relPath string, dir bool) bool {
-				return relPath != excludeFile
+				return relPath != excludeFile && !exclude(relPath)
 			},
+			CheckSymlink: checkSymlink,
 		})
 		if err != nil {
 			return err
@@ -274,8 +376,9 @@ func tarFilesAndVendors(dir string, writer io.Writer, excludeFile string, module
 		err := tar.TarAppend(dir, twWriter, &tar.TarOptions{
 			ClearModTime: clearModTime,
 			ShouldInclude: func(relPath string, dir bool) bool {
-				return relPath != "vendor" && relPath != excludeFile
+				return relPath != "vendor" && relPath != excludeFile && !exclude(relPath)
 			},
+			CheckSymlink: checkSymlink,
 		})
 		if err != nil {
 			return err
@@ -303,12 +406,22 @@ func tarFilesAndVendors(dir string, writer io.Writer, excludeFile string, module
 			err = tar.TarAppend(path.Join(dir, "vendor", mod), twWriter, &tar.TarOptions{
 				ClearModTime: clearModTime,
 				WritePrefix:  path.Join("vendor", mod),
+				ShouldInclude: func(relPath string, dir bool) bool {
+					return !exclude(path.Join("vendor", mod, relPath))
+				},
+				CheckSymlink: checkSymlink,
 			})
 			if err != nil {
 				return err
 			}
 		}
 	}
+	if len(extraFiles) > 0 {
+		err := tarAddFiles(twWriter, extraFiles)
+		if err != nil {
+			return err
+		}
+	}
 	if afterWritten != nil {
 		flush()
 		err := afterWritten(twWriter)
@@ -319,6 +432,86 @@ func tarFilesAndVendors(dir string, writer io.Writer, excludeFile string, module
 	return nil
 }
 
+// excludeMatcher matches a path relative to the pack, e.g. vendor/example.com/a/a.go,
+// if itself or any of its parent dirs matches one of the patterns. A pattern
+// without `/` matches the base name, and `**` matches any number of path elements
+func excludeMatcher(patterns []string) func(relPath string) bool {
+	return func(relPath string) bool {
+		if len(patterns) == 0 {
+			return false
+		}
+		relPath = filepath.ToSlash(relPath)
+		for p := relPath; p != "." && p != "/" && p != ""; p = path.Dir(p) {
+			for _, pattern := range patterns {
+				pattern = strings.TrimSuffix(pattern, "/")
+				if !strings.Contains(pattern, "/") {
+					if ok, _ := path.Match(pattern, path.Base(p)); ok {
+						return true
+					}
+					continue
+				}
+				if matchPathElems(strings.Split(pattern, "/"), strings.Split(p, "/")) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// matchPathElems matches elems against pattern elems by path.Match, `**` matches any number of elems
+func matchPathElems(pattern []string, elems []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(elems); i++ {
+				if matchPathElems(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(elems) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], elems[0]); !ok {
+			return false
+		}
+		pattern, elems = pattern[1:], elems[1:]
+	}
+	return len(elems) == 0
+}
+
+// tarAddFiles adds files in sorted order, with missing parent dirs
+func tarAddFiles(twWriter *tarlib.Writer, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	addedDirs := make(map[string]bool)
+	for _, name := range names {
+		dirList := strings.Split(path.Dir(name), "/")
+		for i := 1; i <= len(dirList); i++ {
+			dir := path.Join(dirList[:i]...)
+			if addedDirs[dir] {
+				continue
+			}
+			addedDirs[dir] = true
+			err := tar.TarAddDir(twWriter, dir, 0755)
+			if err != nil {
+				return err
+			}
+		}
+		content := files[name]
+		err := tar.TarAddFile(twWriter, name, int64(len(content)), 0644, bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func PackVendor(dir string, pkg string, w io.Writer) error {
 	if pkg == "" {
 		return fmt.Errorf("requires pkg")
@@ -5,7 +5,13 @@ import (
 	"compress/gzip"
 	"encoding/base64"
 	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/tar"
 )
 
 // go test -run TestPack -v ./pack
@@ -40,3 +46,54 @@ func TestPackAsBase64GzipRoundTrip(t *testing.T) {
 	}
 }
 
+// go test -run TestPackExcludes -v ./pack
+func TestPackExcludes(t *testing.T) {
+	dir := t.TempDir()
+	err := exec.Command("cp", "-R", "./testdata/source/.", dir).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	extraFiles := map[string]string{
+		"README.md": "# source\n",
+		"vendor/golang.org/x/tools/cover/profile_test.go": "package cover\n",
+		"vendor/golang.org/x/tools/cover/testdata/x.txt":  "x\n",
+	}
+	for name, content := range extraFiles {
+		file := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(file, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, whitelist := range []map[string]bool{nil, {"golang.org/x/tools": true}} {
+		encoded, err := PackAsBase64(dir, &Options{
+			ModuleWhitelist: whitelist,
+			Excludes:        []string{"vendor/**/testdata", "*_test.go", "*.md"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		raw, err := base64.StdEncoding.DecodeString(string(encoded))
+		if err != nil {
+			t.Fatal(err)
+		}
+		fs, err := tar.NewTarFS(bytes.NewReader(raw))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for file := range extraFiles {
+			_, err := fs.ReadFile(file)
+			if !packfs.IsNotExists(err) {
+				t.Fatalf("expect %s = %+v, actual:%+v", file, "excluded", err)
+			}
+		}
+		_, err = fs.ReadFile("vendor/golang.org/x/tools/cover/profile.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
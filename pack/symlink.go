@@ -0,0 +1,51 @@
+package pack
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+)
+
+// escapingSymlinkChecker rejects symlinks pointing outside of their module root,
+// which is vendor/<module> for vendored files, and the packed dir for others
+func escapingSymlinkChecker(modules []*pack_model.Module) func(relPath string, target string) error {
+	var vendorRoots []string
+	for _, mod := range modules {
+		if mod.ModulePublic == nil || mod.Main || mod.Path == "" {
+			continue
+		}
+		vendorRoots = append(vendorRoots, path.Join("vendor", mod.Path))
+	}
+	return func(relPath string, target string) error {
+		relPath = path.Clean(strings.ReplaceAll(relPath, "\\", "/"))
+		if path.IsAbs(target) {
+			return fmt.Errorf("symlink %s points to absolute path: %s", relPath, target)
+		}
+		root := moduleRootOf(relPath, vendorRoots)
+		resolved := path.Join(path.Dir(relPath), target)
+		if !isSubPath(resolved, root) {
+			return fmt.Errorf("symlink %s escapes module root %q: %s", relPath, root, target)
+		}
+		return nil
+	}
+}
+
+// moduleRootOf returns the longest root containing relPath, "." if none
+func moduleRootOf(relPath string, roots []string) string {
+	root := "."
+	for _, r := range roots {
+		if isSubPath(relPath, r) && len(r) > len(root) {
+			root = r
+		}
+	}
+	return root
+}
+
+func isSubPath(p string, root string) bool {
+	if root == "." {
+		return p != ".." && !strings.HasPrefix(p, "../")
+	}
+	return p == root || strings.HasPrefix(p, root+"/")
+}
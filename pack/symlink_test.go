@@ -0,0 +1,36 @@
+package pack
+
+import (
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/go_cmd/model"
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+)
+
+// go test -run TestEscapingSymlinkChecker -v ./pack
+func TestEscapingSymlinkChecker(t *testing.T) {
+	check := escapingSymlinkChecker([]*pack_model.Module{
+		{ModulePublic: &model.ModulePublic{Path: "example.com/main", Main: true}},
+		{ModulePublic: &model.ModulePublic{Path: "example.com/a"}},
+		{ModulePublic: &model.ModulePublic{Path: "example.com/a/sub"}},
+	})
+	tests := []struct {
+		relPath string
+		target  string
+		escape  bool
+	}{
+		{"vendor/example.com/a/link", "a.go", false},
+		{"vendor/example.com/a/x/link", "../a.go", false},
+		{"vendor/example.com/a/link", "../b/b.go", true},
+		{"vendor/example.com/a/sub/link", "../a.go", true},
+		{"vendor/example.com/a/link", "/etc/passwd", true},
+		{"cmd/link", "../main.go", false},
+		{"cmd/link", "../../outside", true},
+	}
+	for _, tt := range tests {
+		err := check(tt.relPath, tt.target)
+		if (err != nil) != tt.escape {
+			t.Fatalf("expect %s -> %s escape = %+v, actual:%+v", tt.relPath, tt.target, tt.escape, err)
+		}
+	}
+}
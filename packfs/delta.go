@@ -0,0 +1,357 @@
+package packfs
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+)
+
+const FILE_DELTA_JSON = "go.delta.json"
+
+// IsDelta reports whether fs is a delta pack
+func IsDelta(fsys FS) bool {
+	_, err := fsys.ReadFile(FILE_DELTA_JSON)
+	return err == nil
+}
+
+func ReadDelta(fsys FS) (*pack_model.Delta, error) {
+	data, err := fsys.ReadFile(FILE_DELTA_JSON)
+	if err != nil {
+		return nil, err
+	}
+	var delta *pack_model.Delta
+	err = json.Unmarshal(data, &delta)
+	if err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", FILE_DELTA_JSON, err)
+	}
+	if delta == nil {
+		return nil, fmt.Errorf("empty %s", FILE_DELTA_JSON)
+	}
+	return delta, nil
+}
+
+// ApplyDelta returns base with delta applied, the base must have
+// the digest the delta is made from, and the result must
+// match the content digest recorded in the delta
+func ApplyDelta(base FS, deltaPack FS) (FS, error) {
+	delta, err := ReadDelta(deltaPack)
+	if err != nil {
+		return nil, err
+	}
+	baseDigest, err := readGoListDigest(base)
+	if err != nil {
+		return nil, err
+	}
+	if baseDigest != delta.BaseDigest {
+		return nil, fmt.Errorf("delta requires base %s, actual: %s", delta.BaseDigest, baseDigest)
+	}
+	deleted := make(map[string]bool, len(delta.Deleted))
+	for _, name := range delta.Deleted {
+		deleted[name] = true
+	}
+	result := &deltaFS{base: base, delta: deltaPack, deleted: deleted}
+	digest, err := ContentDigest(result)
+	if err != nil {
+		return nil, err
+	}
+	if digest != delta.ContentDigest {
+		return nil, fmt.Errorf("content digest mismatch after applying delta, expect: %s, actual: %s", delta.ContentDigest, digest)
+	}
+	return result, nil
+}
+
+func readGoListDigest(fsys FS) (string, error) {
+	data, err := fsys.ReadFile("go.list.json")
+	if err != nil {
+		return "", err
+	}
+	var goList struct {
+		Digest string
+	}
+	err = json.Unmarshal(data, &goList)
+	if err != nil {
+		return "", fmt.Errorf("parsing go.list.json: %w", err)
+	}
+	return goList.Digest, nil
+}
+
+// ContentDigest is a digest of names, contents, executable
+// bits and symlinks of all files in fs, which unlike GoList.Digest
+// does not depend on how the tar is compressed
+func ContentDigest(fsys FS) (string, error) {
+	h := md5.New()
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := fsys.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].Name() < entries[j].Name()
+		})
+		for _, e := range entries {
+			name := joinName(dir, e.Name())
+			if e.Type()&fs.ModeSymlink != 0 {
+				if sfs, ok := fsys.(SymlinkFS); ok {
+					link, err := sfs.Readlink(name)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(h, "l %s %s\n", name, link)
+					continue
+				}
+			}
+			if e.IsDir() {
+				fmt.Fprintf(h, "d %s\n", name)
+				err := walk(name)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			content, err := fsys.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			exec := false
+			info, err := Stat(fsys, name)
+			if err != nil {
+				return err
+			}
+			if info != nil {
+				exec = info.Mode()&0111 != 0
+			}
+			fmt.Fprintf(h, "f %s %v %d\n", name, exec, len(content))
+			h.Write(content)
+		}
+		return nil
+	}
+	err := walk(".")
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// deltaFS serves files of delta, then files of base not deleted
+type deltaFS struct {
+	base    FS
+	delta   FS
+	deleted map[string]bool
+}
+
+var _ StatFS = (*deltaFS)(nil)
+var _ SymlinkFS = (*deltaFS)(nil)
+
+func (c *deltaFS) isDeleted(name string) bool {
+	name = path.Clean(name)
+	for name != "." && name != "/" && name != "" {
+		if c.deleted[name] {
+			return true
+		}
+		name = path.Dir(name)
+	}
+	return false
+}
+
+func (c *deltaFS) isMeta(name string) bool {
+	return path.Clean(name) == FILE_DELTA_JSON
+}
+
+// find tries delta first, then base
+func (c *deltaFS) find(name string, fn func(p FS) error) error {
+	if !c.isMeta(name) {
+		err := fn(c.delta)
+		if err == nil || !isNotExist(err) {
+			return err
+		}
+	}
+	if c.isDeleted(name) {
+		return NewError(ErrKind_NotExists, fmt.Errorf("no such file: %v", name))
+	}
+	return fn(c.base)
+}
+
+func (c *deltaFS) ReadFile(file string) ([]byte, error) {
+	var data []byte
+	err := c.find(file, func(p FS) error {
+		var err error
+		data, err = p.ReadFile(file)
+		return err
+	})
+	return data, err
+}
+
+func (c *deltaFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	found := false
+	list, err := c.delta.ReadDir(name)
+	if err != nil && !isNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		found = true
+		for _, e := range list {
+			if c.isMeta(joinName(name, e.Name())) {
+				continue
+			}
+			seen[e.Name()] = true
+			entries = append(entries, e)
+		}
+	}
+	if !c.isDeleted(name) {
+		list, err := c.base.ReadDir(name)
+		if err != nil && !isNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			found = true
+			for _, e := range list {
+				if seen[e.Name()] || c.deleted[joinName(name, e.Name())] {
+					continue
+				}
+				entries = append(entries, e)
+			}
+		}
+	}
+	if !found {
+		return nil, NewError(ErrKind_NotExists, fmt.Errorf("no such directory: %v", name))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Stat returns nil info if the serving fs does not implement StatFS
+func (c *deltaFS) Stat(name string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := c.find(name, func(p FS) error {
+		// fs returning nil info may still not have the file
+		_, err := p.ReadDir(name)
+		if err != nil {
+			_, err = p.ReadFile(name)
+		}
+		if err != nil {
+			return err
+		}
+		info, err = Stat(p, name)
+		return err
+	})
+	return info, err
+}
+
+func (c *deltaFS) Readlink(name string) (string, error) {
+	var link string
+	err := c.find(name, func(p FS) error {
+		sfs, ok := p.(SymlinkFS)
+		if !ok {
+			return NewError(ErrKind_NotExists, fmt.Errorf("no such file: %v", name))
+		}
+		var err error
+		link, err = sfs.Readlink(name)
+		return err
+	})
+	return link, err
+}
+
+// DeltaNames compares base and target, returns names added or changed
+// in target, and names deleted from base
+func DeltaNames(base FS, target FS) (changed []string, deleted []string, err error) {
+	baseEntries, err := listEntries(base)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetEntries, err := listEntries(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, sig := range targetEntries {
+		baseSig, ok := baseEntries[name]
+		if ok && baseSig == sig {
+			continue
+		}
+		changed = append(changed, name)
+		// type changed, e.g. file to dir, so the base one must be hidden
+		if ok && baseSig[0] != sig[0] {
+			deleted = append(deleted, name)
+		}
+	}
+	for name := range baseEntries {
+		if _, ok := targetEntries[name]; ok {
+			continue
+		}
+		// children of a deleted dir are implied
+		if parent := path.Dir(name); parent != "." {
+			if _, ok := targetEntries[parent]; !ok {
+				if _, ok := baseEntries[parent]; ok {
+					continue
+				}
+			}
+		}
+		deleted = append(deleted, name)
+	}
+	sort.Strings(changed)
+	sort.Strings(deleted)
+	return changed, deleted, nil
+}
+
+// listEntries maps each name to a signature of its type and content
+func listEntries(fsys FS) (map[string]string, error) {
+	entries := make(map[string]string)
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		list, err := fsys.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		for _, e := range list {
+			name := joinName(dir, e.Name())
+			if e.Type()&fs.ModeSymlink != 0 {
+				if sfs, ok := fsys.(SymlinkFS); ok {
+					link, err := sfs.Readlink(name)
+					if err != nil {
+						return err
+					}
+					entries[name] = "l " + link
+					continue
+				}
+			}
+			if e.IsDir() {
+				entries[name] = "d"
+				err := walk(name)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			content, err := fsys.ReadFile(name)
+			if err != nil {
+				return err
+			}
+			exec := false
+			info, err := Stat(fsys, name)
+			if err != nil {
+				return err
+			}
+			if info != nil {
+				exec = info.Mode()&0111 != 0
+			}
+			h := md5.Sum(content)
+			entries[name] = fmt.Sprintf("f %v %s", exec, hex.EncodeToString(h[:]))
+		}
+		return nil
+	}
+	err := walk(".")
+	if err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -28,6 +28,29 @@ type FS interface {
 	ReadDir(name string) ([]fs.DirEntry, error)
 }
 
+// StatFS is implemented by FS that knows modes and
+// modification times of files, e.g. tarball
+type StatFS interface {
+	FS
+	Stat(name string) (fs.FileInfo, error)
+}
+
+// Stat returns nil info if fs does not implement StatFS
+func Stat(fs FS, name string) (fs.FileInfo, error) {
+	sfs, ok := fs.(StatFS)
+	if !ok {
+		return nil, nil
+	}
+	return sfs.Stat(name)
+}
+
+// SymlinkFS is implemented by FS that keeps symlinks, e.g. tarball.
+// ReadFile, ReadDir and Stat follow symlinks.
+type SymlinkFS interface {
+	FS
+	Readlink(name string) (string, error)
+}
+
 func IsNotExists(err error) bool {
 	if fsErr, ok := err.(*Error); ok {
 		return fsErr.Kind == ErrKind_NotExists
@@ -0,0 +1,144 @@
+// Package packtest builds small packs for tests
+package packtest
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/fs"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/go_cmd/model"
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	vtar "github.com/xhd2015/go-vendor-pack/tar"
+)
+
+// Pack describes the content of a pack, go.list.json, go.mod.versions
+// and go.sum are generated from Modules unless given in Files
+type Pack struct {
+	Digest      string
+	PackTimeUTC string
+
+	Modules  map[string]string   // module -> version
+	Packages map[string][]string // module -> import paths, listed in go.list.json
+	// Sums are go.sum lines, default to a fake
+	// `MODULE VERSION h1:MODULE@VERSION` per module
+	Sums []string
+
+	Files    map[string]string // e.g. vendor/example.com/a/a.go
+	Symlinks map[string]string // name -> target, e.g. vendor/example.com/a/static -> assets
+
+	Modes   map[string]fs.FileMode // name -> mode, default to 0644
+	ModTime time.Time              // mtime of all files, default to none
+	Omit    []string               // generated files left out, e.g. go.list.json
+}
+
+// New returns the pack as a packfs.FS
+func New(t testing.TB, p *Pack) packfs.FS {
+	t.Helper()
+	fs, err := vtar.NewTarFS(bytes.NewReader(Tar(t, p)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// Decode returns the base64 encoded pack, as written by pack.PackAsBase64, as a packfs.FS
+func Decode(t testing.TB, data []byte) packfs.FS {
+	t.Helper()
+	fs, err := vtar.NewTarFS(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// Tar returns the gzipped tar of the pack, entries are sorted by name
+func Tar(t testing.TB, p *Pack) []byte {
+	t.Helper()
+	mods := make([]string, 0, len(p.Modules))
+	for mod := range p.Modules {
+		mods = append(mods, mod)
+	}
+	sort.Strings(mods)
+
+	goList := &pack_model.GoList{Digest: p.Digest, PackTimeUTC: p.PackTimeUTC}
+	var versions []string
+	sums := p.Sums
+	for _, mod := range mods {
+		version := p.Modules[mod]
+		versions = append(versions, mod+" "+version)
+		if p.Sums == nil {
+			sums = append(sums, mod+" "+version+" h1:"+mod+"@"+version)
+		}
+		m := &pack_model.Module{ModulePublic: &model.ModulePublic{Path: mod, Version: version}}
+		for _, pkg := range p.Packages[mod] {
+			m.Packages = append(m.Packages, &model.PackagePublic{ImportPath: pkg})
+		}
+		goList.Modules = append(goList.Modules, m)
+	}
+	goListJSON, err := json.Marshal(goList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"go.list.json":    string(goListJSON),
+		"go.mod.versions": strings.Join(versions, "\n"),
+		"go.sum":          strings.Join(sums, "\n"),
+	}
+	for _, name := range p.Omit {
+		delete(files, name)
+	}
+	for name, content := range p.Files {
+		files[name] = content
+	}
+	names := make([]string, 0, len(files)+len(p.Symlinks))
+	for name := range files {
+		names = append(names, name)
+	}
+	for name := range p.Symlinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw, _, closeTw := vtar.WrapTarWriter(&buf)
+	for _, name := range names {
+		if target, ok := p.Symlinks[name]; ok {
+			err := vtar.TarAddSymlink(tw, name, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		content := files[name]
+		mode, ok := p.Modes[name]
+		if !ok {
+			mode = 0644
+			if name == "go.list.json" {
+				// same as pack.PackAsBase64
+				mode = 0755
+			}
+		}
+		err := vtar.TarAdd(tw, &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     int64(mode),
+			Size:     int64(len(content)),
+			ModTime:  p.ModTime,
+		}, strings.NewReader(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = closeTw()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,78 @@
+package packfs
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// Sub returns the FS rooted at dir, like fs.Sub
+func Sub(fsys FS, dir string) (FS, error) {
+	dir = path.Clean(dir)
+	if dir == "." || dir == "" {
+		return fsys, nil
+	}
+	if path.IsAbs(dir) || dir == ".." || strings.HasPrefix(dir, "../") {
+		return nil, fmt.Errorf("invalid sub dir: %s", dir)
+	}
+	_, err := fsys.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &subFS{fsys: fsys, dir: dir}, nil
+}
+
+type subFS struct {
+	fsys FS
+	dir  string
+}
+
+var _ StatFS = (*subFS)(nil)
+var _ SymlinkFS = (*subFS)(nil)
+
+// fullName does not allow names escaping dir
+func (c *subFS) fullName(name string) (string, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", NewError(ErrKind_NotExists, fmt.Errorf("no such file: %v", name))
+	}
+	return path.Join(c.dir, clean), nil
+}
+
+func (c *subFS) ReadFile(file string) ([]byte, error) {
+	full, err := c.fullName(file)
+	if err != nil {
+		return nil, err
+	}
+	return c.fsys.ReadFile(full)
+}
+
+func (c *subFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	full, err := c.fullName(name)
+	if err != nil {
+		return nil, err
+	}
+	return c.fsys.ReadDir(full)
+}
+
+// Stat returns nil info if the underlying fs does not implement StatFS
+func (c *subFS) Stat(name string) (fs.FileInfo, error) {
+	full, err := c.fullName(name)
+	if err != nil {
+		return nil, err
+	}
+	return Stat(c.fsys, full)
+}
+
+func (c *subFS) Readlink(name string) (string, error) {
+	full, err := c.fullName(name)
+	if err != nil {
+		return "", err
+	}
+	sfs, ok := c.fsys.(SymlinkFS)
+	if !ok {
+		return "", fmt.Errorf("not a symlink: %v", name)
+	}
+	return sfs.Readlink(full)
+}
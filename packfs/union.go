@@ -0,0 +1,560 @@
+package packfs
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/go_cmd/model"
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+	"golang.org/x/mod/semver"
+)
+
+// UnionConflict reports a module found in more
+// than one pack with different versions
+type UnionConflict struct {
+	Module   string
+	Versions []string // version in each pack, empty if absent
+	Chosen   string
+	Pack     int // index of the chosen pack
+	Reason   string
+	// MissingPackages are packages vendored by packs with other
+	// versions, but not by packs with the chosen version
+	MissingPackages []string
+}
+
+func (c *UnionConflict) String() string {
+	s := fmt.Sprintf("%s: %s, chosen %s from pack %d (%s)", c.Module, strings.Join(c.Versions, ","), c.Chosen, c.Pack, c.Reason)
+	if len(c.MissingPackages) > 0 {
+		s += ", missing packages: " + strings.Join(c.MissingPackages, ",")
+	}
+	return s
+}
+
+// UnionStrategy decides which pack wins a module found in several packs
+type UnionStrategy string
+
+const (
+	UnionStrategy_Highest UnionStrategy = "highest" // the highest version wins, the default
+	UnionStrategy_First   UnionStrategy = "first"   // the first pack having the module wins
+	UnionStrategy_Fail    UnionStrategy = "fail"    // fail if versions differ
+)
+
+func ParseUnionStrategy(s string) (UnionStrategy, error) {
+	strategy := UnionStrategy(s)
+	switch strategy {
+	case "":
+		return UnionStrategy_Highest, nil
+	case UnionStrategy_Highest, UnionStrategy_First, UnionStrategy_Fail:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("unknown union strategy: %s, available: highest, first, fail", s)
+}
+
+type UnionOptions struct {
+	Strategy UnionStrategy
+}
+
+// UnionFS presents several packs as one, see Union
+type UnionFS struct {
+	packs    []FS
+	strategy UnionStrategy
+
+	versions  map[string]string // module -> chosen version
+	winners   map[string]int    // module -> index of the pack
+	owners    map[string][]int  // module -> packs having the chosen version, winner first
+	modules   []string          // sorted by length desc, for owner lookup
+	conflicts []*UnionConflict
+
+	// merged metadata files
+	files map[string][]byte
+}
+
+var _ StatFS = (*UnionFS)(nil)
+var _ SymlinkFS = (*UnionFS)(nil)
+
+// Union merges packs, earlier packs take precedence except for modules:
+// the highest version of a module wins, and vendor/<module> is merged
+// from the packs having that version, see UnionWithOptions for other
+// strategies. go.list.json, go.sum, go.mod.versions and go.mod.whitelist
+// are merged.
+func Union(packs ...FS) (*UnionFS, error) {
+	return UnionWithOptions(nil, packs...)
+}
+
+func UnionWithOptions(opts *UnionOptions, packs ...FS) (*UnionFS, error) {
+	if len(packs) == 0 {
+		return nil, fmt.Errorf("union requires at least 1 pack")
+	}
+	strategy := UnionStrategy_Highest
+	if opts != nil && opts.Strategy != "" {
+		strategy = opts.Strategy
+	}
+	c := &UnionFS{
+		packs:    packs,
+		strategy: strategy,
+		versions: make(map[string]string),
+		winners:  make(map[string]int),
+		owners:   make(map[string][]int),
+		files:    make(map[string][]byte),
+	}
+	err := c.mergeVersions()
+	if err != nil {
+		return nil, err
+	}
+	err = c.mergeSums()
+	if err != nil {
+		return nil, err
+	}
+	err = c.mergeWhitelist()
+	if err != nil {
+		return nil, err
+	}
+	err = c.mergeGoList()
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Conflicts returns modules packs disagree on, sorted by module
+func (c *UnionFS) Conflicts() []*UnionConflict {
+	return c.conflicts
+}
+
+// Versions returns the chosen version of each module
+func (c *UnionFS) Versions() map[string]string {
+	return c.versions
+}
+
+func (c *UnionFS) readOptional(fsys FS, name string) ([]byte, bool, error) {
+	data, err := fsys.ReadFile(name)
+	if err != nil {
+		if isNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return data, true, nil
+}
+
+func (c *UnionFS) mergeVersions() error {
+	perPack := make([]map[string]string, len(c.packs))
+	for i, p := range c.packs {
+		data, _, err := c.readOptional(p, "go.mod.versions")
+		if err != nil {
+			return err
+		}
+		perPack[i] = parseLines(string(data))
+		for mod, version := range perPack[i] {
+			prev, ok := c.versions[mod]
+			higher := version != "" && (prev == "" || semver.Compare(version, prev) > 0)
+			if !ok || (c.strategy != UnionStrategy_First && higher) {
+				c.versions[mod] = version
+				c.winners[mod] = i
+			}
+		}
+	}
+	for mod, version := range c.versions {
+		c.modules = append(c.modules, mod)
+		// the winner is the first pack having the chosen version
+		for i := range c.packs {
+			if v, ok := perPack[i][mod]; ok && v == version {
+				c.owners[mod] = append(c.owners[mod], i)
+			}
+		}
+	}
+	sort.Strings(c.modules)
+
+	var lines []string
+	for _, mod := range c.modules {
+		lines = append(lines, mod+" "+c.versions[mod])
+		versions := make([]string, len(c.packs))
+		differ := false
+		for i := range c.packs {
+			v, ok := perPack[i][mod]
+			if !ok {
+				continue
+			}
+			versions[i] = v
+			if v != c.versions[mod] {
+				differ = true
+			}
+		}
+		if differ {
+			reason := "highest version"
+			if c.strategy == UnionStrategy_First {
+				reason = "first pack"
+			}
+			c.conflicts = append(c.conflicts, &UnionConflict{
+				Module:   mod,
+				Versions: versions,
+				Chosen:   c.versions[mod],
+				Pack:     c.winners[mod],
+				Reason:   reason,
+			})
+		}
+	}
+	if c.strategy == UnionStrategy_Fail && len(c.conflicts) > 0 {
+		list := make([]string, 0, len(c.conflicts))
+		for _, conflict := range c.conflicts {
+			list = append(list, conflict.Module+" "+strings.Join(conflict.Versions, ","))
+		}
+		return fmt.Errorf("modules with different versions: %s", strings.Join(list, "; "))
+	}
+	c.files["go.mod.versions"] = []byte(strings.Join(lines, "\n"))
+
+	// longer first, so nested modules own their files
+	sort.SliceStable(c.modules, func(i, j int) bool {
+		return len(c.modules[i]) > len(c.modules[j])
+	})
+	return nil
+}
+
+func (c *UnionFS) mergeSums() error {
+	seen := make(map[string]string) // "module version" -> hash
+	var lines []string
+	for _, p := range c.packs {
+		data, _, err := c.readOptional(p, "go.sum")
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(data), "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			key := fields[0] + " " + fields[1]
+			hash, ok := seen[key]
+			if ok {
+				if hash != fields[2] {
+					return fmt.Errorf("go.sum mismatch for %s: %s vs %s", key, hash, fields[2])
+				}
+				continue
+			}
+			seen[key] = fields[2]
+			lines = append(lines, strings.Join(fields, " "))
+		}
+	}
+	sort.Strings(lines)
+	c.files["go.sum"] = []byte(strings.Join(lines, "\n"))
+	return nil
+}
+
+// mergeWhitelist unions whitelists, a pack without
+// whitelist allows all its modules
+func (c *UnionFS) mergeWhitelist() error {
+	whitelist := make(map[string]bool)
+	found := false
+	for i, p := range c.packs {
+		data, ok, err := c.readOptional(p, "go.mod.whitelist")
+		if err != nil {
+			return err
+		}
+		found = found || ok
+		if !ok || strings.TrimSpace(string(data)) == "" {
+			for mod, winner := range c.winners {
+				if winner == i {
+					whitelist[mod] = true
+				}
+			}
+			continue
+		}
+		for mod := range parseLines(string(data)) {
+			whitelist[mod] = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	list := make([]string, 0, len(whitelist))
+	for mod := range whitelist {
+		list = append(list, mod)
+	}
+	sort.Strings(list)
+	c.files["go.mod.whitelist"] = []byte(strings.Join(list, "\n"))
+	return nil
+}
+
+func (c *UnionFS) mergeGoList() error {
+	merged := &pack_model.GoList{}
+	modules := make(map[string]*pack_model.Module)
+	otherPackages := make(map[string][]string) // module -> packages of other versions
+	var digests []string
+	found := false
+	for i, p := range c.packs {
+		data, ok, err := c.readOptional(p, "go.list.json")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		found = true
+		var goList *pack_model.GoList
+		err = json.Unmarshal(data, &goList)
+		if err != nil {
+			return fmt.Errorf("parsing go.list.json of pack %d: %w", i, err)
+		}
+		if goList == nil {
+			continue
+		}
+		digests = append(digests, goList.Digest)
+		if goList.PackTimeUTC > merged.PackTimeUTC {
+			merged.PackTimeUTC = goList.PackTimeUTC
+		}
+		if merged.GoMod == nil {
+			merged.GoMod = goList.GoMod
+		}
+		for _, mod := range goList.Modules {
+			if mod == nil || mod.ModulePublic == nil {
+				continue
+			}
+			owners, ok := c.owners[mod.Path]
+			if !ok {
+				if modules[mod.Path] == nil {
+					modules[mod.Path] = mod
+				}
+				continue
+			}
+			if !containsPack(owners, i) {
+				for _, pkg := range mod.Packages {
+					if pkg != nil {
+						otherPackages[mod.Path] = append(otherPackages[mod.Path], pkg.ImportPath)
+					}
+				}
+				continue
+			}
+			prev := modules[mod.Path]
+			if prev == nil {
+				modules[mod.Path] = mod
+				continue
+			}
+			// same version, vendor/<module> is merged too
+			merged := *prev
+			merged.Packages = mergePackages(prev.Packages, mod.Packages)
+			modules[mod.Path] = &merged
+		}
+	}
+	if !found {
+		return nil
+	}
+	for _, conflict := range c.conflicts {
+		have := make(map[string]bool)
+		if mod := modules[conflict.Module]; mod != nil {
+			for _, pkg := range mod.Packages {
+				if pkg != nil {
+					have[pkg.ImportPath] = true
+				}
+			}
+		}
+		for _, pkg := range otherPackages[conflict.Module] {
+			if !have[pkg] {
+				have[pkg] = true
+				conflict.MissingPackages = append(conflict.MissingPackages, pkg)
+			}
+		}
+		sort.Strings(conflict.MissingPackages)
+	}
+	h := md5.New()
+	h.Write([]byte(strings.Join(digests, "\n")))
+	merged.Digest = "union:" + hex.EncodeToString(h.Sum(nil))
+	for _, mod := range modules {
+		merged.Modules = append(merged.Modules, mod)
+	}
+	sort.Slice(merged.Modules, func(i, j int) bool {
+		return merged.Modules[i].Path < merged.Modules[j].Path
+	})
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return err
+	}
+	c.files["go.list.json"] = data
+	return nil
+}
+
+func mergePackages(a []*model.PackagePublic, b []*model.PackagePublic) []*model.PackagePublic {
+	merged := append([]*model.PackagePublic(nil), a...)
+	seen := make(map[string]bool, len(a))
+	for _, pkg := range a {
+		if pkg != nil {
+			seen[pkg.ImportPath] = true
+		}
+	}
+	for _, pkg := range b {
+		if pkg != nil && !seen[pkg.ImportPath] {
+			seen[pkg.ImportPath] = true
+			merged = append(merged, pkg)
+		}
+	}
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i] != nil && (merged[j] == nil || merged[i].ImportPath < merged[j].ImportPath)
+	})
+	return merged
+}
+
+func containsPack(packs []int, i int) bool {
+	for _, p := range packs {
+		if p == i {
+			return true
+		}
+	}
+	return false
+}
+
+// ownersOf returns the packs serving name, nil if name
+// does not belong to a module
+func (c *UnionFS) ownersOf(name string) []int {
+	name = strings.TrimPrefix(name, "./")
+	if !strings.HasPrefix(name, "vendor/") {
+		return nil
+	}
+	rel := strings.TrimPrefix(name, "vendor/")
+	for _, mod := range c.modules {
+		if rel == mod || strings.HasPrefix(rel, mod+"/") {
+			return c.owners[mod]
+		}
+	}
+	return nil
+}
+
+// serves reports whether pack i serves name
+func (c *UnionFS) serves(name string, i int) bool {
+	owners := c.ownersOf(name)
+	return owners == nil || containsPack(owners, i)
+}
+
+// find returns the first serving pack that fn succeeds with
+func (c *UnionFS) find(name string, fn func(p FS) error) error {
+	var firstErr error
+	for i, p := range c.packs {
+		if !c.serves(name, i) {
+			continue
+		}
+		err := fn(p)
+		if err == nil {
+			return nil
+		}
+		if !isNotExist(err) {
+			return err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (c *UnionFS) ReadFile(file string) ([]byte, error) {
+	if data, ok := c.files[strings.TrimPrefix(file, "./")]; ok {
+		return data, nil
+	}
+	var data []byte
+	err := c.find(file, func(p FS) error {
+		var err error
+		data, err = p.ReadFile(file)
+		return err
+	})
+	return data, err
+}
+
+// ReadDir merges entries of all packs, an entry of a
+// module comes from packs having the chosen version only
+func (c *UnionFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	var entries []fs.DirEntry
+	seen := make(map[string]bool)
+	var firstErr error
+	found := false
+	for i, p := range c.packs {
+		if !c.serves(name, i) {
+			continue
+		}
+		list, err := p.ReadDir(name)
+		if err != nil {
+			if !isNotExist(err) {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		found = true
+		for _, e := range list {
+			if seen[e.Name()] {
+				continue
+			}
+			if !c.serves(joinName(name, e.Name()), i) {
+				continue
+			}
+			seen[e.Name()] = true
+			entries = append(entries, e)
+		}
+	}
+	if !found {
+		return nil, firstErr
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+// Stat returns nil info if the serving pack does not implement StatFS
+func (c *UnionFS) Stat(name string) (fs.FileInfo, error) {
+	var info fs.FileInfo
+	err := c.find(name, func(p FS) error {
+		var err error
+		info, err = Stat(p, name)
+		return err
+	})
+	return info, err
+}
+
+func (c *UnionFS) Readlink(name string) (string, error) {
+	var link string
+	err := c.find(name, func(p FS) error {
+		sfs, ok := p.(SymlinkFS)
+		if !ok {
+			return NewError(ErrKind_NotExists, fmt.Errorf("no such file: %v", name))
+		}
+		var err error
+		link, err = sfs.Readlink(name)
+		return err
+	})
+	return link, err
+}
+
+func joinName(dir string, name string) string {
+	dir = strings.TrimSuffix(strings.TrimPrefix(dir, "./"), "/")
+	if dir == "" || dir == "." {
+		return name
+	}
+	return dir + "/" + name
+}
+
+// parseLines parses `key value` lines
+func parseLines(s string) map[string]string {
+	m := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		sp := strings.SplitN(strings.TrimSpace(line), " ", 2)
+		if sp[0] == "" {
+			continue
+		}
+		var value string
+		if len(sp) == 2 {
+			value = strings.TrimSpace(sp[1])
+		}
+		m[sp[0]] = value
+	}
+	return m
+}
+
+func isNotExist(err error) bool {
+	return IsNotExists(err) || errors.Is(err, fs.ErrNotExist)
+}
@@ -0,0 +1,192 @@
+package packfs_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+)
+
+// go test -run TestUnion -v ./packfs
+func TestUnion(t *testing.T) {
+	base := packtest.New(t, &packtest.Pack{
+		Digest: "base",
+		Modules: map[string]string{
+			"example.com/a": "v1.0.0",
+			"example.com/b": "v1.2.0",
+		},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go": "package a // v1.0.0",
+			"vendor/example.com/b/b.go": "package b // v1.2.0",
+		},
+	})
+	feature := packtest.New(t, &packtest.Pack{
+		Digest: "feature",
+		Modules: map[string]string{
+			"example.com/a": "v1.1.0",
+			"example.com/b": "v1.1.0",
+			"example.com/c": "v0.1.0",
+		},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go":     "package a // v1.1.0",
+			"vendor/example.com/a/extra.go": "package a",
+			"vendor/example.com/b/b.go":     "package b // v1.1.0",
+			"vendor/example.com/c/c.go":     "package c",
+		},
+	})
+	u, err := packfs.Union(base, feature)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	versions, err := u.ReadFile("go.mod.versions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectVersions := "example.com/a v1.1.0\nexample.com/b v1.2.0\nexample.com/c v0.1.0"
+	if string(versions) != expectVersions {
+		t.Fatalf("expect %s = %+v, actual:%+v", "go.mod.versions", expectVersions, string(versions))
+	}
+
+	for file, expect := range map[string]string{
+		"vendor/example.com/a/a.go": "package a // v1.1.0",
+		"vendor/example.com/b/b.go": "package b // v1.2.0",
+		"vendor/example.com/c/c.go": "package c",
+	} {
+		content, err := u.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expect {
+			t.Fatalf("expect %s = %+v, actual:%+v", file, expect, string(content))
+		}
+	}
+	entries, err := u.ReadDir("vendor/example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "len(entries)", 3, len(entries))
+	}
+
+	conflicts := u.Conflicts()
+	if len(conflicts) != 2 || conflicts[0].Module != "example.com/a" || conflicts[0].Pack != 1 || conflicts[1].Module != "example.com/b" || conflicts[1].Pack != 0 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "conflicts", "a from pack 1, b from pack 0", conflicts)
+	}
+
+	goListJSON, err := u.ReadFile("go.list.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var goList *pack_model.GoList
+	err = json.Unmarshal(goListJSON, &goList)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var modules []string
+	for _, mod := range goList.Modules {
+		modules = append(modules, mod.Path+"@"+mod.Version)
+	}
+	expectModules := "example.com/a@v1.1.0,example.com/b@v1.2.0,example.com/c@v0.1.0"
+	if strings.Join(modules, ",") != expectModules {
+		t.Fatalf("expect %s = %+v, actual:%+v", "modules", expectModules, modules)
+	}
+
+	sums, err := u.ReadFile("go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(strings.Split(string(sums), "\n")); n != 5 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "go.sum lines", 5, n)
+	}
+}
+
+// go test -run TestUnionSumMismatch -v ./packfs
+func TestUnionSumMismatch(t *testing.T) {
+	a := packtest.New(t, &packtest.Pack{Digest: "a", Modules: map[string]string{"example.com/a": "v1.0.0"}})
+	b := packtest.New(t, &packtest.Pack{Digest: "b", Files: map[string]string{"go.sum": "example.com/a v1.0.0 h1:other"}})
+	_, err := packfs.Union(a, b)
+	if err == nil || !strings.Contains(err.Error(), "go.sum mismatch") {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "go.sum mismatch", err)
+	}
+}
+
+// go test -run TestUnionSameVersion -v ./packfs
+func TestUnionSameVersion(t *testing.T) {
+	newPack := func(digest string, version string, pkg string) packfs.FS {
+		return packtest.New(t, &packtest.Pack{
+			Digest:   digest,
+			Modules:  map[string]string{"example.com/m": version},
+			Packages: map[string][]string{"example.com/m": {"example.com/m/" + pkg}},
+			Files: map[string]string{
+				"vendor/example.com/m/" + pkg + "/" + pkg + ".go": "package " + pkg,
+			},
+		})
+	}
+	readPackages := func(u *packfs.UnionFS) []string {
+		goListJSON, err := u.ReadFile("go.list.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var goList *pack_model.GoList
+		err = json.Unmarshal(goListJSON, &goList)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var pkgs []string
+		for _, mod := range goList.Modules {
+			for _, pkg := range mod.Packages {
+				pkgs = append(pkgs, pkg.ImportPath)
+			}
+		}
+		return pkgs
+	}
+
+	u, err := packfs.Union(newPack("a", "v1.0.0", "x"), newPack("b", "v1.0.0", "y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := u.ReadDir("vendor/example.com/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "x,y" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "entries", "x,y", names)
+	}
+	content, err := u.ReadFile("vendor/example.com/m/y/y.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "package y" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "y.go", "package y", string(content))
+	}
+	pkgs := readPackages(u)
+	if strings.Join(pkgs, ",") != "example.com/m/x,example.com/m/y" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "packages", "example.com/m/x,example.com/m/y", pkgs)
+	}
+
+	// a lower version is not merged, its packages are reported
+	u, err = packfs.Union(newPack("a", "v1.1.0", "x"), newPack("b", "v1.0.0", "y"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = u.ReadFile("vendor/example.com/m/y/y.go")
+	if !packfs.IsNotExists(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "y.go err", "not exists", err)
+	}
+	pkgs = readPackages(u)
+	if strings.Join(pkgs, ",") != "example.com/m/x" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "packages", "example.com/m/x", pkgs)
+	}
+	conflicts := u.Conflicts()
+	if len(conflicts) != 1 || strings.Join(conflicts[0].MissingPackages, ",") != "example.com/m/y" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "missing packages", "example.com/m/y", conflicts)
+	}
+}
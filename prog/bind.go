@@ -3,12 +3,133 @@ package prog
 import (
 	"flag"
 	"fmt"
+	"os"
 	"reflect"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func Bind(v interface{}) {
+// FlagInfo describes a flag bound from a struct field
+type FlagInfo struct {
+	Name     string
+	Default  string
+	Help     string
+	Type     string // string, bool, int, duration, list or map
+	Env      string // environment variable used when the flag is not set
+	Required bool
+
+	Values   []string // possible values, checked by Apply and used by completion
+	Complete string   // how values are completed: file, dir or a name in RunOptions.Completers
+}
+
+// Binding holds the flags bound from a struct
+type Binding struct {
+	fs    *flag.FlagSet
+	flags []*FlagInfo
+}
+
+// Bind binds fields of v to flag.CommandLine, see BindFlagSet
+func Bind(v interface{}) *Binding {
+	return BindFlagSet(flag.CommandLine, v)
+}
+
+// BindFlagSet binds fields of v, a pointer to struct, to fs.
+// Fields are tagged with prog, other tags are optional:
+//
+//	prog:"name default help"  a default of '' means the zero value
+//	env:"NAME"                read the environment variable if the flag is not set
+//	required:"true"           the flag must be set, either by command line or env
+//	values:"a,b"              possible values, checked by Apply and used by completion
+//	complete:"dir"            complete values as file, dir or by a completer in RunOptions.Completers
+//
+// Supported types are string, bool, int, int64, time.Duration,
+// []string and map[string]string. Slices and maps are repeatable,
+// map values are given as KEY=VALUE.
+func BindFlagSet(fs *flag.FlagSet, v interface{}) *Binding {
+	b := &Binding{fs: fs}
+	b.bind(v)
+	return b
+}
+
+// Flags returns bound flags in declaration order
+func (c *Binding) Flags() []*FlagInfo {
+	return c.flags
+}
+
+// Apply should be called after parsing, it sets unset flags
+// from their environment variables and checks required flags
+// and values of flags that are set
+func (c *Binding) Apply() error {
+	set := make(map[string]bool)
+	c.fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	for _, info := range c.flags {
+		if set[info.Name] || info.Env == "" {
+			continue
+		}
+		val := os.Getenv(info.Env)
+		if val == "" {
+			continue
+		}
+		values := []string{val}
+		if info.Type == "list" {
+			values = strings.Split(val, ",")
+		}
+		for _, e := range values {
+			err := c.fs.Set(info.Name, e)
+			if err != nil {
+				return fmt.Errorf("$%s: %v", info.Env, err)
+			}
+		}
+		set[info.Name] = true
+	}
+	for _, info := range c.flags {
+		if len(info.Values) == 0 || !set[info.Name] {
+			continue
+		}
+		err := checkValues(info, c.fs.Lookup(info.Name).Value.String())
+		if err != nil {
+			return err
+		}
+	}
+	for _, info := range c.flags {
+		if !info.Required || set[info.Name] {
+			continue
+		}
+		if info.Env != "" {
+			return fmt.Errorf("requires -%s or $%s", info.Name, info.Env)
+		}
+		return fmt.Errorf("requires -%s", info.Name)
+	}
+	return nil
+}
+
+func checkValues(info *FlagInfo, val string) error {
+	values := []string{val}
+	if info.Type == "list" {
+		values = strings.Split(val, ",")
+	}
+	for _, v := range values {
+		found := false
+		for _, e := range info.Values {
+			if v == e {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("invalid -%s %s, expecting one of: %s", info.Name, v, strings.Join(info.Values, ", "))
+		}
+	}
+	return nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+func (c *Binding) bind(v interface{}) {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr {
 		panic(fmt.Errorf("requires ptr, actual: %T", v))
@@ -18,6 +139,7 @@ func Bind(v interface{}) {
 		panic(fmt.Errorf("requires struct, actual: %T", v))
 	}
 
+	fs := c.fs
 	t := rv.Type()
 	n := rv.NumField()
 	for i := 0; i < n; i++ {
@@ -26,7 +148,7 @@ func Bind(v interface{}) {
 
 		if field.Anonymous {
 			// recursively parse
-			Bind(fieldValue.Addr().Interface())
+			c.bind(fieldValue.Addr().Interface())
 			continue
 		}
 
@@ -42,34 +164,84 @@ func Bind(v interface{}) {
 		flagName := list[0]
 		defaulVal := list[1]
 		help := list[2]
+		if defaulVal == "''" {
+			defaulVal = ""
+		}
+		info := &FlagInfo{
+			Name:     flagName,
+			Default:  defaulVal,
+			Help:     help,
+			Env:      field.Tag.Get("env"),
+			Complete: field.Tag.Get("complete"),
+		}
+		if values := field.Tag.Get("values"); values != "" {
+			info.Values = strings.Split(values, ",")
+		}
+		if required := field.Tag.Get("required"); required != "" {
+			var err error
+			info.Required, err = strconv.ParseBool(required)
+			if err != nil {
+				panic(fmt.Errorf("parsing %s: invalid required tag %s", field.Name, required))
+			}
+		}
 
 		var bad bool
-		switch fieldValue.Kind() {
-		case reflect.String:
-			if defaulVal == "''" {
-				defaulVal = ""
+		ptr := fieldValue.Addr().Interface()
+		switch {
+		case field.Type == durationType:
+			var d time.Duration
+			if defaulVal != "" {
+				var err error
+				d, err = time.ParseDuration(defaulVal)
+				if err != nil {
+					panic(fmt.Errorf("parsing %s as duration: invalid default value %s", field.Name, defaulVal))
+				}
+			}
+			info.Type = "duration"
+			fs.DurationVar(ptr.(*time.Duration), flagName, d, help)
+		case fieldValue.Kind() == reflect.String:
+			info.Type = "string"
+			fs.StringVar(ptr.(*string), flagName, defaulVal, help)
+		case fieldValue.Kind() == reflect.Bool:
+			if defaulVal == "" {
+				defaulVal = "false"
 			}
-			flag.StringVar(fieldValue.Addr().Interface().(*string), flagName, defaulVal, help)
-		case reflect.Bool:
 			v, err := strconv.ParseBool(defaulVal)
 			if err != nil {
 				panic(fmt.Errorf("parsing %s as bool: invalid default value %s", field.Name, defaulVal))
 			}
-			flag.BoolVar(fieldValue.Addr().Interface().(*bool), flagName, v, help)
-		case reflect.Slice:
-			if field.Type.Elem().Kind() == reflect.String {
-				// []string
-				plist := fieldValue.Addr().Interface().(*[]string)
-				flag.Var(strSlice{plist}, flagName, help)
+			info.Type = "bool"
+			fs.BoolVar(ptr.(*bool), flagName, v, help)
+		case fieldValue.Kind() == reflect.Int || fieldValue.Kind() == reflect.Int64:
+			var v int64
+			if defaulVal != "" {
+				var err error
+				v, err = strconv.ParseInt(defaulVal, 10, 64)
+				if err != nil {
+					panic(fmt.Errorf("parsing %s as int: invalid default value %s", field.Name, defaulVal))
+				}
+			}
+			info.Type = "int"
+			if fieldValue.Kind() == reflect.Int {
+				fs.IntVar(ptr.(*int), flagName, int(v), help)
 			} else {
-				bad = true
+				fs.Int64Var(ptr.(*int64), flagName, v, help)
 			}
+		case fieldValue.Kind() == reflect.Slice && field.Type.Elem().Kind() == reflect.String:
+			// []string
+			info.Type = "list"
+			fs.Var(strSlice{ptr.(*[]string)}, flagName, help)
+		case fieldValue.Kind() == reflect.Map && field.Type.Key().Kind() == reflect.String && field.Type.Elem().Kind() == reflect.String:
+			// map[string]string
+			info.Type = "map"
+			fs.Var(strMap{ptr.(*map[string]string)}, flagName, help)
 		default:
 			bad = true
 		}
 		if bad {
 			panic(fmt.Errorf("unsupported type: %s %s", field.Name, fieldValue.Type()))
 		}
+		c.flags = append(c.flags, info)
 	}
 }
 
@@ -78,6 +250,9 @@ type strSlice struct {
 }
 
 func (c strSlice) String() string {
+	if c.ptr == nil {
+		return ""
+	}
 	return strings.Join(*c.ptr, ",")
 }
 
@@ -85,3 +260,35 @@ func (c strSlice) Set(value string) error {
 	*c.ptr = append(*c.ptr, value)
 	return nil
 }
+
+type strMap struct {
+	ptr *map[string]string
+}
+
+func (c strMap) String() string {
+	if c.ptr == nil {
+		return ""
+	}
+	keys := make([]string, 0, len(*c.ptr))
+	for k := range *c.ptr {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	list := make([]string, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, k+"="+(*c.ptr)[k])
+	}
+	return strings.Join(list, ",")
+}
+
+func (c strMap) Set(value string) error {
+	idx := strings.Index(value, "=")
+	if idx <= 0 {
+		return fmt.Errorf("invalid %s, expecting KEY=VALUE", value)
+	}
+	if *c.ptr == nil {
+		*c.ptr = make(map[string]string)
+	}
+	(*c.ptr)[value[:idx]] = value[idx+1:]
+	return nil
+}
@@ -0,0 +1,151 @@
+package prog
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type CommonTestFlags struct {
+	Verbose bool `prog:"v false verbose"`
+}
+
+type testFlags struct {
+	CommonTestFlags
+	Name    string            `prog:"name '' name" required:"true"`
+	Count   int               `prog:"count 3 count"`
+	Size    int64             `prog:"size '' size"`
+	Timeout time.Duration     `prog:"timeout 1s timeout"`
+	Tags    []string          `prog:"tag '' tags" env:"PROG_TEST_TAGS"`
+	Labels  map[string]string `prog:"label '' labels"`
+	Dir     string            `prog:"dir '' dir" env:"PROG_TEST_DIR"`
+}
+
+// go test -run TestBindFlagSet -v ./prog
+func TestBindFlagSet(t *testing.T) {
+	os.Setenv("PROG_TEST_TAGS", "a,b")
+	os.Setenv("PROG_TEST_DIR", "from-env")
+	defer os.Unsetenv("PROG_TEST_TAGS")
+	defer os.Unsetenv("PROG_TEST_DIR")
+
+	var v testFlags
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	b := BindFlagSet(fs, &v)
+	err := fs.Parse([]string{"-v", "-name", "x", "-size", "10", "-timeout", "2m", "-label", "k1=v1", "-label", "k2=a=b", "-dir", "from-flag", "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Apply()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := testFlags{
+		CommonTestFlags: CommonTestFlags{Verbose: true},
+		Name:            "x",
+		Count:           3,
+		Size:            10,
+		Timeout:         2 * time.Minute,
+		Tags:            []string{"a", "b"},
+		Labels:          map[string]string{"k1": "v1", "k2": "a=b"},
+		Dir:             "from-flag",
+	}
+	if !reflect.DeepEqual(v, expect) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "flags", expect, v)
+	}
+	if len(b.Flags()) != 8 || b.Flags()[0].Name != "v" || b.Flags()[5].Type != "list" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "flags info", 8, b.Flags())
+	}
+}
+
+// go test -run TestBindFlagSetRequired -v ./prog
+func TestBindFlagSetRequired(t *testing.T) {
+	var v testFlags
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	b := BindFlagSet(fs, &v)
+	err := fs.Parse(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = b.Apply()
+	if err == nil || err.Error() != "requires -name" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "requires -name", err)
+	}
+
+	err = fs.Parse([]string{"-label", "novalue"})
+	if err == nil || !strings.Contains(err.Error(), "expecting KEY=VALUE") {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "expecting KEY=VALUE", err)
+	}
+}
+
+// go test -run TestBindFlagSetValues -v ./prog
+func TestBindFlagSetValues(t *testing.T) {
+	type valuesFlags struct {
+		Level  int      `prog:"level '' level" values:"1,2,3"`
+		Policy string   `prog:"policy '' policy" values:"keep,take" env:"PROG_TEST_POLICY"`
+		Tags   []string `prog:"tag '' tags" values:"a,b"`
+	}
+	tests := []struct {
+		args      []string
+		env       string
+		expectErr string
+	}{
+		{args: nil},
+		{args: []string{"-level", "2", "-policy", "take", "-tag", "a", "-tag", "b"}},
+		{args: []string{"-level", "42"}, expectErr: "invalid -level 42, expecting one of: 1, 2, 3"},
+		{args: []string{"-tag", "a", "-tag", "c"}, expectErr: "invalid -tag c, expecting one of: a, b"},
+		{env: "drop", expectErr: "invalid -policy drop, expecting one of: keep, take"},
+	}
+	for _, tt := range tests {
+		os.Setenv("PROG_TEST_POLICY", tt.env)
+		var v valuesFlags
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		b := BindFlagSet(fs, &v)
+		err := fs.Parse(tt.args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = b.Apply()
+		var errMsg string
+		if err != nil {
+			errMsg = err.Error()
+		}
+		if errMsg != tt.expectErr {
+			t.Fatalf("expect %s = %+v, actual:%+v", "err of "+strings.Join(tt.args, " "), tt.expectErr, errMsg)
+		}
+	}
+	os.Unsetenv("PROG_TEST_POLICY")
+}
+
+// go test -run TestPrintCommandHelp -v ./prog
+func TestPrintCommandHelp(t *testing.T) {
+	var v testFlags
+	cmd := &Command{
+		Name:     "run",
+		Args:     "DIR",
+		Short:    "run something",
+		Examples: []string{"prog run -name x ."},
+		Flags:    &v,
+	}
+	opts := &RunOptions{Name: "prog", Cmds: []*Command{cmd}}
+	_, bindings := newFlagSet(nil, cmd, opts)
+
+	var buf strings.Builder
+	printCommandHelp(&buf, opts, cmd, bindings)
+	help := buf.String()
+	for _, s := range []string{
+		"usage: prog run [flags] DIR\n",
+		"  -name string\n        name (required)\n",
+		"  -count int\n        count (default 3)\n",
+		"  -tag value\n        tags (repeatable, env $PROG_TEST_TAGS)\n",
+		"examples:\n  prog run -name x .\n",
+	} {
+		if !strings.Contains(help, s) {
+			t.Fatalf("expect %s = %+v, actual:%+v", "help containing", s, help)
+		}
+	}
+}
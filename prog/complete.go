@@ -0,0 +1,318 @@
+package prog
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// completeCommand is called by completion scripts with the words
+// after the program name, the last word is the one being completed
+const completeCommand = "__complete"
+
+// CompleteContext is passed to a Completer
+type CompleteContext struct {
+	Command string
+	Flags   map[string]string // flags already given, the last value for repeated flags
+	Args    []string          // args already given
+	Prefix  string            // the word being completed
+}
+
+// Completer returns candidates, they are filtered by prefix afterwards
+type Completer func(ctx *CompleteContext) []string
+
+// completionCommand handles `completion bash|zsh|fish`
+func completionCommand(args []string, opts *RunOptions) {
+	if len(args) != 1 {
+		fmt.Fprintf(os.Stderr, "requires one of: bash,zsh,fish\n")
+		os.Exit(1)
+	}
+	err := WriteCompletion(os.Stdout, args[0], opts.name())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+var nonIdent = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// WriteCompletion writes completion script of shell for the program
+// name, the script calls `name __complete WORDS...` for candidates
+func WriteCompletion(w io.Writer, shell string, name string) error {
+	fn := "_" + nonIdent.ReplaceAllString(name, "_") + "_complete"
+	var script string
+	switch shell {
+	case "bash":
+		script = `# bash completion for {{name}}, source it in ~/.bashrc:
+#   source <({{name}} completion bash)
+{{fn}}() {
+    local IFS=$'\n'
+    COMPREPLY=($({{name}} __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+    if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == */ ]]; then
+        compopt -o nospace 2>/dev/null
+    fi
+}
+complete -o default -F {{fn}} {{name}}
+`
+	case "zsh":
+		script = `#compdef {{name}}
+# zsh completion for {{name}}, source it in ~/.zshrc:
+#   source <({{name}} completion zsh)
+{{fn}}() {
+    local -a candidates
+    local c
+    candidates=("${(@f)$({{name}} __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    for c in $candidates; do
+        if [[ $c == */ ]]; then
+            compadd -Q -S '' -- "$c"
+        elif [[ -n $c ]]; then
+            compadd -Q -- "$c"
+        fi
+    done
+}
+compdef {{fn}} {{name}}
+`
+	case "fish":
+		script = `# fish completion for {{name}}, save it to ~/.config/fish/completions/{{name}}.fish
+function {{fn}}
+    set -l tokens (commandline -opc) (commandline -ct)
+    {{name}} __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c {{name}} -f -a '({{fn}})'
+`
+	default:
+		return fmt.Errorf("unsupported shell: %s, requires one of: bash,zsh,fish", shell)
+	}
+	script = strings.NewReplacer("{{name}}", name, "{{fn}}", fn).Replace(script)
+	_, err := io.WriteString(w, script)
+	return err
+}
+
+// complete prints candidates for the last word, one per line
+func complete(w io.Writer, prog interface{}, opts *RunOptions, words []string) {
+	for _, c := range completeWords(prog, opts, words) {
+		fmt.Fprintln(w, c)
+	}
+}
+
+func completeWords(prog interface{}, opts *RunOptions, words []string) []string {
+	if len(words) == 0 {
+		words = []string{""}
+	}
+	cur := words[len(words)-1]
+	if len(words) == 1 {
+		return filterPrefix(commandNames(opts), cur)
+	}
+	commd := words[0]
+
+	ctx := &CompleteContext{
+		Command: commd,
+		Flags:   make(map[string]string),
+		Prefix:  cur,
+	}
+	switch {
+	case isHelp(commd):
+		if len(words) == 2 {
+			return filterPrefix(commandNames(opts), cur)
+		}
+		return nil
+	case commd == "completion" && opts.Commands[commd] == nil:
+		if len(words) == 2 {
+			return filterPrefix([]string{"bash", "zsh", "fish"}, cur)
+		}
+		return nil
+	}
+
+	// collect flags of the command with a scratch flag set
+	var flags []*FlagInfo
+	cmd := opts.lookup(commd)
+	if cmd == nil && opts.Commands[commd] == nil {
+		return nil
+	}
+	fs := flag.NewFlagSet(commd, flag.ContinueOnError)
+	if prog != nil {
+		flags = append(flags, BindFlagSet(fs, prog).Flags()...)
+	}
+	if cmd != nil && cmd.Flags != nil {
+		flags = append(flags, BindFlagSet(fs, cmd.Flags).Flags()...)
+	}
+	lookupFlag := func(name string) *FlagInfo {
+		for _, f := range flags {
+			if f.Name == name {
+				return f
+			}
+		}
+		return nil
+	}
+
+	// walk given words, bash splits -flag=value into -flag = value
+	var valueOf *FlagInfo
+	prev := words[1 : len(words)-1]
+	for i := 0; i < len(prev); i++ {
+		word := prev[i]
+		if valueOf != nil {
+			if word == "=" {
+				continue
+			}
+			ctx.Flags[valueOf.Name] = word
+			valueOf = nil
+			continue
+		}
+		if word == "--" {
+			// extra args are not completed
+			return nil
+		}
+		if len(ctx.Args) == 0 && len(word) > 1 && word[0] == '-' {
+			name := strings.TrimLeft(word, "-")
+			if idx := strings.Index(name, "="); idx >= 0 {
+				ctx.Flags[name[:idx]] = name[idx+1:]
+				continue
+			}
+			f := lookupFlag(name)
+			if f == nil {
+				continue
+			}
+			if f.Type == "bool" {
+				ctx.Flags[name] = "true"
+				continue
+			}
+			valueOf = f
+			continue
+		}
+		ctx.Args = append(ctx.Args, word)
+	}
+
+	if valueOf != nil {
+		if cur == "=" {
+			cur = ""
+			ctx.Prefix = ""
+		}
+		return completeValue(opts, ctx, valueOf.Values, valueOf.Complete, valueOf.Type == "string")
+	}
+	if len(ctx.Args) == 0 && strings.HasPrefix(cur, "-") {
+		dashes := "-"
+		if strings.HasPrefix(cur, "--") {
+			dashes = "--"
+		}
+		name := cur[len(dashes):]
+		if idx := strings.Index(name, "="); idx >= 0 {
+			f := lookupFlag(name[:idx])
+			if f == nil {
+				return nil
+			}
+			ctx.Prefix = name[idx+1:]
+			list := completeValue(opts, ctx, f.Values, f.Complete, f.Type == "string")
+			for i, e := range list {
+				list[i] = dashes + name[:idx+1] + e
+			}
+			return list
+		}
+		var names []string
+		for _, f := range flags {
+			names = append(names, dashes+f.Name)
+		}
+		return filterPrefix(names, cur)
+	}
+	if cmd == nil {
+		return nil
+	}
+	return completeValue(opts, ctx, cmd.ArgValues, cmd.ArgComplete, false)
+}
+
+// completeValue completes ctx.Prefix by values or complete,
+// if commaList, only the part after the last comma is completed
+func completeValue(opts *RunOptions, ctx *CompleteContext, values []string, complete string, commaList bool) []string {
+	switch complete {
+	case "file":
+		return completePath(ctx.Prefix, false)
+	case "dir":
+		return completePath(ctx.Prefix, true)
+	}
+	candidates := values
+	if complete != "" && opts.Completers[complete] != nil {
+		candidates = append(append([]string(nil), values...), opts.Completers[complete](ctx)...)
+	}
+	var head string
+	prefix := ctx.Prefix
+	if commaList {
+		if idx := strings.LastIndex(prefix, ","); idx >= 0 {
+			head = prefix[:idx+1]
+			prefix = prefix[idx+1:]
+		}
+	}
+	list := filterPrefix(candidates, prefix)
+	for i, e := range list {
+		list[i] = head + e
+	}
+	return list
+}
+
+func completePath(prefix string, dirOnly bool) []string {
+	dir, base := filepath.Split(prefix)
+	readDir := dir
+	if readDir == "" {
+		readDir = "."
+	}
+	files, err := ioutil.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+	var list []string
+	for _, file := range files {
+		name := file.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		isDir := file.IsDir()
+		if !isDir && file.Mode()&os.ModeSymlink != 0 {
+			if stat, err := os.Stat(filepath.Join(readDir, name)); err == nil {
+				isDir = stat.IsDir()
+			}
+		}
+		if isDir {
+			list = append(list, dir+name+"/")
+		} else if !dirOnly {
+			list = append(list, dir+name)
+		}
+	}
+	return list
+}
+
+func commandNames(opts *RunOptions) []string {
+	var names []string
+	for _, cmd := range opts.Cmds {
+		names = append(names, cmd.Name)
+	}
+	var legacy []string
+	for commd := range opts.Commands {
+		if opts.lookup(commd) == nil {
+			legacy = append(legacy, commd)
+		}
+	}
+	sort.Strings(legacy)
+	names = append(names, legacy...)
+	for _, builtin := range []string{"completion", "help"} {
+		if opts.lookup(builtin) == nil && opts.Commands[builtin] == nil {
+			names = append(names, builtin)
+		}
+	}
+	return names
+}
+
+func filterPrefix(list []string, prefix string) []string {
+	var res []string
+	seen := make(map[string]bool, len(list))
+	for _, e := range list {
+		if strings.HasPrefix(e, prefix) && !seen[e] {
+			seen[e] = true
+			res = append(res, e)
+		}
+	}
+	return res
+}
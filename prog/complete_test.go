@@ -0,0 +1,81 @@
+package prog
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type completeTestFlags struct {
+	Input   string `prog:"input '' input file" complete:"file"`
+	Policy  string `prog:"policy '' policy" values:"keep,take,fail"`
+	Modules string `prog:"modules '' modules" complete:"module"`
+	DryRun  bool   `prog:"dry-run false dry run"`
+}
+
+// go test -run TestCompleteWords -v ./prog
+func TestCompleteWords(t *testing.T) {
+	newOpts := func() *RunOptions {
+		var v completeTestFlags
+		return &RunOptions{
+			Name: "prog",
+			Cmds: []*Command{
+				{Name: "unpack", Flags: &v, ArgComplete: "dir"},
+				{Name: "cache", ArgValues: []string{"ls", "gc", "clean"}},
+			},
+			Completers: map[string]Completer{
+				"module": func(ctx *CompleteContext) []string {
+					if ctx.Flags["input"] == "" {
+						return nil
+					}
+					return []string{"golang.org/x/mod", "golang.org/x/tools", "github.com/a/b"}
+				},
+			},
+		}
+	}
+	tests := []struct {
+		words  []string
+		expect []string
+	}{
+		{[]string{""}, []string{"unpack", "cache", "completion", "help"}},
+		{[]string{"c"}, []string{"cache", "completion"}},
+		{[]string{"help", "u"}, []string{"unpack"}},
+		{[]string{"completion", "z"}, []string{"zsh"}},
+		{[]string{"cache", "g"}, []string{"gc"}},
+		{[]string{"unpack", "-p"}, []string{"-policy"}},
+		{[]string{"unpack", "--d"}, []string{"--dry-run"}},
+		{[]string{"unpack", "-policy", "t"}, []string{"take"}},
+		{[]string{"unpack", "-policy=f"}, []string{"-policy=fail"}},
+		{[]string{"unpack", "-policy", "=", "k"}, []string{"keep"}},
+		{[]string{"unpack", "-modules", "golang"}, nil},
+		{[]string{"unpack", "-input", "x", "-dry-run", "-modules", "golang"}, []string{"golang.org/x/mod", "golang.org/x/tools"}},
+		{[]string{"unpack", "-input", "x", "-modules", "github.com/a/b,golang.org/x/t"}, []string{"github.com/a/b,golang.org/x/tools"}},
+		{[]string{"unpack", "--", ""}, nil},
+		{[]string{"unknown", ""}, nil},
+	}
+	for _, tt := range tests {
+		actual := completeWords(nil, newOpts(), tt.words)
+		if !reflect.DeepEqual(actual, tt.expect) {
+			t.Fatalf("expect %s = %+v, actual:%+v", strings.Join(tt.words, " "), tt.expect, actual)
+		}
+	}
+}
+
+// go test -run TestWriteCompletion -v ./prog
+func TestWriteCompletion(t *testing.T) {
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		var buf bytes.Buffer
+		err := WriteCompletion(&buf, shell, "go-pack")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), "go-pack __complete") || !strings.Contains(buf.String(), "_go_pack_complete") {
+			t.Fatalf("expect %s = %+v, actual:%+v", shell+" script", "calling go-pack __complete", buf.String())
+		}
+	}
+	err := WriteCompletion(&bytes.Buffer{}, "ksh", "go-pack")
+	if err == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "unsupported shell", err)
+	}
+}
@@ -0,0 +1,133 @@
+package prog
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+func printHelp(w io.Writer, opts *RunOptions, binding *Binding) {
+	name := opts.name()
+	fmt.Fprintf(w, "usage: %s <command> [flags] [args] [-- extra args]\n", name)
+
+	type entry struct {
+		name  string
+		short string
+	}
+	var entries []entry
+	for _, cmd := range opts.Cmds {
+		entries = append(entries, entry{name: cmd.Name, short: cmd.Short})
+	}
+	var legacy []string
+	for commd := range opts.Commands {
+		if opts.lookup(commd) == nil {
+			legacy = append(legacy, commd)
+		}
+	}
+	sort.Strings(legacy)
+	for _, commd := range legacy {
+		entries = append(entries, entry{name: commd})
+	}
+	if opts.lookup("completion") == nil && opts.Commands["completion"] == nil {
+		entries = append(entries, entry{name: "completion", short: "generate completion script for bash, zsh or fish"})
+	}
+	if opts.lookup("help") == nil && opts.Commands["help"] == nil {
+		entries = append(entries, entry{name: "help", short: "show help of the program or a command"})
+	}
+
+	width := 0
+	for _, e := range entries {
+		if len(e.name) > width {
+			width = len(e.name)
+		}
+	}
+	fmt.Fprintf(w, "\ncommands:\n")
+	for _, e := range entries {
+		if e.short == "" {
+			fmt.Fprintf(w, "  %s\n", e.name)
+			continue
+		}
+		fmt.Fprintf(w, "  %-*s  %s\n", width, e.name, e.short)
+	}
+	if binding != nil && len(binding.Flags()) > 0 {
+		fmt.Fprintf(w, "\nflags:\n")
+		printFlags(w, binding.Flags())
+	}
+	if len(opts.Cmds) > 0 {
+		fmt.Fprintf(w, "\nrun '%s help <command>' for flags of a command.\n", name)
+	}
+	printExamples(w, opts.Examples)
+}
+
+func printCommandHelp(w io.Writer, opts *RunOptions, cmd *Command, bindings []*Binding) {
+	var flags []*FlagInfo
+	for _, binding := range bindings {
+		flags = append(flags, binding.Flags()...)
+	}
+	usage := opts.name() + " " + cmd.Name
+	if len(flags) > 0 {
+		usage += " [flags]"
+	}
+	if cmd.Args != "" {
+		usage += " " + cmd.Args
+	}
+	fmt.Fprintf(w, "usage: %s\n", usage)
+	desc := cmd.Long
+	if desc == "" {
+		desc = cmd.Short
+	}
+	if desc != "" {
+		fmt.Fprintf(w, "\n%s\n", strings.TrimSpace(desc))
+	}
+	if len(flags) > 0 {
+		fmt.Fprintf(w, "\nflags:\n")
+		printFlags(w, flags)
+	}
+	printExamples(w, cmd.Examples)
+}
+
+func printFlags(w io.Writer, flags []*FlagInfo) {
+	for _, f := range flags {
+		line := "  -" + f.Name
+		switch f.Type {
+		case "bool":
+		case "list":
+			line += " value"
+		case "map":
+			line += " key=value"
+		default:
+			line += " " + f.Type
+		}
+		fmt.Fprintln(w, line)
+
+		help := f.Help
+		var notes []string
+		if f.Required {
+			notes = append(notes, "required")
+		}
+		if f.Type == "list" || f.Type == "map" {
+			notes = append(notes, "repeatable")
+		}
+		if f.Default != "" && !(f.Type == "bool" && f.Default == "false") {
+			notes = append(notes, "default "+f.Default)
+		}
+		if f.Env != "" {
+			notes = append(notes, "env $"+f.Env)
+		}
+		if len(notes) > 0 {
+			help += " (" + strings.Join(notes, ", ") + ")"
+		}
+		fmt.Fprintf(w, "        %s\n", help)
+	}
+}
+
+func printExamples(w io.Writer, examples []string) {
+	if len(examples) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nexamples:\n")
+	for _, example := range examples {
+		fmt.Fprintf(w, "  %s\n", example)
+	}
+}
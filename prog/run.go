@@ -4,19 +4,50 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 type RunOptions struct {
+	Name string // program name shown in help, default to base name of os.Args[0]
+
 	Usage func(usage func()) func()
 
 	AfterFlagParse func()
 
+	// Cmds are commands with their own flags and help,
+	// looked up before Commands
+	Cmds     []*Command
+	Examples []string // shown in help
+
 	Commands map[string]func(commd string, args []string, extraArgs []string)
 	Default  func(commd string, args []string, extraArgs []string) // default command
+
+	// Completers complete flag values and args by name,
+	// referenced by the complete tag or Command.ArgComplete
+	Completers map[string]Completer
+}
+
+// Command is a command with its own flag set
+type Command struct {
+	Name     string
+	Args     string // args shown in usage, e.g. DIR
+	Short    string // one line description
+	Long     string
+	Examples []string
+
+	// Flags is a pointer to struct bound by BindFlagSet, can be nil
+	Flags interface{}
+
+	// ArgValues and ArgComplete complete args,
+	// ArgComplete is file, dir or a name in RunOptions.Completers
+	ArgValues   []string
+	ArgComplete string
+
+	// Run is called with the parsed flag set of the command
+	Run func(commd string, fs *flag.FlagSet, args []string, extraArgs []string)
 }
 
 func Run(prog interface{}, opts *RunOptions) {
-	Bind(prog)
 	if opts == nil {
 		opts = &RunOptions{}
 	}
@@ -43,7 +74,28 @@ func Run(prog interface{}, opts *RunOptions) {
 		}
 	}
 
+	if cmd := opts.lookup(commd); cmd != nil {
+		runCommand(prog, cmd, args, extraArgs, opts)
+		return
+	}
+	if commd == completeCommand {
+		// words are passed as is, including --
+		complete(os.Stdout, prog, opts, os.Args[2:])
+		return
+	}
+	if commd == "completion" && opts.Commands[commd] == nil {
+		completionCommand(args, opts)
+		return
+	}
+
+	var binding *Binding
+	if prog != nil {
+		binding = Bind(prog)
+	}
 	os.Args = append([]string{arg0}, args...)
+	flag.Usage = func() {
+		printHelp(flag.CommandLine.Output(), opts, binding)
+	}
 	flag.Parse()
 	args = flag.Args()
 
@@ -51,12 +103,25 @@ func Run(prog interface{}, opts *RunOptions) {
 	if opts.Usage != nil {
 		flag.Usage = opts.Usage(flag.Usage)
 	}
+	if binding != nil {
+		err := binding.Apply()
+		if err != nil {
+			fmt.Fprintf(flag.CommandLine.Output(), "%v\n", err)
+			flag.Usage()
+			os.Exit(2)
+		}
+	}
 
 	if opts.AfterFlagParse != nil {
 		opts.AfterFlagParse()
 	}
 
 	handler := opts.Commands[commd]
+	if handler == nil && isHelp(commd) {
+		handler = func(commd string, args []string, extraArgs []string) {
+			helpCommand(prog, args, opts, binding)
+		}
+	}
 	if handler == nil {
 		handler = opts.Default
 	}
@@ -65,3 +130,78 @@ func Run(prog interface{}, opts *RunOptions) {
 	}
 	handler(commd, args, extraArgs)
 }
+
+func runCommand(prog interface{}, cmd *Command, args []string, extraArgs []string, opts *RunOptions) {
+	fs, bindings := newFlagSet(prog, cmd, opts)
+	fs.Usage = func() {
+		printCommandHelp(fs.Output(), opts, cmd, bindings)
+	}
+	err := fs.Parse(args)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	}
+	if err != nil {
+		os.Exit(2)
+	}
+	for _, binding := range bindings {
+		err := binding.Apply()
+		if err != nil {
+			fmt.Fprintf(fs.Output(), "%v\n", err)
+			fs.Usage()
+			os.Exit(2)
+		}
+	}
+	if opts.AfterFlagParse != nil {
+		opts.AfterFlagParse()
+	}
+	cmd.Run(cmd.Name, fs, fs.Args(), extraArgs)
+}
+
+func newFlagSet(prog interface{}, cmd *Command, opts *RunOptions) (*flag.FlagSet, []*Binding) {
+	fs := flag.NewFlagSet(opts.name()+" "+cmd.Name, flag.ContinueOnError)
+	var bindings []*Binding
+	if prog != nil {
+		bindings = append(bindings, BindFlagSet(fs, prog))
+	}
+	if cmd.Flags != nil {
+		bindings = append(bindings, BindFlagSet(fs, cmd.Flags))
+	}
+	return fs, bindings
+}
+
+// helpCommand handles `help [COMMAND]`
+func helpCommand(prog interface{}, args []string, opts *RunOptions, binding *Binding) {
+	if len(args) > 0 {
+		cmd := opts.lookup(args[0])
+		if cmd == nil {
+			fmt.Fprintf(os.Stderr, "unknown command: %s\n", args[0])
+			os.Exit(1)
+		}
+		// bind to a separate flag set to collect flags
+		_, bindings := newFlagSet(prog, cmd, opts)
+		printCommandHelp(os.Stdout, opts, cmd, bindings)
+		os.Exit(0)
+	}
+	printHelp(os.Stdout, opts, binding)
+	os.Exit(0)
+}
+
+func (c *RunOptions) lookup(commd string) *Command {
+	for _, cmd := range c.Cmds {
+		if cmd.Name == commd {
+			return cmd
+		}
+	}
+	return nil
+}
+
+func (c *RunOptions) name() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return filepath.Base(os.Args[0])
+}
+
+func isHelp(commd string) bool {
+	return commd == "help" || commd == "-h" || commd == "-help" || commd == "--help"
+}
@@ -18,6 +18,10 @@ type TarOptions struct {
 	OnAdd         func(relPath string, dir bool)
 	WritePrefix   string
 	ClearModTime  bool
+
+	// CheckSymlink is called for each symlink before it is added,
+	// with the written name and the link target, an error aborts tar
+	CheckSymlink func(relPath string, target string) error
 }
 
 // Tar takes a source and variable writers and walks 'source' writing each file
@@ -29,9 +33,18 @@ func Tar(src string, writer io.Writer, opts *TarOptions) error {
 	return TarAppend(src, tw, opts)
 }
 func WrapTarWriter(writer io.Writer) (tw *tar.Writer, flush func() error, close func() error) {
+	tw, flush, close, _ = WrapTarWriterLevel(writer, gzip.DefaultCompression)
+	return
+}
+
+// WrapTarWriterLevel is like WrapTarWriter, with gzip compression level
+func WrapTarWriterLevel(writer io.Writer, level int) (tw *tar.Writer, flush func() error, close func() error, err error) {
 	mw := writer
 
-	gzw := gzip.NewWriter(mw)
+	gzw, err := gzip.NewWriterLevel(mw, level)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
 	tw = tar.NewWriter(gzw)
 	flush = func() error {
@@ -73,14 +86,23 @@ func TarAppend(src string, tw *tar.Writer, opts *TarOptions) error {
 			return err
 		}
 		isDir := info.Type().IsDir()
+		isSymlink := info.Type()&fs.ModeSymlink != 0
 
 		// return on non-regular files (thanks to [kumo](https://medium.com/@komuw/just-like-you-did-fbdd7df829d3) for this suggested update)
-		if !isDir && !info.Type().IsRegular() {
+		// symlinks are kept as is, not followed
+		if !isDir && !isSymlink && !info.Type().IsRegular() {
 			return nil
 		}
+		var link string
+		if isSymlink {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
 
 		// create a new dir/file header
-		header, err := tar.FileInfoHeader(finfo, info.Name())
+		header, err := tar.FileInfoHeader(finfo, link)
 		if err != nil {
 			return err
 		}
@@ -110,6 +132,13 @@ func TarAppend(src string, tw *tar.Writer, opts *TarOptions) error {
 			}
 		}
 
+		if isSymlink && opts != nil && opts.CheckSymlink != nil {
+			err := opts.CheckSymlink(name, link)
+			if err != nil {
+				return err
+			}
+		}
+
 		if opts != nil && opts.OnAdd != nil {
 			opts.OnAdd(name, isDir)
 		}
@@ -122,7 +151,7 @@ func TarAppend(src string, tw *tar.Writer, opts *TarOptions) error {
 			return err
 		}
 
-		if isDir {
+		if isDir || isSymlink {
 			return nil
 		}
 
@@ -150,7 +179,7 @@ func TarAdd(tw *tar.Writer, header *tar.Header, content io.Reader) error {
 	if err := tw.WriteHeader(header); err != nil {
 		return err
 	}
-	if header.Typeflag == tar.TypeDir || content == nil {
+	if header.Typeflag == tar.TypeDir || header.Typeflag == tar.TypeSymlink || content == nil {
 		return nil
 	}
 	_, err := io.Copy(tw, content)
@@ -166,6 +195,16 @@ func TarAddDir(tw *tar.Writer, name string, mode fs.FileMode) error {
 	}, nil)
 }
 
+// no modTime included
+func TarAddSymlink(tw *tar.Writer, name string, target string) error {
+	return TarAdd(tw, &tar.Header{
+		Typeflag: tar.TypeSymlink,
+		Name:     name,
+		Linkname: target,
+		Mode:     0777,
+	}, nil)
+}
+
 // no modTime included
 func TarAddFile(tw *tar.Writer, name string, size int64, mode fs.FileMode, content io.Reader) error {
 	return TarAdd(tw, &tar.Header{
@@ -7,51 +7,18 @@ import (
 	"io"
 	"io/fs"
 	"io/ioutil"
-	"os"
-	"path/filepath"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/writefs"
 )
 
-// Untar takes a destination path and a reader; a tar reader loops over the tarfile
-// creating the file structure at 'dst' along the way, and writing any files
+// Untar takes a destination path and a reader, creating the file structure at 'dst'
+// along the way, existing files are overwritten. See UntarFS for more options
 func Untar(dst string, r io.Reader) error {
-	return ForEachFileInTar(r, func(header *tar.Header, tarReader io.Reader) (error, bool) {
-		// the target location where the dir/file should be created
-		target := filepath.Join(dst, header.Name)
-		// the following switch could also be done using fi.Mode(), not sure if there
-		// a benefit of using one vs. the other.
-		// fi := header.FileInfo()
-
-		// check the file type
-		switch header.Typeflag {
-
-		// if its a dir and it doesn't exist create it
-		case tar.TypeDir:
-			if _, err := os.Stat(target); err != nil {
-				if err := os.MkdirAll(target, 0755); err != nil {
-					return err, false
-				}
-			}
-		// if it's a file create it
-		case tar.TypeReg:
-			f, err := os.OpenFile(target, os.O_CREATE|os.O_RDWR, os.FileMode(header.Mode))
-			if err != nil {
-				return err, false
-			}
-
-			// copy over contents
-			if _, err := io.Copy(f, tarReader); err != nil {
-				return err, false
-			}
-
-			// manually close here after each file operation; defering would cause each file close
-			// to wait until all operations have completed.
-			f.Close()
-		}
-		return nil, true
-	})
+	return UntarFS(r, writefs.SysFS{}, dst, nil)
 }
 
 type tarFS struct {
@@ -67,8 +34,9 @@ type info struct {
 
 type dirEntry struct {
 	fs.DirEntry
-	name  string
-	isDir bool
+	name   string
+	isDir  bool
+	header *tar.Header
 }
 
 func (c *dirEntry) Name() string {
@@ -77,26 +45,73 @@ func (c *dirEntry) Name() string {
 func (c *dirEntry) IsDir() bool {
 	return c.isDir
 }
+func (c *dirEntry) Type() fs.FileMode {
+	return c.header.FileInfo().Mode().Type()
+}
+func (c *dirEntry) Info() (fs.FileInfo, error) {
+	return c.header.FileInfo(), nil
+}
 
 type fileInfo struct {
 	fs.FileInfo
 }
 
-var _ packfs.FS = (*tarFS)(nil)
+var _ packfs.StatFS = (*tarFS)(nil)
+var _ packfs.SymlinkFS = (*tarFS)(nil)
+
+// maxSymlinks limits symlinks followed in one path, like ELOOP
+const maxSymlinks = 40
+
+type TarFSOptions struct {
+	// StripPrefix is a top dir like project-v1.2.3, entries
+	// outside of it are dropped
+	StripPrefix string
+	// Limits defaults to DefaultMemLimits
+	Limits *Limits
+}
 
 func NewTarFS(r io.Reader) (packfs.FS, error) {
+	return NewTarFSWithOptions(r, nil)
+}
+
+// NewTarFSWithOptions reads the whole tar into memory, missing
+// parent dirs are synthesized, as tools other than go-pack
+// often omit dir headers
+func NewTarFSWithOptions(r io.Reader, opts *TarFSOptions) (packfs.FS, error) {
+	if opts == nil {
+		opts = &TarFSOptions{}
+	}
+	limits := opts.Limits
+	if limits == nil {
+		limits = DefaultMemLimits
+	}
+	prefix := normalize(opts.StripPrefix)
 	mapping := make(map[string]*info)
-	err := ForEachFileInTar(r, func(header *tar.Header, r io.Reader) (error, bool) {
+	err := ForEachFileInTarLimits(r, limits, func(header *tar.Header, r io.Reader) (error, bool) {
+		// pax_global_header of git archive
+		if header.Typeflag == tar.TypeXGlobalHeader {
+			return nil, true
+		}
+		name := normalize(header.Name)
+		if prefix != "" {
+			if name == prefix {
+				return nil, true
+			}
+			if !dirPrefixWith(name, prefix) {
+				return nil, true
+			}
+			name = name[len(prefix)+1:]
+		}
 		content, err := ioutil.ReadAll(r)
 		if err != nil {
 			return err, false
 		}
-		name := normalize(header.Name)
 		mapping[name] = &info{
 			header: header,
 			self: &dirEntry{
-				name:  basename(name),
-				isDir: header.Typeflag == tar.TypeDir,
+				name:   basename(name),
+				isDir:  header.Typeflag == tar.TypeDir,
+				header: header,
 			},
 			content: content,
 		}
@@ -114,21 +129,57 @@ func NewTarFS(r io.Reader) (packfs.FS, error) {
 	}, nil
 }
 
+// fillTree links entries to their parents, the root
+// is "", missing dirs are added
 func fillTree(mapping map[string]*info) error {
-	for name, inf := range mapping {
-		parent := dirname(name)
-		if parent == "" || parent == "." || parent == "/" {
+	names := make([]string, 0, len(mapping))
+	for name := range mapping {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if mapping[""] == nil {
+		mapping[""] = newDirInfo("")
+	}
+	for _, name := range names {
+		if name == "" {
 			continue
 		}
-		parentInf := mapping[parent]
-		if parentInf == nil {
-			return fmt.Errorf("building tree: %s not found", parent)
+		for {
+			parent := dirname(name)
+			parentInf := mapping[parent]
+			created := parentInf == nil
+			if created {
+				parentInf = newDirInfo(parent)
+				mapping[parent] = parentInf
+			} else if parentInf.header.Typeflag != tar.TypeDir {
+				return fmt.Errorf("building tree: %s is not a directory", parent)
+			}
+			parentInf.children = append(parentInf.children, mapping[name].self)
+			if !created || parent == "" {
+				break
+			}
+			name = parent
 		}
-		parentInf.children = append(parentInf.children, inf.self)
 	}
 	return nil
 }
 
+func newDirInfo(name string) *info {
+	header := &tar.Header{
+		Name:     name + "/",
+		Typeflag: tar.TypeDir,
+		Mode:     0755,
+	}
+	return &info{
+		header: header,
+		self: &dirEntry{
+			name:   basename(name),
+			isDir:  true,
+			header: header,
+		},
+	}
+}
+
 func basename(s string) string {
 	if s == "" || s == "/" {
 		return s
@@ -152,6 +203,9 @@ func dirname(s string) string {
 }
 
 func normalize(name string) string {
+	if name == "." || name == "./" {
+		return ""
+	}
 	name = strings.TrimPrefix(name, "./")
 	if name != "/" {
 		name = strings.TrimSuffix(name, "/")
@@ -169,11 +223,70 @@ func dirPrefixWith(dir string, prefix string) bool {
 	return dir[len(prefix)] == '/'
 }
 
+// resolve follows symlinks in every component of name,
+// links pointing outside of the tar are not followed
+func (t *tarFS) resolve(name string, followLast bool) (string, *info, error) {
+	hops := 0
+	var resolve func(name string, followLast bool) (string, *info, error)
+	resolve = func(name string, followLast bool) (string, *info, error) {
+		parts := strings.Split(name, "/")
+		cur := ""
+		var inf *info
+		for i, part := range parts {
+			p := part
+			if cur != "" {
+				p = cur + "/" + part
+			}
+			inf = t.mapping[p]
+			if inf == nil {
+				return "", nil, packfs.NewError(packfs.ErrKind_NotExists, fmt.Errorf("no such file: %v", name))
+			}
+			if inf.header.Typeflag == tar.TypeSymlink && (followLast || i < len(parts)-1) {
+				hops++
+				if hops > maxSymlinks {
+					return "", nil, fmt.Errorf("too many levels of symbolic links: %v", name)
+				}
+				link := inf.header.Linkname
+				if path.IsAbs(link) {
+					return "", nil, packfs.NewError(packfs.ErrKind_NotExists, fmt.Errorf("symlink %s points outside of tar: %s", p, link))
+				}
+				target := path.Join(dirname(p), link)
+				if target == ".." || strings.HasPrefix(target, "../") {
+					return "", nil, packfs.NewError(packfs.ErrKind_NotExists, fmt.Errorf("symlink %s points outside of tar: %s", p, link))
+				}
+				var err error
+				p, inf, err = resolve(target, true)
+				if err != nil {
+					return "", nil, err
+				}
+			}
+			cur = p
+		}
+		return cur, inf, nil
+	}
+	return resolve(normalize(name), followLast)
+}
+
+// Readlink implements packfs.SymlinkFS.
+func (t *tarFS) Readlink(name string) (string, error) {
+	_, inf, err := t.resolve(name, false)
+	if err != nil {
+		return "", err
+	}
+	if inf.header.Typeflag != tar.TypeSymlink {
+		return "", fmt.Errorf("not a symlink: %v", name)
+	}
+	return inf.header.Linkname, nil
+}
+
 // ReadDir implements helper.FS.
 func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
-	inf, ok := t.mapping[name]
-	if !ok {
-		return nil, packfs.NewError(packfs.ErrKind_NotExists, fmt.Errorf("no such directory: %v", name))
+	_, inf, err := t.resolve(name, true)
+	if err != nil {
+		if packfs.IsNotExists(err) {
+			return nil, packfs.NewError(packfs.ErrKind_NotExists, fmt.Errorf("no such directory: %v", name))
+		}
+		return nil, err
 	}
 	if inf.header.Typeflag != tar.TypeDir {
 		return nil, fmt.Errorf("type error, expecting directory, actual file: %v", name)
@@ -181,11 +294,21 @@ func (t *tarFS) ReadDir(name string) ([]fs.DirEntry, error) {
 	return inf.children, nil
 }
 
+// Stat implements packfs.StatFS, mode and
+// modification time are from the tar header
+func (t *tarFS) Stat(name string) (fs.FileInfo, error) {
+	_, inf, err := t.resolve(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return inf.header.FileInfo(), nil
+}
+
 // ReadFile implements helper.FS.
 func (t *tarFS) ReadFile(file string) ([]byte, error) {
-	inf, ok := t.mapping[file]
-	if !ok {
-		return nil, packfs.NewError(packfs.ErrKind_NotExists, fmt.Errorf("no such file: %v", file))
+	_, inf, err := t.resolve(file, true)
+	if err != nil {
+		return nil, err
 	}
 	if inf.self.isDir {
 		return nil, fmt.Errorf("not a file: %v", file)
@@ -193,22 +316,32 @@ func (t *tarFS) ReadFile(file string) ([]byte, error) {
 	return inf.content, nil
 }
 
+// ForEachFileInTar reads a gzipped tar with DefaultLimits, entries escaping the root,
+// duplicated or being devices are rejected with *Error, see ForEachFileInTarLimits
 func ForEachFileInTar(r io.Reader, fn func(header *tar.Header, r io.Reader) (error, bool)) error {
-	gzr, err := gzip.NewReader(r)
+	return ForEachFileInTarLimits(r, DefaultLimits, fn)
+}
+
+// ForEachFileInTarLimits is like ForEachFileInTar, nil limits means no limit
+func ForEachFileInTarLimits(r io.Reader, limits *Limits, fn func(header *tar.Header, r io.Reader) (error, bool)) error {
+	compressed := &countingReader{r: r}
+	gzr, err := gzip.NewReader(compressed)
 	if err != nil {
 		return err
 	}
 	defer gzr.Close()
 
 	tr := tar.NewReader(gzr)
+	v := newValidator(limits, compressed)
 
+	var consumed int64
 	for {
 		header, err := tr.Next()
 		switch {
 
 		// if no more files are found return
 		case err == io.EOF:
-			return nil
+			return v.checkRatio(consumed)
 
 		// return any other error
 		case err != nil:
@@ -218,6 +351,16 @@ func ForEachFileInTar(r io.Reader, fn func(header *tar.Header, r io.Reader) (err
 		case header == nil:
 			continue
 		}
+		// content of previous entries is consumed by Next
+		err = v.checkRatio(consumed)
+		if err != nil {
+			return err
+		}
+		err = v.check(header)
+		if err != nil {
+			return err
+		}
+		consumed += header.Size
 		err, ok := fn(header, tr)
 		if err != nil {
 			return err
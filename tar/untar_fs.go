@@ -0,0 +1,188 @@
+package tar
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// OverwritePolicy decides what happens when an entry already exists in dst
+type OverwritePolicy string
+
+const (
+	OverwritePolicy_Overwrite       OverwritePolicy = "overwrite"         // replace existing files, the default
+	OverwritePolicy_SkipExisting    OverwritePolicy = "skip-existing"     // keep existing files
+	OverwritePolicy_ErrorOnExisting OverwritePolicy = "error-on-existing" // fail with os.ErrExist
+)
+
+func ParseOverwritePolicy(s string) (OverwritePolicy, error) {
+	policy := OverwritePolicy(s)
+	switch policy {
+	case "":
+		return OverwritePolicy_Overwrite, nil
+	case OverwritePolicy_Overwrite, OverwritePolicy_SkipExisting, OverwritePolicy_ErrorOnExisting:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown overwrite policy: %s, available: overwrite, skip-existing, error-on-existing", s)
+}
+
+type UntarOptions struct {
+	Policy OverwritePolicy
+	// StripComponents removes leading path elements,
+	// entries with no elements left are skipped
+	StripComponents int
+	// Include filters entries by name after stripping, nil means all.
+	// Parent dirs of included files are always created
+	Include func(name string, dir bool) bool
+	// Limits defaults to DefaultLimits
+	Limits *Limits
+}
+
+// UntarFS extracts a gzipped tar into dst of wfs, existing
+// directories are merged, existing files are handled by opts.Policy.
+// Hard links are written as copies of their target, which must be
+// a file extracted earlier
+func UntarFS(r io.Reader, wfs writefs.FS, dst string, opts *UntarOptions) error {
+	if opts == nil {
+		opts = &UntarOptions{}
+	}
+	policy := opts.Policy
+	if policy == "" {
+		policy = OverwritePolicy_Overwrite
+	}
+	limits := opts.Limits
+	if limits == nil {
+		limits = DefaultLimits
+	}
+	// regular files extracted so far, archive name -> target,
+	// hard links are materialized by copying them
+	extracted := make(map[string]string)
+	return ForEachFileInTarLimits(r, limits, func(header *tar.Header, tarReader io.Reader) (error, bool) {
+		switch header.Typeflag {
+		case tar.TypeDir, tar.TypeSymlink, tar.TypeLink, tar.TypeReg, tar.TypeRegA:
+		default:
+			return nil, true
+		}
+		// names are validated by ForEachFileInTarLimits, never escaping dst
+		archiveName, err := CleanName(header.Name)
+		if err != nil {
+			return err, false
+		}
+		name, ok := stripComponents(archiveName, opts.StripComponents)
+		if !ok {
+			return nil, true
+		}
+		isDir := header.Typeflag == tar.TypeDir
+		if opts.Include != nil && !opts.Include(name, isDir) {
+			return nil, true
+		}
+		target := filepath.Join(dst, filepath.FromSlash(name))
+		content := tarReader
+		if header.Typeflag == tar.TypeLink {
+			linkName, err := CleanName(header.Linkname)
+			if err != nil {
+				return err, false
+			}
+			src, ok := extracted[linkName]
+			if !ok {
+				return fmt.Errorf("%s: hard link target %s is not extracted", header.Name, header.Linkname), false
+			}
+			srcReader, err := wfs.OpenFileRead(src)
+			if err != nil {
+				return err, false
+			}
+			defer srcReader.Close()
+			content = srcReader
+		}
+		write, err := prepareTarget(wfs, target, isDir, policy)
+		if err != nil {
+			return err, false
+		}
+		if !write {
+			return nil, true
+		}
+		if isDir {
+			return wfs.MkdirAll(target, 0755), true
+		}
+		err = wfs.MkdirAll(filepath.Dir(target), 0755)
+		if err != nil {
+			return err, false
+		}
+		if header.Typeflag == tar.TypeSymlink {
+			err := writefs.Symlink(wfs, header.Linkname, target)
+			if err != nil {
+				return fmt.Errorf("%s: %w", target, err), false
+			}
+			return nil, true
+		}
+		w, err := wfs.OpenFileWrite(target)
+		if err != nil {
+			return err, false
+		}
+		_, err = io.Copy(w, content)
+		closeErr := w.Close()
+		if err != nil {
+			return err, false
+		}
+		if closeErr != nil {
+			return closeErr, false
+		}
+		if mfs, ok := wfs.(writefs.FSWithMode); ok {
+			err := mfs.Chmod(target, header.FileInfo().Mode().Perm())
+			if err != nil {
+				return err, false
+			}
+		}
+		extracted[archiveName] = target
+		return nil, true
+	})
+}
+
+// prepareTarget applies policy to an existing target, reports whether to write it
+func prepareTarget(wfs writefs.FS, target string, isDir bool, policy OverwritePolicy) (bool, error) {
+	info, err := wfs.Stat(target)
+	if err != nil && !writefs.IsNotExist(err) {
+		return false, err
+	}
+	exists := err == nil
+	if exists && isDir && info.IsDir() {
+		return true, nil
+	}
+	if exists {
+		switch policy {
+		case OverwritePolicy_SkipExisting:
+			return false, nil
+		case OverwritePolicy_ErrorOnExisting:
+			return false, fmt.Errorf("%s: %w", target, os.ErrExist)
+		}
+		if isDir || info.IsDir() {
+			return true, wfs.RemoveAll(target)
+		}
+	}
+	if isDir {
+		return true, nil
+	}
+	// also removes symlinks, even dangling ones, so
+	// writing never goes through them
+	err = wfs.RemoveFile(target)
+	if err != nil && !writefs.IsNotExist(err) {
+		return false, err
+	}
+	return true, nil
+}
+
+func stripComponents(name string, n int) (string, bool) {
+	if n <= 0 {
+		return name, name != "."
+	}
+	parts := strings.SplitN(name, "/", n+1)
+	if len(parts) <= n {
+		return "", false
+	}
+	return parts[n], true
+}
@@ -0,0 +1,140 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestUntarTruncates -v ./tar
+func TestUntarTruncates(t *testing.T) {
+	dir, err := ioutil.TempDir("", "untar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "a.txt"), []byte("a much longer old content"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Untar(dir, bytes.NewReader(makeTestTar(t, []testEntry{{name: "a.txt", content: "new"}})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "new" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "content", "new", string(content))
+	}
+}
+
+// go test -run TestUntarFSPolicy -v ./tar
+func TestUntarFSPolicy(t *testing.T) {
+	data := makeTestTar(t, []testEntry{{name: "d/", typ: tar.TypeDir}, {name: "d/a.txt", content: "new"}})
+	tests := []struct {
+		policy  OverwritePolicy
+		content string
+		exists  bool // expect os.ErrExist
+	}{
+		{OverwritePolicy_Overwrite, "new", false},
+		{OverwritePolicy_SkipExisting, "old", false},
+		{OverwritePolicy_ErrorOnExisting, "old", true},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			fs := memfs.New()
+			err := fs.MkdirAll("out/d", 0755)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = writefs.WriteFile(fs, "out/d/a.txt", []byte("old"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = UntarFS(bytes.NewReader(data), fs, "out", &UntarOptions{Policy: tt.policy})
+			if tt.exists != errors.Is(err, os.ErrExist) || (!tt.exists && err != nil) {
+				t.Fatalf("expect %s = %+v, actual:%+v", "exist err", tt.exists, err)
+			}
+			content, err := writefs.ReadFile(fs, "out/d/a.txt")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != tt.content {
+				t.Fatalf("expect %s = %+v, actual:%+v", "content", tt.content, string(content))
+			}
+		})
+	}
+}
+
+// go test -run TestUntarFSStripAndInclude -v ./tar
+func TestUntarFSStripAndInclude(t *testing.T) {
+	data := makeTestTar(t, []testEntry{
+		{name: "project-v1.2.3/", typ: tar.TypeDir},
+		{name: "project-v1.2.3/go.mod", content: "module project"},
+		{name: "project-v1.2.3/vendor/a/a.go", content: "package a"},
+		{name: "project-v1.2.3/docs/README.md", content: "readme"},
+	})
+	fs := memfs.New()
+	err := UntarFS(bytes.NewReader(data), fs, "out", &UntarOptions{
+		StripComponents: 1,
+		Include: func(name string, dir bool) bool {
+			return name != "docs/README.md"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"out/go.mod", "out/vendor/a/a.go"} {
+		_, err := fs.Stat(file)
+		if err != nil {
+			t.Fatalf("expect %s exists, actual:%+v", file, err)
+		}
+	}
+	_, err = fs.Stat("out/docs/README.md")
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "excluded README.md", "not exist", err)
+	}
+	_, err = fs.Stat("out/project-v1.2.3")
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "stripped dir", "not exist", err)
+	}
+}
+
+// go test -run TestUntarFSHardLink -v ./tar
+func TestUntarFSHardLink(t *testing.T) {
+	data := makeTestTar(t, []testEntry{
+		{name: "a.txt", content: "a"},
+		{name: "d/b.txt", typ: tar.TypeLink, linkname: "a.txt"},
+	})
+	fs := memfs.New()
+	err := UntarFS(bytes.NewReader(data), fs, "out", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := writefs.ReadFile(fs, "out/d/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "a" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "content", "a", string(content))
+	}
+
+	// the target is excluded
+	err = UntarFS(bytes.NewReader(data), memfs.New(), "out", &UntarOptions{
+		Include: func(name string, dir bool) bool {
+			return name != "a.txt"
+		},
+	})
+	if err == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "hard link target a.txt is not extracted", err)
+	}
+}
@@ -0,0 +1,232 @@
+package tar
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+)
+
+type testEntry struct {
+	name     string
+	typ      byte
+	linkname string
+	content  string
+	size     int64 // if 0, len(content)
+}
+
+func makeTestTar(t testing.TB, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, e := range entries {
+		typ := e.typ
+		if typ == 0 {
+			typ = tar.TypeReg
+		}
+		size := e.size
+		if size == 0 {
+			size = int64(len(e.content))
+		}
+		header := &tar.Header{Name: e.name, Typeflag: typ, Linkname: e.linkname, Size: size, Mode: 0644}
+		if typ == tar.TypeXGlobalHeader {
+			header = &tar.Header{Typeflag: typ, PAXRecords: map[string]string{"comment": "commit"}}
+		}
+		err := tw.WriteHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size > 0 {
+			_, err = io.CopyN(tw, io.MultiReader(bytes.NewReader([]byte(e.content)), zeroReader{}), size)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gzw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+func readAll(data []byte, limits *Limits) error {
+	return ForEachFileInTarLimits(bytes.NewReader(data), limits, func(header *tar.Header, r io.Reader) (error, bool) {
+		_, err := io.Copy(ioutil.Discard, r)
+		return err, true
+	})
+}
+
+// go test -run TestForEachFileInTarValidate -v ./tar
+func TestForEachFileInTarValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []testEntry
+		limits  *Limits
+		kind    ErrKind // 0: no error
+	}{
+		{"ok", []testEntry{{name: "a/", typ: tar.TypeDir}, {name: "a/b.go", content: "package a"}}, DefaultLimits, 0},
+		{"repeated dir", []testEntry{{name: "a/", typ: tar.TypeDir}, {name: "a/", typ: tar.TypeDir}}, DefaultLimits, 0},
+		{"traversal", []testEntry{{name: "../evil", content: "x"}}, DefaultLimits, ErrKind_Traversal},
+		{"inner traversal", []testEntry{{name: "a/../../evil", content: "x"}}, DefaultLimits, ErrKind_Traversal},
+		{"backslash traversal", []testEntry{{name: "a\\..\\..\\evil", content: "x"}}, DefaultLimits, ErrKind_Traversal},
+		{"absolute", []testEntry{{name: "/etc/passwd", content: "x"}}, DefaultLimits, ErrKind_Absolute},
+		{"volume", []testEntry{{name: "C:/evil", content: "x"}}, DefaultLimits, ErrKind_Absolute},
+		{"hard link traversal", []testEntry{{name: "a", typ: tar.TypeLink, linkname: "../../etc/passwd"}}, DefaultLimits, ErrKind_Traversal},
+		{"through symlink", []testEntry{{name: "a", typ: tar.TypeSymlink, linkname: "/etc"}, {name: "a/passwd", content: "x"}}, DefaultLimits, ErrKind_Traversal},
+		{"duplicate", []testEntry{{name: "a", content: "x"}, {name: "./a", content: "y"}}, DefaultLimits, ErrKind_Duplicate},
+		{"char device", []testEntry{{name: "a", typ: tar.TypeChar}}, DefaultLimits, ErrKind_Device},
+		{"fifo", []testEntry{{name: "a", typ: tar.TypeFifo}}, DefaultLimits, ErrKind_Device},
+		{"file too large", []testEntry{{name: "a", content: "12345"}}, &Limits{MaxFileSize: 4}, ErrKind_FileTooLarge},
+		{"total too large", []testEntry{{name: "a", content: "123"}, {name: "b", content: "123"}}, &Limits{MaxTotalSize: 5}, ErrKind_TotalTooLarge},
+		{"too many entries", []testEntry{{name: "a"}, {name: "b"}, {name: "c"}}, &Limits{MaxEntries: 2}, ErrKind_TooManyEntries},
+		{"bomb", []testEntry{{name: "a", size: 16 << 20}}, DefaultLimits, ErrKind_RatioTooHigh},
+		{"bomb without limit", []testEntry{{name: "a", size: 16 << 20}}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := readAll(makeTestTar(t, tt.entries), tt.limits)
+			if tt.kind == 0 {
+				if err != nil {
+					t.Fatalf("expect err = nil, actual:%+v", err)
+				}
+				return
+			}
+			if !IsErrKind(err, tt.kind) {
+				t.Fatalf("expect err kind = %+v, actual:%+v", tt.kind, err)
+			}
+		})
+	}
+}
+
+// go test -run TestNewTarFSRejectsTraversal -v ./tar
+func TestNewTarFSRejectsTraversal(t *testing.T) {
+	data := makeTestTar(t, []testEntry{{name: "../evil", content: "x"}})
+	_, err := NewTarFS(bytes.NewReader(data))
+	if !IsErrKind(err, ErrKind_Traversal) {
+		t.Fatalf("expect err kind = %+v, actual:%+v", ErrKind_Traversal, err)
+	}
+}
+
+// go test -run TestNewTarFSMemLimits -v ./tar
+func TestNewTarFSMemLimits(t *testing.T) {
+	data := makeTestTar(t, []testEntry{{name: "a", size: DefaultMemLimits.MaxFileSize + 1}})
+	_, err := NewTarFS(bytes.NewReader(data))
+	if !IsErrKind(err, ErrKind_FileTooLarge) {
+		t.Fatalf("expect err kind = %+v, actual:%+v", ErrKind_FileTooLarge, err)
+	}
+	// streaming to disk allows it
+	err = readAll(data, &Limits{MaxFileSize: DefaultLimits.MaxFileSize})
+	if err != nil {
+		t.Fatalf("expect err = nil, actual:%+v", err)
+	}
+}
+
+// go test -run FuzzForEachFileInTar -fuzz FuzzForEachFileInTar ./tar
+func FuzzForEachFileInTar(f *testing.F) {
+	f.Add(makeTestTar(f, []testEntry{{name: "a/", typ: tar.TypeDir}, {name: "a/b.go", content: "package a"}}))
+	f.Add(makeTestTar(f, []testEntry{{name: "a", typ: tar.TypeSymlink, linkname: "b"}, {name: "b", content: "b"}}))
+	f.Add(makeTestTar(f, []testEntry{{name: "../evil", content: "x"}}))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		limits := &Limits{MaxFileSize: 1 << 20, MaxTotalSize: 4 << 20, MaxEntries: 1000, MaxRatio: 100}
+		var names []string
+		err := ForEachFileInTarLimits(bytes.NewReader(data), limits, func(header *tar.Header, r io.Reader) (error, bool) {
+			name, err := CleanName(header.Name)
+			if err != nil {
+				t.Fatalf("expect validated name, actual:%+v", err)
+			}
+			names = append(names, name)
+			_, err = io.Copy(ioutil.Discard, r)
+			return err, true
+		})
+		if err != nil {
+			return
+		}
+		if len(names) > limits.MaxEntries {
+			t.Fatalf("expect entries <= %d, actual:%d", limits.MaxEntries, len(names))
+		}
+		// the same archive must be accepted by NewTarFS
+		tfs, err := NewTarFS(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		for _, name := range names {
+			_, _ = tfs.ReadFile(name)
+		}
+	})
+}
+
+// go test -run TestNewTarFSImplicitDirs -v ./tar
+func TestNewTarFSImplicitDirs(t *testing.T) {
+	data := makeTestTar(t, []testEntry{
+		{name: "pax_global_header", typ: tar.TypeXGlobalHeader},
+		{name: "project-v1.2.3/go.mod", content: "module project"},
+		{name: "project-v1.2.3/vendor/golang.org/x/mod/module/module.go", content: "package module"},
+		{name: "other/README.md", content: "readme"},
+	})
+	tfs, err := NewTarFS(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := tfs.ReadDir("project-v1.2.3/vendor/golang.org/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "mod" || !entries[0].IsDir() {
+		t.Fatalf("expect %s = %+v, actual:%+v", "entries", "[mod/]", entries)
+	}
+	root, err := tfs.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root) != 2 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "len(root)", 2, len(root))
+	}
+
+	stripped, err := NewTarFSWithOptions(bytes.NewReader(data), &TarFSOptions{StripPrefix: "project-v1.2.3/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := stripped.ReadFile("vendor/golang.org/x/mod/module/module.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "package module" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "content", "package module", string(content))
+	}
+	_, err = stripped.ReadFile("other/README.md")
+	if !packfs.IsNotExists(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "other/README.md", "not exists", err)
+	}
+
+	sub, err := packfs.Sub(tfs, "project-v1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err = sub.ReadFile("go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "module project" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "content", "module project", string(content))
+	}
+	_, err = sub.ReadFile("../other/README.md")
+	if !packfs.IsNotExists(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "escaping sub", "not exists", err)
+	}
+}
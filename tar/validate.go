@@ -0,0 +1,192 @@
+package tar
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+type ErrKind int
+
+const (
+	ErrKind_Traversal      ErrKind = 1 // name escapes the archive root, or goes through a symlink
+	ErrKind_Absolute       ErrKind = 2
+	ErrKind_Duplicate      ErrKind = 3
+	ErrKind_Device         ErrKind = 4 // char, block device or fifo
+	ErrKind_FileTooLarge   ErrKind = 5
+	ErrKind_TotalTooLarge  ErrKind = 6
+	ErrKind_TooManyEntries ErrKind = 7
+	ErrKind_RatioTooHigh   ErrKind = 8 // likely a compression bomb
+)
+
+// Error is returned when an archive violates validation or Limits
+type Error struct {
+	Kind ErrKind
+	Name string // the entry name, empty for archive wide limits
+	Err  error
+}
+
+func NewError(kind ErrKind, name string, err error) *Error {
+	return &Error{Kind: kind, Name: name, Err: err}
+}
+
+func (c *Error) Error() string {
+	if c.Name == "" {
+		return c.Err.Error()
+	}
+	return fmt.Sprintf("%s: %v", c.Name, c.Err)
+}
+
+func (c *Error) Unwrap() error {
+	return c.Err
+}
+
+// IsErrKind reports whether err is an *Error of kind
+func IsErrKind(err error, kind ErrKind) bool {
+	var tarErr *Error
+	return errors.As(err, &tarErr) && tarErr.Kind == kind
+}
+
+// Limits bounds what ForEachFileInTar reads, 0 means no limit
+type Limits struct {
+	MaxFileSize  int64
+	MaxTotalSize int64
+	MaxEntries   int
+	// MaxRatio is the max ratio of uncompressed size to compressed size,
+	// only checked after MinRatioCheckSize bytes are uncompressed
+	MaxRatio float64
+}
+
+// MinRatioCheckSize avoids rejecting small archives, whose ratio is not meaningful
+const MinRatioCheckSize = 1 << 20
+
+// DefaultLimits is used by ForEachFileInTar and UntarFS,
+// which stream content to disk
+var DefaultLimits = &Limits{
+	MaxFileSize:  512 << 20,
+	MaxTotalSize: 4 << 30,
+	MaxEntries:   1 << 20,
+	MaxRatio:     500,
+}
+
+// DefaultMemLimits is used by NewTarFS, which holds
+// the whole content in memory
+var DefaultMemLimits = &Limits{
+	MaxFileSize:  64 << 20,
+	MaxTotalSize: 256 << 20,
+	MaxEntries:   1 << 18,
+	MaxRatio:     500,
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// validator checks entries one by one
+type validator struct {
+	limits     *Limits
+	compressed *countingReader
+
+	entries  int
+	total    int64
+	seen     map[string]byte // name -> type flag
+	symlinks map[string]bool
+}
+
+func newValidator(limits *Limits, compressed *countingReader) *validator {
+	if limits == nil {
+		limits = &Limits{}
+	}
+	return &validator{
+		limits:     limits,
+		compressed: compressed,
+		seen:       make(map[string]byte),
+		symlinks:   make(map[string]bool),
+	}
+}
+
+// CleanName validates name of an entry, returns the cleaned
+// relative name, "." for the root
+func CleanName(name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "/") || hasVolume(name) {
+		return "", NewError(ErrKind_Absolute, name, fmt.Errorf("absolute path"))
+	}
+	clean := path.Clean(name)
+	if clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", NewError(ErrKind_Traversal, name, fmt.Errorf("path escapes archive root"))
+	}
+	return clean, nil
+}
+
+// hasVolume reports windows volume names like C:
+func hasVolume(name string) bool {
+	return len(name) >= 2 && name[1] == ':' && ((name[0] >= 'a' && name[0] <= 'z') || (name[0] >= 'A' && name[0] <= 'Z'))
+}
+
+func (c *validator) check(header *tar.Header) error {
+	c.entries++
+	if c.limits.MaxEntries > 0 && c.entries > c.limits.MaxEntries {
+		return NewError(ErrKind_TooManyEntries, "", fmt.Errorf("too many entries, limit: %d", c.limits.MaxEntries))
+	}
+	name, err := CleanName(header.Name)
+	if err != nil {
+		return err
+	}
+	switch header.Typeflag {
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		return NewError(ErrKind_Device, header.Name, fmt.Errorf("device or fifo not allowed"))
+	case tar.TypeLink:
+		// hard links refer to another entry
+		_, err := CleanName(header.Linkname)
+		if err != nil {
+			return err
+		}
+	}
+	for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+		if c.symlinks[dir] {
+			return NewError(ErrKind_Traversal, header.Name, fmt.Errorf("path goes through symlink %s", dir))
+		}
+	}
+	if prev, ok := c.seen[name]; ok {
+		// parent dirs are commonly repeated
+		if prev != tar.TypeDir || header.Typeflag != tar.TypeDir {
+			return NewError(ErrKind_Duplicate, header.Name, fmt.Errorf("duplicate entry"))
+		}
+	}
+	c.seen[name] = header.Typeflag
+	if header.Typeflag == tar.TypeSymlink {
+		c.symlinks[name] = true
+	}
+
+	if c.limits.MaxFileSize > 0 && header.Size > c.limits.MaxFileSize {
+		return NewError(ErrKind_FileTooLarge, header.Name, fmt.Errorf("size %d exceeds limit %d", header.Size, c.limits.MaxFileSize))
+	}
+	c.total += header.Size
+	if c.limits.MaxTotalSize > 0 && c.total > c.limits.MaxTotalSize {
+		return NewError(ErrKind_TotalTooLarge, "", fmt.Errorf("total size exceeds limit %d", c.limits.MaxTotalSize))
+	}
+	return nil
+}
+
+// checkRatio is called after the content of previous entries is consumed
+func (c *validator) checkRatio(uncompressed int64) error {
+	if c.limits.MaxRatio <= 0 || uncompressed < MinRatioCheckSize || c.compressed.n == 0 {
+		return nil
+	}
+	ratio := float64(uncompressed) / float64(c.compressed.n)
+	if ratio > c.limits.MaxRatio {
+		return NewError(ErrKind_RatioTooHigh, "", fmt.Errorf("compression ratio %.0f exceeds limit %.0f", ratio, c.limits.MaxRatio))
+	}
+	return nil
+}
@@ -0,0 +1,315 @@
+package unpack
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/go_info"
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/unpack/helper"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/txfs"
+)
+
+// CACHE_ENTRY_FILE describes a cached host dir, relative to the entry
+const CACHE_ENTRY_FILE = ".go-vendor-pack-cache.json"
+
+// DEFAULT_CACHE_MAX_SIZE bounds the cache when GCCache is called without a size
+const DEFAULT_CACHE_MAX_SIZE = 1 << 30
+
+const cacheTmpPrefix = "tmp-"
+
+// cacheTargetsDir keeps state of targets using cached or temp host dirs, e.g. their go.work and unpack lock
+const cacheTargetsDir = "targets"
+
+// CacheEntry is a host dir extracted from a pack, shared by non-vendor targets
+type CacheEntry struct {
+	Key         string
+	Dir         string `json:"-"`
+	Digest      string
+	PackTimeUTC string `json:",omitempty"`
+	GoVersion   string
+	Modules     []string
+	Size        int64     `json:"-"`
+	LastUsed    time.Time `json:"-"` // mtime of Dir, updated on every unpack using it
+}
+
+// DefaultCacheDir returns the cache root used when Options.CacheDir is empty
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "go-vendor-pack", "host-dirs"), nil
+}
+
+func getCacheDir(cacheDir string) (string, error) {
+	if cacheDir != "" {
+		return cacheDir, nil
+	}
+	return DefaultCacheDir()
+}
+
+// targetStateDir returns the dir in cacheDir keeping state of the target dir
+func targetStateDir(cacheDir string, dir string) (string, error) {
+	cacheDir, err := getCacheDir(cacheDir)
+	if err != nil {
+		return "", err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	h := md5.Sum([]byte(absDir))
+	return filepath.Join(cacheDir, cacheTargetsDir, hex.EncodeToString(h[:])), nil
+}
+
+func isSysFS(wfs writefs.FS) bool {
+	if tfs, ok := wfs.(*txfs.FS); ok {
+		wfs = tfs.Base()
+	}
+	_, ok := wfs.(writefs.SysFS)
+	return ok
+}
+
+// cachedHostDir returns the host dir of the pack in cacheDir, locked until unlock is called,
+// so writes into a shared entry and GCCache never race with other unpacks
+func cachedHostDir(fs packfs.FS, cacheDir string, digest string, packTime string, modules []string, goVersion *go_info.GoVersion, lockTimeout time.Duration) (entryDir string, unlock func(), err error) {
+	cacheDir, err = getCacheDir(cacheDir)
+	if err != nil {
+		return "", nil, err
+	}
+	h := md5.Sum([]byte(digest + "\n" + fmt.Sprintf("%d.%d", goVersion.Major, goVersion.Minor)))
+	entryDir = filepath.Join(cacheDir, hex.EncodeToString(h[:]))
+	unlock, err = lockDirs(writefs.SysFS{}, lockTimeout, entryDir)
+	if err != nil {
+		return "", nil, err
+	}
+	err = populateCacheEntry(fs, entryDir, digest, packTime, modules, goVersion)
+	if err != nil {
+		unlock()
+		return "", nil, err
+	}
+	return entryDir, unlock, nil
+}
+
+// populateCacheEntry extracts the pack into a temp dir and renames it to entryDir
+// on the first use, so an entry is either complete or absent, and
+// unpacks not holding the lock never see a partial one
+func populateCacheEntry(fs packfs.FS, entryDir string, digest string, packTime string, modules []string, goVersion *go_info.GoVersion) error {
+	cacheDir := filepath.Dir(entryDir)
+	key := filepath.Base(entryDir)
+	goVersionStr := fmt.Sprintf("%d.%d", goVersion.Major, goVersion.Minor)
+
+	_, err := os.Stat(filepath.Join(entryDir, CACHE_ENTRY_FILE))
+	if err == nil {
+		now := time.Now()
+		return os.Chtimes(entryDir, now, now)
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	err = os.MkdirAll(cacheDir, 0755)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(cacheDir, cacheTmpPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	// entries are shared, keep mtimes the same for every target
+	copyOpts, err := stableCopyOptions(packTime)
+	if err != nil {
+		return err
+	}
+	for _, module := range modules {
+		_, err := helper.AddVendorFSWithOptions(writefs.SysFS{}, tmpDir, module, fs, true, nil, copyOpts)
+		if err != nil {
+			return fmt.Errorf("caching %s: %w", module, err)
+		}
+		err = helper.TruncateGoModFS(writefs.SysFS{}, filepath.Join(tmpDir, "vendor", module, "go.mod"), module, goVersion.Major, goVersion.Minor)
+		if err != nil {
+			return err
+		}
+	}
+	data, err := json.MarshalIndent(&CacheEntry{
+		Key:         key,
+		Digest:      digest,
+		PackTimeUTC: packTime,
+		GoVersion:   goVersionStr,
+		Modules:     modules,
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filepath.Join(tmpDir, CACHE_ENTRY_FILE), append(data, '\n'), 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(tmpDir, entryDir)
+	if err != nil {
+		// populated by another unpack
+		if _, statErr := os.Stat(filepath.Join(entryDir, CACHE_ENTRY_FILE)); statErr == nil {
+			return nil
+		}
+		return fmt.Errorf("populating cache: %w", err)
+	}
+	return nil
+}
+
+// ListCache lists entries in cacheDir, the most recently used first.
+// If cacheDir is empty, DefaultCacheDir() is used.
+func ListCache(cacheDir string) ([]*CacheEntry, error) {
+	cacheDir, err := getCacheDir(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []*CacheEntry
+	for _, file := range files {
+		if !file.IsDir() || strings.HasPrefix(file.Name(), cacheTmpPrefix) {
+			continue
+		}
+		entryDir := filepath.Join(cacheDir, file.Name())
+		data, err := ioutil.ReadFile(filepath.Join(entryDir, CACHE_ENTRY_FILE))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		var entry *CacheEntry
+		err = json.Unmarshal(data, &entry)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s: %w", entryDir, err)
+		}
+		entry.Key = file.Name()
+		entry.Dir = entryDir
+		entry.LastUsed = file.ModTime()
+		entry.Size, err = dirSize(entryDir)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return entries, nil
+}
+
+// GCCache removes the least recently used entries until the total size
+// is within maxSize, and temp dirs left by interrupted unpacks.
+// If maxSize <= 0, DEFAULT_CACHE_MAX_SIZE is used.
+// NOTE: targets still replacing modules with a removed entry need to be unpacked again.
+func GCCache(cacheDir string, maxSize int64) (removed []*CacheEntry, err error) {
+	cacheDir, err = getCacheDir(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	if maxSize <= 0 {
+		maxSize = DEFAULT_CACHE_MAX_SIZE
+	}
+	err = removeStaleCacheTmp(cacheDir, time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ListCache(cacheDir)
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, entry := range entries {
+		total += entry.Size
+		if total <= maxSize {
+			continue
+		}
+		err := removeCacheEntry(cacheDir, entry.Dir)
+		if err != nil {
+			return nil, err
+		}
+		removed = append(removed, entry)
+	}
+	return removed, nil
+}
+
+// CleanCache removes cacheDir entirely
+func CleanCache(cacheDir string) error {
+	cacheDir, err := getCacheDir(cacheDir)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(cacheDir)
+}
+
+// removeCacheEntry renames the entry away before removing it,
+// so it is never seen partially removed
+func removeCacheEntry(cacheDir string, entryDir string) error {
+	// wait for unpacks using the entry
+	unlock, err := lockDirs(writefs.SysFS{}, 0, entryDir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	tmpDir, err := ioutil.TempDir(cacheDir, cacheTmpPrefix)
+	if err != nil {
+		return err
+	}
+	trashDir := filepath.Join(tmpDir, "trash")
+	err = os.Rename(entryDir, trashDir)
+	if err != nil {
+		os.RemoveAll(tmpDir)
+		return err
+	}
+	return os.RemoveAll(tmpDir)
+}
+
+func removeStaleCacheTmp(cacheDir string, age time.Duration) error {
+	files, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, file := range files {
+		if !strings.HasPrefix(file.Name(), cacheTmpPrefix) || time.Since(file.ModTime()) < age {
+			continue
+		}
+		err := os.RemoveAll(filepath.Join(cacheDir, file.Name()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
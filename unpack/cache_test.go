@@ -0,0 +1,148 @@
+package unpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// go test -run TestUnpackHostDirCache -v ./unpack
+func TestUnpackHostDirCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, "cache")
+	dirA := filepath.Join(tmpDir, "a")
+	dirB := filepath.Join(tmpDir, "b")
+	newTestTarget(t, writefs.SysFS{}, dirA, false)
+	newTestTarget(t, writefs.SysFS{}, dirB, false)
+
+	for _, dir := range []string{dirA, dirB} {
+		_, err := UnpackFromBase64Decode(testPack, dir, &Options{CacheDir: cacheDir, GoVersion: "1.18"})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := ListCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "len(entries)", 1, len(entries))
+	}
+	entry := entries[0]
+	if entry.GoVersion != "1.18" || entry.Size == 0 || len(entry.Modules) == 0 {
+		t.Fatalf("expect entry filled, actual:%+v", entry)
+	}
+	expectReplace := "golang.org/x/tools => " + filepath.Join(entry.Dir, "vendor", "golang.org/x/tools")
+	for _, dir := range []string{dirA, dirB} {
+		goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(goMod), expectReplace) {
+			t.Fatalf("expect go.mod contains %q, actual:%s", expectReplace, goMod)
+		}
+	}
+	_, err = os.Stat(filepath.Join(entry.Dir, "vendor/golang.org/x/tools/cover/profile.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := os.ReadDir(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range files {
+		if strings.HasPrefix(file.Name(), cacheTmpPrefix) {
+			t.Fatalf("expect no temp dir left in cache, actual:%s", file.Name())
+		}
+	}
+}
+
+// go test -run TestGCCache -v ./unpack
+func TestGCCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, "cache")
+	dir := filepath.Join(tmpDir, "target")
+	newTestTarget(t, writefs.SysFS{}, dir, false)
+
+	// one entry per go version
+	for i, goVersion := range []string{"1.18", "1.19"} {
+		_, err := UnpackFromBase64Decode(testPack, dir, &Options{CacheDir: cacheDir, GoVersion: goVersion})
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries, err := ListCache(cacheDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		used := time.Now().Add(time.Duration(i-2) * time.Hour)
+		for _, entry := range entries {
+			if entry.GoVersion == goVersion {
+				os.Chtimes(entry.Dir, used, used)
+			}
+		}
+	}
+	entries, err := ListCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].GoVersion != "1.19" {
+		t.Fatalf("expect 2 entries, 1.19 first, actual:%+v", entries)
+	}
+
+	removed, err := GCCache(cacheDir, entries[0].Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 1 || removed[0].GoVersion != "1.18" {
+		t.Fatalf("expect least recently used 1.18 removed, actual:%+v", removed)
+	}
+	entries, err = ListCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].GoVersion != "1.19" {
+		t.Fatalf("expect 1.19 kept, actual:%+v", entries)
+	}
+
+	err = CleanCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(cacheDir)
+	if !os.IsNotExist(err) {
+		t.Fatalf("expect cache removed, actual:%v", err)
+	}
+}
+
+// go test -run TestPackDigestWithoutDigest -v ./unpack
+func TestPackDigestWithoutDigest(t *testing.T) {
+	newPack := func(content string) *packtest.Pack {
+		return &packtest.Pack{
+			Modules: map[string]string{"example.com/m": "v1.0.0"},
+			Files:   map[string]string{"vendor/example.com/m/m.go": content},
+		}
+	}
+	digestA, _, err := readPackDigest(packtest.New(t, newPack("package m\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	digestB, _, err := readPackDigest(packtest.New(t, newPack("package m\n\nvar X int\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestA == digestB {
+		t.Fatalf("expect %s = %+v, actual:%+v", "digests of packs differing in content", "different", digestA)
+	}
+	digestA2, _, err := readPackDigest(packtest.New(t, newPack("package m\n")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digestA != digestA2 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "digest of the same pack", digestA, digestA2)
+	}
+}
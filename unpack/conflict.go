@@ -0,0 +1,203 @@
+package unpack
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"golang.org/x/mod/modfile"
+	"golang.org/x/mod/semver"
+)
+
+// ConflictPolicy decides which version wins when the target
+// requires a module at a version different from the pack
+type ConflictPolicy string
+
+const (
+	ConflictPolicy_Default           ConflictPolicy = ""                     // reuse existing packages, update go.mod to the pack version, even if older, see ConflictDecision.Downgrade
+	ConflictPolicy_KeepTarget        ConflictPolicy = "keep-target"          // do not unpack the module
+	ConflictPolicy_TakePack          ConflictPolicy = "take-pack"            // override with the pack version
+	ConflictPolicy_TakeNewer         ConflictPolicy = "take-newer"           // the higher version wins, like MVS
+	ConflictPolicy_FailOnDowngrade   ConflictPolicy = "fail-on-downgrade"    // take pack, fail if it is older than the target
+	ConflictPolicy_FailOnAnyMismatch ConflictPolicy = "fail-on-any-mismatch" // fail if versions differ
+)
+
+func ParseConflictPolicy(s string) (ConflictPolicy, error) {
+	policy := ConflictPolicy(s)
+	switch policy {
+	case ConflictPolicy_Default, ConflictPolicy_KeepTarget, ConflictPolicy_TakePack, ConflictPolicy_TakeNewer, ConflictPolicy_FailOnDowngrade, ConflictPolicy_FailOnAnyMismatch:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unknown conflict policy: %s, available: keep-target, take-pack, take-newer, fail-on-downgrade, fail-on-any-mismatch", s)
+}
+
+type ConflictAction string
+
+const (
+	ConflictAction_TakePack   ConflictAction = "take-pack"
+	ConflictAction_KeepTarget ConflictAction = "keep-target"
+)
+
+// ConflictDecision records how the version of a module is decided
+type ConflictDecision struct {
+	Path          string
+	TargetVersion string `json:",omitempty"` // empty if the target does not require the module
+	PackVersion   string
+	Policy        ConflictPolicy `json:",omitempty"`
+	Action        ConflictAction
+	Override      bool `json:",omitempty"` // existing packages are overridden by the pack
+	Downgrade     bool `json:",omitempty"` // the pack version taken is older than the target
+	Reason        string
+}
+
+func (c *ConflictDecision) String() string {
+	targetVersion := c.TargetVersion
+	if targetVersion == "" {
+		targetVersion = "none"
+	}
+	policy := c.Policy
+	if policy == "" {
+		policy = "default"
+	}
+	action := string(c.Action)
+	if c.Downgrade {
+		action += " DOWNGRADE"
+	}
+	return fmt.Sprintf("%s target=%s pack=%s policy=%s => %s (%s)", c.Path, targetVersion, c.PackVersion, policy, action, c.Reason)
+}
+
+// Downgrades returns decisions taking a pack version older than the target
+func Downgrades(decisions []*ConflictDecision) []*ConflictDecision {
+	var downgrades []*ConflictDecision
+	for _, decision := range decisions {
+		if decision.Downgrade {
+			downgrades = append(downgrades, decision)
+		}
+	}
+	return downgrades
+}
+
+func (c *Options) conflictPolicyOf(module string) ConflictPolicy {
+	if policy, ok := c.ModuleConflictPolicies[module]; ok {
+		return policy
+	}
+	return c.ConflictPolicy
+}
+
+// readTargetVersions reads required versions from dir/go.mod
+func readTargetVersions(wfs writefs.FS, dir string) (map[string]string, error) {
+	goModFile := path.Join(dir, "go.mod")
+	content, err := writefs.ReadFile(wfs, goModFile)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	f, err := modfile.ParseLax(goModFile, content, nil)
+	if err != nil {
+		return nil, err
+	}
+	versions := make(map[string]string, len(f.Require))
+	for _, req := range f.Require {
+		versions[req.Mod.Path] = req.Mod.Version
+	}
+	return versions, nil
+}
+
+// readTargetReplaces reads replaced paths from dir/go.mod, replaces of a single version are ignored
+func readTargetReplaces(wfs writefs.FS, dir string) (map[string]string, error) {
+	goModFile := path.Join(dir, "go.mod")
+	content, err := writefs.ReadFile(wfs, goModFile)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	f, err := modfile.Parse(goModFile, content, nil)
+	if err != nil {
+		return nil, err
+	}
+	replaces := make(map[string]string, len(f.Replace))
+	for _, replace := range f.Replace {
+		if replace.Old.Version != "" {
+			continue
+		}
+		replaces[replace.Old.Path] = replace.New.Path
+	}
+	return replaces, nil
+}
+
+// decideConflicts decides for all modules before anything is changed,
+// so that a failing policy reports every mismatch at once
+func decideConflicts(modules []string, versionMapping map[string]string, targetVersions map[string]string, opts *Options) ([]*ConflictDecision, error) {
+	decisions := make([]*ConflictDecision, 0, len(modules))
+	var errs []string
+	for _, module := range modules {
+		decision, err := decideConflict(module, targetVersions[module], versionMapping[module], opts.conflictPolicyOf(module))
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		decisions = append(decisions, decision)
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("version conflicts:\n  %s", strings.Join(errs, "\n  "))
+	}
+	return decisions, nil
+}
+
+func decideConflict(module string, targetVersion string, packVersion string, policy ConflictPolicy) (*ConflictDecision, error) {
+	decision := &ConflictDecision{
+		Path:          module,
+		TargetVersion: targetVersion,
+		PackVersion:   packVersion,
+		Policy:        policy,
+		Action:        ConflictAction_TakePack,
+	}
+	if targetVersion == "" {
+		decision.Override = policy == ConflictPolicy_TakePack
+		decision.Reason = "not required by target"
+		return decision, nil
+	}
+	if targetVersion == packVersion {
+		decision.Reason = "same version"
+		return decision, nil
+	}
+	cmp := semver.Compare(packVersion, targetVersion)
+	switch policy {
+	case ConflictPolicy_Default:
+		decision.Reason = "default policy"
+		if cmp < 0 {
+			decision.Reason = "default policy, go.mod is downgraded"
+		}
+	case ConflictPolicy_KeepTarget:
+		decision.Action = ConflictAction_KeepTarget
+		decision.Reason = "target version kept"
+	case ConflictPolicy_TakePack:
+		decision.Override = true
+		decision.Reason = "pack version taken"
+	case ConflictPolicy_TakeNewer:
+		if cmp > 0 {
+			decision.Override = true
+			decision.Reason = "pack is newer"
+		} else {
+			decision.Action = ConflictAction_KeepTarget
+			decision.Reason = "target is newer"
+		}
+	case ConflictPolicy_FailOnDowngrade:
+		if cmp < 0 {
+			return nil, fmt.Errorf("%s: pack %s would downgrade target %s", module, packVersion, targetVersion)
+		}
+		decision.Override = true
+		decision.Reason = "pack is newer"
+	case ConflictPolicy_FailOnAnyMismatch:
+		return nil, fmt.Errorf("%s: pack %s mismatches target %s", module, packVersion, targetVersion)
+	default:
+		return nil, fmt.Errorf("%s: unknown conflict policy: %s", module, policy)
+	}
+	decision.Downgrade = decision.Action == ConflictAction_TakePack && cmp < 0
+	return decision, nil
+}
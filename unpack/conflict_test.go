@@ -0,0 +1,115 @@
+package unpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestDecideConflict -v ./unpack
+func TestDecideConflict(t *testing.T) {
+	tests := []struct {
+		target    string
+		pack      string
+		policy    ConflictPolicy
+		action    ConflictAction
+		override  bool
+		downgrade bool
+		err       string
+	}{
+		{"", "v1.0.0", ConflictPolicy_KeepTarget, ConflictAction_TakePack, false, false, ""},
+		{"v1.0.0", "v1.0.0", ConflictPolicy_FailOnAnyMismatch, ConflictAction_TakePack, false, false, ""},
+		{"v1.1.0", "v1.0.0", ConflictPolicy_Default, ConflictAction_TakePack, false, true, ""},
+		{"v1.0.0", "v1.1.0", ConflictPolicy_Default, ConflictAction_TakePack, false, false, ""},
+		{"v1.1.0", "v1.0.0", ConflictPolicy_KeepTarget, ConflictAction_KeepTarget, false, false, ""},
+		{"v1.1.0", "v1.0.0", ConflictPolicy_TakePack, ConflictAction_TakePack, true, true, ""},
+		{"v1.1.0", "v1.0.0", ConflictPolicy_TakeNewer, ConflictAction_KeepTarget, false, false, ""},
+		{"v1.0.0", "v1.1.0", ConflictPolicy_TakeNewer, ConflictAction_TakePack, true, false, ""},
+		{"v1.0.0", "v1.1.0", ConflictPolicy_FailOnDowngrade, ConflictAction_TakePack, true, false, ""},
+		{"v1.1.0", "v1.0.0", ConflictPolicy_FailOnDowngrade, "", false, false, "would downgrade"},
+		{"v1.0.0", "v1.1.0", ConflictPolicy_FailOnAnyMismatch, "", false, false, "mismatches"},
+	}
+	for _, tt := range tests {
+		decision, err := decideConflict("a.b/c", tt.target, tt.pack, tt.policy)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expect %s %s->%s err = %s, actual:%v", tt.policy, tt.target, tt.pack, tt.err, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decision.Action != tt.action || decision.Override != tt.override || decision.Downgrade != tt.downgrade {
+			t.Fatalf("expect %s %s->%s = %s override=%v downgrade=%v, actual:%+v", tt.policy, tt.target, tt.pack, tt.action, tt.override, tt.downgrade, decision)
+		}
+	}
+}
+
+// go test -run TestUnpackConflictPolicy -v ./unpack
+func TestUnpackConflictPolicy(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod := "module example\n\ngo 1.14\n\nrequire golang.org/x/tools v0.9.0\n"
+
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+	err = writefs.WriteFile(mfs, "/target/go.mod", []byte(goMod))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = BeginFS(fs, mfs, "/target", &Options{GoVersion: "1.18", ConflictPolicy: ConflictPolicy_FailOnDowngrade})
+	expectErr := "golang.org/x/tools: pack v0.8.0 would downgrade target v0.9.0"
+	if err == nil || !strings.Contains(err.Error(), expectErr) {
+		t.Fatalf("expect err = %s, actual:%v", expectErr, err)
+	}
+
+	tx, err := BeginFS(fs, mfs, "/target", &Options{
+		GoVersion:      "1.18",
+		ConflictPolicy: ConflictPolicy_FailOnDowngrade,
+		ModuleConflictPolicies: map[string]ConflictPolicy{
+			"golang.org/x/tools": ConflictPolicy_KeepTarget,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var toolsDecision *ConflictDecision
+	for _, decision := range tx.Decisions() {
+		if decision.Path == "golang.org/x/tools" {
+			toolsDecision = decision
+		}
+	}
+	if toolsDecision == nil || toolsDecision.Action != ConflictAction_KeepTarget {
+		t.Fatalf("expect golang.org/x/tools decision = %s, actual:%+v", ConflictAction_KeepTarget, toolsDecision)
+	}
+	newGoMod, err := writefs.ReadFile(mfs, "/target/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(newGoMod), "golang.org/x/tools v0.9.0") {
+		t.Fatalf("expect go.mod keeps golang.org/x/tools v0.9.0, actual:%s", newGoMod)
+	}
+	_, err = mfs.Stat("/target/vendor/golang.org/x/tools")
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect golang.org/x/tools not unpacked, actual:%v", err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the default policy takes the pack, but reports the downgrade
+	tx, err = BeginFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	downgrades := Downgrades(tx.Result().Decisions)
+	if len(downgrades) != 1 || downgrades[0].Path != "golang.org/x/tools" {
+		t.Fatalf("expect %s = %+v, actual:%+v", "downgrades", "golang.org/x/tools", downgrades)
+	}
+}
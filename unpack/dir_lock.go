@@ -0,0 +1,84 @@
+package unpack
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/flock"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// DEFAULT_LOCK_TIMEOUT is how long unpack waits for other processes
+// unpacking into the same target or host dir
+const DEFAULT_LOCK_TIMEOUT = 5 * time.Minute
+
+// dirLockFile is the lock file of dir, kept next to dir so the target is not polluted,
+// and every user sharing dir, e.g. a checkout or NonVendorHostDir, sees the same lock
+func dirLockFile(absDir string) string {
+	return absDir + ".lock"
+}
+
+// writtenPaths returns paths unpack writes into, except cached host dirs, which are
+// locked by cachedHostDir once known. The per-target dir in the cache is covered by dir.
+func writtenPaths(dir string, opts *Options) ([]string, error) {
+	paths := []string{dir}
+	if opts == nil {
+		return paths, nil
+	}
+	paths = append(paths, opts.NonVendorHostDir, opts.GoWorkFile)
+	if opts.UseModCache {
+		modCacheDir, err := getModCacheDir(opts.ModCacheDir)
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, modCacheDir)
+	}
+	return paths, nil
+}
+
+// lockDirs locks dirs, or files, in a fixed order, so processes sharing some of them
+// never deadlock. Only SysFS is locked, timeout < 0 means no locking.
+func lockDirs(wfs writefs.FS, timeout time.Duration, dirs ...string) (unlock func(), err error) {
+	unlock = func() {}
+	if !isSysFS(wfs) || timeout < 0 {
+		return unlock, nil
+	}
+	if timeout == 0 {
+		timeout = DEFAULT_LOCK_TIMEOUT
+	}
+	absDirs := make([]string, 0, len(dirs))
+	seen := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		if seen[absDir] {
+			continue
+		}
+		seen[absDir] = true
+		absDirs = append(absDirs, absDir)
+	}
+	sort.Strings(absDirs)
+
+	var locks []*flock.File
+	unlock = func() {
+		for i := len(locks) - 1; i >= 0; i-- {
+			locks[i].Unlock()
+		}
+	}
+	for _, absDir := range absDirs {
+		l, err := flock.Lock(dirLockFile(absDir), timeout)
+		if err != nil {
+			unlock()
+			return nil, fmt.Errorf("waiting for another unpack of %s: %w", absDir, err)
+		}
+		locks = append(locks, l)
+	}
+	return unlock, nil
+}
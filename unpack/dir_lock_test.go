@@ -0,0 +1,233 @@
+package unpack
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/flock"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+func expectNoDuplicateLines(t *testing.T, file string) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		if line == "" {
+			continue
+		}
+		if seen[line] {
+			t.Fatalf("expect no duplicate line in %s, actual:%q", file, content)
+		}
+		seen[line] = true
+	}
+}
+
+// go test -run TestUnpackConcurrent -v ./unpack
+func TestUnpackConcurrent(t *testing.T) {
+	dir := t.TempDir()
+	newTestTarget(t, writefs.SysFS{}, dir, true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := UnpackFromBase64Decode(testPack, dir, &Options{GoVersion: "1.18"})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(goMod), "golang.org/x/tools v0.8.0"); n != 1 {
+		t.Fatalf("expect %s = %+v, actual:%+v, go.mod:%s", "requires", 1, n, goMod)
+	}
+	expectNoDuplicateLines(t, filepath.Join(dir, "go.sum"))
+	modulesTxt, err := os.ReadFile(filepath.Join(dir, "vendor", "modules.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(modulesTxt), "# golang.org/x/tools "); n != 1 {
+		t.Fatalf("expect %s = %+v, actual:%+v, modules.txt:%s", "modules.txt stanzas", 1, n, modulesTxt)
+	}
+	_, err = os.Stat(filepath.Join(dir, "vendor/golang.org/x/tools/cover/profile.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock, err := ReadLock(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lock.Module("golang.org/x/tools") == nil {
+		t.Fatalf("expect lock records golang.org/x/tools, actual:%+v", lock)
+	}
+}
+
+// go test -run TestUnpackConcurrentHostDir -v ./unpack
+func TestUnpackConcurrentHostDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	hostDir := filepath.Join(tmpDir, "host")
+
+	var dirs []string
+	for i := 0; i < 4; i++ {
+		dir := filepath.Join(tmpDir, "target"+string(rune('a'+i)))
+		newTestTarget(t, writefs.SysFS{}, dir, false)
+		dirs = append(dirs, dir)
+	}
+	var wg sync.WaitGroup
+	for _, dir := range dirs {
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			_, err := UnpackFromBase64Decode(testPack, dir, &Options{NonVendorHostDir: hostDir, GoVersion: "1.18"})
+			if err != nil {
+				t.Error(err)
+			}
+		}(dir)
+	}
+	wg.Wait()
+
+	expectReplace := "golang.org/x/tools => " + filepath.Join(hostDir, "vendor", "golang.org/x/tools")
+	for _, dir := range dirs {
+		goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(goMod), expectReplace) {
+			t.Fatalf("expect go.mod contains %q, actual:%s", expectReplace, goMod)
+		}
+		expectNoDuplicateLines(t, filepath.Join(dir, "go.sum"))
+	}
+	_, err := os.Stat(filepath.Join(hostDir, "vendor/golang.org/x/tools/cover/profile.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// go test -run TestUnpackLockTimeout -v ./unpack
+func TestUnpackLockTimeout(t *testing.T) {
+	dir := t.TempDir()
+	newTestTarget(t, writefs.SysFS{}, dir, true)
+
+	l, err := flock.Lock(dirLockFile(dir), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Unlock()
+	// next to dir and writable by other users sharing dir
+	info, err := os.Stat(dir + ".lock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0666 {
+		t.Fatalf("expect %s = %v, actual:%v", "lock file mode", os.FileMode(0666), info.Mode().Perm())
+	}
+	_, err = UnpackFromBase64Decode(testPack, dir, &Options{GoVersion: "1.18", LockTimeout: 50 * time.Millisecond})
+	if !flock.IsTimeout(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "timeout", true, err)
+	}
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(goMod) != "module example\n\ngo 1.18\n" {
+		t.Fatalf("expect go.mod untouched, actual:%s", goMod)
+	}
+}
+
+// go test -run TestUnpackCachedHostDirLock -v ./unpack
+func TestUnpackCachedHostDirLock(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, "cache")
+	dirA := filepath.Join(tmpDir, "a")
+	dirB := filepath.Join(tmpDir, "b")
+	newTestTarget(t, writefs.SysFS{}, dirA, false)
+	newTestTarget(t, writefs.SysFS{}, dirB, false)
+	res, err := UnpackFromBase64Decode(testPack, dirA, &Options{GoVersion: "1.18", CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// another target sharing the entry waits for its holder
+	l, err := flock.Lock(dirLockFile(res.HostDir), time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = UnpackFromBase64Decode(testPack, dirB, &Options{GoVersion: "1.18", CacheDir: cacheDir, LockTimeout: 50 * time.Millisecond})
+	if !flock.IsTimeout(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "timeout", true, err)
+	}
+	l.Unlock()
+	resB, err := UnpackFromBase64Decode(testPack, dirB, &Options{GoVersion: "1.18", CacheDir: cacheDir, LockTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resB.HostDir != res.HostDir {
+		t.Fatalf("expect %s = %+v, actual:%+v", "host dir", res.HostDir, resB.HostDir)
+	}
+}
+
+// go test -run TestUnpackLockAcrossProcesses -v ./unpack
+func TestUnpackLockAcrossProcesses(t *testing.T) {
+	if file := os.Getenv("GO_VENDOR_PACK_TEST_LOCK"); file != "" {
+		// the child process
+		unlock, err := lockDirs(writefs.SysFS{}, 100*time.Millisecond, file)
+		if err != nil {
+			fmt.Print(err)
+			os.Exit(2)
+		}
+		unlock()
+		os.Exit(0)
+	}
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, "target")
+	goWorkFile := filepath.Join(tmpDir, "go.work")
+	newTestTarget(t, writefs.SysFS{}, dir, false)
+	lockInChild := func(file string) ([]byte, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestUnpackLockAcrossProcesses$")
+		cmd.Env = append(os.Environ(), "GO_VENDOR_PACK_TEST_LOCK="+file)
+		return cmd.CombinedOutput()
+	}
+
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := Begin(fs, dir, &Options{
+		UseGoWork:        true,
+		GoWorkFile:       goWorkFile,
+		NonVendorHostDir: filepath.Join(tmpDir, "host"),
+		GoVersion:        "1.18",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{dir, goWorkFile} {
+		out, err := lockInChild(file)
+		if err == nil || !strings.Contains(string(out), "waiting for another unpack") {
+			t.Fatalf("expect %s = %+v, actual:%v %s", file, "locked by the unpack", err, out)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := lockInChild(goWorkFile)
+	if err != nil {
+		t.Fatalf("expect %s = %+v, actual:%v %s", goWorkFile, "unlocked after commit", err, out)
+	}
+}
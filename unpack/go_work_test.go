@@ -0,0 +1,111 @@
+package unpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// go test -run TestUnpackGoWork -v ./unpack
+func TestUnpackGoWork(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+	newTestTarget(t, writefs.SysFS{}, target, true)
+	err := os.WriteFile(filepath.Join(target, "main.go"), []byte("package main\n\nimport \"example.com/m\"\n\nfunc main() { println(m.Hello()) }\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := packtest.New(t, &packtest.Pack{
+		Digest:  "go-work-test",
+		Modules: map[string]string{"example.com/m": "v1.0.0"},
+		Files: map[string]string{
+			"vendor/example.com/m/m.go": "package m\n\nfunc Hello() string { return \"hello\" }\n",
+		},
+	})
+	t.Setenv("GOFLAGS", "-trimpath -mod=vendor")
+	env, err := UnpackGoWork(fs, target, &Options{CacheDir: filepath.Join(tmpDir, "cache"), GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(target, "go.work"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "target go.work", "not exist", err)
+	}
+	expectGoFlags := "GOFLAGS=-trimpath -mod=readonly"
+	var goWorkFile string
+	var hasGoFlags bool
+	for _, e := range env {
+		if strings.HasPrefix(e, "GOWORK=") {
+			goWorkFile = strings.TrimPrefix(e, "GOWORK=")
+		}
+		hasGoFlags = hasGoFlags || e == expectGoFlags
+	}
+	if !hasGoFlags {
+		t.Fatalf("expect %s = %+v, actual:%+v", "env", expectGoFlags, env)
+	}
+	if goWorkFile == "" || strings.HasPrefix(goWorkFile, target+string(filepath.Separator)) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "GOWORK", "outside the target", goWorkFile)
+	}
+	testGoBuild(t, target, env, ".")
+}
+
+// go test -run TestUnpackGoWorkTargetReplace -v ./unpack
+func TestUnpackGoWorkTargetReplace(t *testing.T) {
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target")
+	goMod := "module example\n\ngo 1.18\n\nrequire example.com/m v1.0.0\n\nreplace example.com/m => ./local/m\n"
+	files := map[string]string{
+		"go.mod":         goMod,
+		"go.sum":         "",
+		"main.go":        "package main\n\nimport \"example.com/m\"\n\nfunc main() { println(m.Hello()) }\n",
+		"local/m/go.mod": "module example.com/m\n\ngo 1.18\n",
+		// no Hello, building fails if the replace is used
+		"local/m/m.go": "package m\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(target, name)
+		err := os.MkdirAll(filepath.Dir(file), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = os.WriteFile(file, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	fs := packtest.New(t, &packtest.Pack{
+		Digest:  "go-work-replace-test",
+		Modules: map[string]string{"example.com/m": "v1.0.0"},
+		Files: map[string]string{
+			"vendor/example.com/m/m.go": "package m\n\nfunc Hello() string { return \"hello\" }\n",
+		},
+	})
+	env, err := UnpackGoWork(fs, target, &Options{NoCache: true, GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := os.ReadFile(filepath.Join(target, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != goMod {
+		t.Fatalf("expect %s = %q, actual:%q", "go.mod", goMod, string(content))
+	}
+	for _, e := range env {
+		if !strings.HasPrefix(e, "GOWORK=") {
+			continue
+		}
+		goWork, err := os.ReadFile(strings.TrimPrefix(e, "GOWORK="))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(goWork), "replace") {
+			t.Fatalf("expect %s = %+v, actual:%+v", "go.work", "no replace", string(goWork))
+		}
+	}
+	testGoBuild(t, target, env, ".")
+}
@@ -0,0 +1,150 @@
+package helper
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"golang.org/x/mod/modfile"
+)
+
+type GoWorkUse struct {
+	Dir    string // disk path, absolute or relative to the go.work file
+	Module string // the module path, optional
+}
+
+// AddGoWorkUse creates or merges goWorkFile, adding a `use` directive for each entry.
+// replace directives of the used modules are dropped, because the go
+// command rejects a workspace module that is also replaced at all versions.
+// goVersion is used only when goWorkFile does not exist, e.g. 1.18
+func AddGoWorkUse(goWorkFile string, goVersion string, uses []*GoWorkUse) error {
+	return AddGoWorkUseFS(writefs.SysFS{}, goWorkFile, goVersion, uses)
+}
+
+func AddGoWorkUseFS(wfs writefs.FS, goWorkFile string, goVersion string, uses []*GoWorkUse) error {
+	content, err := writefs.ReadFile(wfs, goWorkFile)
+	if err != nil {
+		if !writefs.IsNotExist(err) {
+			return err
+		}
+		if goVersion == "" {
+			return fmt.Errorf("creating %s: requires go version", goWorkFile)
+		}
+		content = []byte(fmt.Sprintf("go %s\n", goVersion))
+	}
+	workFile, err := modfile.ParseWork(goWorkFile, content, nil)
+	if err != nil {
+		return err
+	}
+	for _, use := range uses {
+		err := workFile.AddUse(use.Dir, use.Module)
+		if err != nil {
+			return err
+		}
+		if use.Module == "" {
+			continue
+		}
+		for _, replace := range workFile.Replace {
+			if replace.Old.Path != use.Module || replace.Old.Version != "" {
+				continue
+			}
+			err := workFile.DropReplace(replace.Old.Path, replace.Old.Version)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	workFile.SortBlocks()
+	workFile.Cleanup()
+	err = wfs.MkdirAll(filepath.Dir(goWorkFile), 0755)
+	if err != nil {
+		return err
+	}
+	return writefs.WriteFile(wfs, goWorkFile, modfile.Format(workFile.Syntax))
+}
+
+// GoWorkReplace is a replace directive of go.work
+type GoWorkReplace struct {
+	Path       string
+	Version    string `json:",omitempty"`
+	NewPath    string
+	NewVersion string `json:",omitempty"`
+}
+
+// ReadGoWorkFS reads the uses of goWorkFile as absolute dirs, and its replaces,
+// exists is false if goWorkFile does not exist
+func ReadGoWorkFS(wfs writefs.FS, goWorkFile string) (uses map[string]bool, replaces []*GoWorkReplace, exists bool, err error) {
+	workFile, err := readGoWorkFile(wfs, goWorkFile)
+	if err != nil || workFile == nil {
+		return nil, nil, false, err
+	}
+	uses = make(map[string]bool, len(workFile.Use))
+	for _, use := range workFile.Use {
+		uses[absGoWorkUse(goWorkFile, use.Path)] = true
+	}
+	for _, replace := range workFile.Replace {
+		replaces = append(replaces, &GoWorkReplace{
+			Path:       replace.Old.Path,
+			Version:    replace.Old.Version,
+			NewPath:    replace.New.Path,
+			NewVersion: replace.New.Version,
+		})
+	}
+	return uses, replaces, true, nil
+}
+
+// RevertGoWorkFS drops the uses of dirs from goWorkFile and adds back replaces,
+// empty reports whether goWorkFile is left without any use or replace
+func RevertGoWorkFS(wfs writefs.FS, goWorkFile string, dirs []string, replaces []*GoWorkReplace) (empty bool, err error) {
+	workFile, err := readGoWorkFile(wfs, goWorkFile)
+	if err != nil || workFile == nil {
+		return false, err
+	}
+	drop := make(map[string]bool, len(dirs))
+	for _, dir := range dirs {
+		drop[filepath.Clean(dir)] = true
+	}
+	for _, use := range workFile.Use {
+		if !drop[absGoWorkUse(goWorkFile, use.Path)] {
+			continue
+		}
+		err := workFile.DropUse(use.Path)
+		if err != nil {
+			return false, err
+		}
+	}
+	for _, replace := range replaces {
+		err := workFile.AddReplace(replace.Path, replace.Version, replace.NewPath, replace.NewVersion)
+		if err != nil {
+			return false, err
+		}
+	}
+	workFile.SortBlocks()
+	workFile.Cleanup()
+	err = writefs.WriteFile(wfs, goWorkFile, modfile.Format(workFile.Syntax))
+	if err != nil {
+		return false, err
+	}
+	return len(workFile.Use) == 0 && len(workFile.Replace) == 0, nil
+}
+
+// readGoWorkFile returns nil if goWorkFile does not exist
+func readGoWorkFile(wfs writefs.FS, goWorkFile string) (*modfile.WorkFile, error) {
+	content, err := writefs.ReadFile(wfs, goWorkFile)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return modfile.ParseWork(goWorkFile, content, nil)
+}
+
+// absGoWorkUse resolves a use dir relative to goWorkFile
+func absGoWorkUse(goWorkFile string, dir string) string {
+	dir = filepath.FromSlash(dir)
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(filepath.Dir(goWorkFile), dir)
+	}
+	return filepath.Clean(dir)
+}
@@ -0,0 +1,56 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestAddGoWorkUseCreate -v ./unpack/helper
+func TestAddGoWorkUseCreate(t *testing.T) {
+	wfs := memfs.New()
+	err := AddGoWorkUseFS(wfs, "/work/go.work", "1.18", []*GoWorkUse{
+		{Dir: "."},
+		{Dir: "/host/vendor/example.com/a", Module: "example.com/a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := writefs.ReadFile(wfs, "/work/go.work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "go 1.18\n\nuse (\n\t.\n\t/host/vendor/example.com/a\n)\n"
+	if string(content) != expect {
+		t.Fatalf("expect %s = %q, actual:%q", `go.work`, expect, string(content))
+	}
+}
+
+// go test -run TestAddGoWorkUseMerge -v ./unpack/helper
+func TestAddGoWorkUseMerge(t *testing.T) {
+	wfs := memfs.New()
+	err := wfs.MkdirAll("/work", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writefs.WriteFile(wfs, "/work/go.work", []byte("go 1.20\n\nuse ./tools\n\nreplace example.com/a => ../a\n\nreplace example.com/b => ../b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = AddGoWorkUseFS(wfs, "/work/go.work", "1.18", []*GoWorkUse{
+		{Dir: "."},
+		{Dir: "/host/vendor/example.com/a", Module: "example.com/a"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := writefs.ReadFile(wfs, "/work/go.work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "go 1.20\n\nuse (\n\t.\n\t./tools\n\t/host/vendor/example.com/a\n)\n\nreplace example.com/b => ../b\n"
+	if string(content) != expect {
+		t.Fatalf("expect %s = %q, actual:%q", `go.work`, expect, string(content))
+	}
+}
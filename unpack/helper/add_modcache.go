@@ -0,0 +1,293 @@
+package helper
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"golang.org/x/mod/module"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// ErrPartialModule is returned by AddModCache when the pack
+// does not hold the whole module, e.g. only vendored packages
+var ErrPartialModule = errors.New("pack does not hold the whole module")
+
+type ModCacheOptions struct {
+	// GoMod is the original go.mod of the module, if empty,
+	// a minimal `module X` is generated, which only matches
+	// go.sum when the upstream go.mod is also minimal
+	GoMod []byte
+	// Sums are go.sum entries of the module, without the module prefix:
+	//    v1.0.0 h1:xxx
+	//    v1.0.0/go.mod h1:yyy
+	Sums []string
+	// ExcludeDirs are sub dirs(relative to module root) that belong to
+	// other nested modules
+	ExcludeDirs map[string]bool
+	Time        *time.Time
+}
+
+// AddModCache installs vendor/<module> from fs into the module cache rooted at modCacheDir:
+//
+//	<modCacheDir>/<escaped module>@<escaped version>/...
+//	<modCacheDir>/cache/download/<escaped module>/@v/<escaped version>.{info,mod,zip,ziphash}
+//
+// When the extracted dir and .ziphash both exist, the go command treats the module as
+// downloaded, so the packed files must be the whole module: their h1 hash, with go.mod,
+// must equal the h1 line of go.sum, otherwise ErrPartialModule is returned.
+// An already installed module is never modified, because the module cache is immutable.
+func AddModCache(modCacheDir string, module string, version string, fs packfs.FS, opts *ModCacheOptions) (added bool, err error) {
+	return AddModCacheFS(writefs.SysFS{}, modCacheDir, module, version, fs, opts)
+}
+
+func AddModCacheFS(wfs writefs.FS, modCacheDir string, mod string, version string, fs packfs.FS, opts *ModCacheOptions) (added bool, err error) {
+	if opts == nil {
+		opts = &ModCacheOptions{}
+	}
+	escPath, err := module.EscapePath(mod)
+	if err != nil {
+		return false, err
+	}
+	escVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return false, err
+	}
+	extractDir := path.Join(modCacheDir, escPath+"@"+escVersion)
+	downloadPrefix := path.Join(modCacheDir, "cache", "download", escPath, "@v", escVersion)
+	zipHashFile := downloadPrefix + ".ziphash"
+
+	extractExists, err := existsFS(wfs, extractDir)
+	if err != nil {
+		return false, err
+	}
+	zipHashExists, err := existsFS(wfs, zipHashFile)
+	if err != nil {
+		return false, err
+	}
+	if extractExists && zipHashExists {
+		if verboseLog {
+			log.Printf("module cache reuse: %s@%s", mod, version)
+		}
+		return false, nil
+	}
+
+	var zipHash string
+	var goModHash string
+	for _, sum := range opts.Sums {
+		sp := strings.SplitN(sum, " ", 2)
+		if len(sp) != 2 {
+			continue
+		}
+		switch sp[0] {
+		case version:
+			zipHash = strings.TrimSpace(sp[1])
+		case version + "/go.mod":
+			goModHash = strings.TrimSpace(sp[1])
+		}
+	}
+
+	goMod := opts.GoMod
+	if len(goMod) == 0 {
+		goMod = []byte(fmt.Sprintf("module %s\n", mod))
+	}
+	if goModHash != "" {
+		actualHash, err := hashGoMod(goMod)
+		if err != nil {
+			return false, err
+		}
+		if actualHash != goModHash {
+			if len(opts.GoMod) == 0 {
+				return false, fmt.Errorf("%s@%s: pack does not contain original go.mod, re-pack with module cache populated", mod, version)
+			}
+			return false, fmt.Errorf("%s@%s: go.mod checksum mismatch, go.sum: %s, pack: %s", mod, version, goModHash, actualHash)
+		}
+	}
+
+	files, err := listModuleFiles(fs, path.Join("vendor", mod), opts.ExcludeDirs)
+	if err != nil {
+		return false, err
+	}
+	contents := make(map[string][]byte, len(files))
+	for _, file := range files {
+		content, err := fs.ReadFile(path.Join("vendor", mod, file))
+		if err != nil {
+			return false, err
+		}
+		contents[file] = content
+	}
+	// the module zip always has go.mod at its root
+	if _, ok := contents["go.mod"]; !ok {
+		files = append(files, "go.mod")
+		sort.Strings(files)
+		contents["go.mod"] = goMod
+	}
+	if zipHash == "" {
+		return false, fmt.Errorf("%s@%s: no h1 line in go.sum to verify the packed files: %w", mod, version, ErrPartialModule)
+	}
+	actualZipHash, err := hashZipFiles(mod, version, files, contents)
+	if err != nil {
+		return false, err
+	}
+	if actualZipHash != zipHash {
+		return false, fmt.Errorf("%s@%s: packed files hash to %s, go.sum: %s: %w", mod, version, actualZipHash, zipHash, ErrPartialModule)
+	}
+
+	// the extracted dir
+	err = wfs.RemoveAll(extractDir)
+	if err != nil {
+		return false, err
+	}
+	for _, file := range files {
+		dst := path.Join(extractDir, file)
+		err := wfs.MkdirAll(path.Dir(dst), 0755)
+		if err != nil {
+			return false, err
+		}
+		err = writefs.WriteFile(wfs, dst, contents[file])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// the download files
+	zipData, err := zipModule(mod, version, files, contents)
+	if err != nil {
+		return false, err
+	}
+	info, err := json.Marshal(&modCacheInfo{Version: version, Time: opts.Time})
+	if err != nil {
+		return false, err
+	}
+	err = wfs.MkdirAll(path.Dir(downloadPrefix), 0755)
+	if err != nil {
+		return false, err
+	}
+	// .ziphash marks the download as complete, so write it last
+	downloads := []struct {
+		ext  string
+		data []byte
+	}{
+		{".info", info},
+		{".mod", goMod},
+		{".zip", zipData},
+		{".ziphash", []byte(zipHash + "\n")},
+	}
+	for _, d := range downloads {
+		err := writefs.WriteFile(wfs, downloadPrefix+d.ext, d.data)
+		if err != nil {
+			return false, fmt.Errorf("writing %s%s: %w", downloadPrefix, d.ext, err)
+		}
+	}
+	if verboseLog {
+		log.Printf("module cache added: %s@%s", mod, version)
+	}
+	return true, nil
+}
+
+// see $GOROOT/src/cmd/go/internal/modfetch/proxy.go
+//
+//	type RevInfo struct
+type modCacheInfo struct {
+	Version string
+	Time    *time.Time `json:",omitempty"`
+}
+
+// listModuleFiles returns all files under dir, relative to dir, sorted.
+// Symlinks are skipped, module zips made by the go command never hold them
+func listModuleFiles(fs packfs.FS, dir string, excludeDirs map[string]bool) ([]string, error) {
+	var files []string
+	var walk func(name string, relPath string) error
+	walk = func(name string, relPath string) error {
+		srcFiles, srcDirs, links, err := readEntriesWithLinks(fs, name)
+		if err != nil {
+			return err
+		}
+		for _, file := range srcFiles {
+			if links[file] {
+				continue
+			}
+			files = append(files, path.Join(relPath, file))
+		}
+		for _, subDir := range srcDirs {
+			subRel := path.Join(relPath, subDir)
+			if excludeDirs[subRel] {
+				continue
+			}
+			err := walk(path.Join(name, subDir), subRel)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(dir, "")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+func zipModule(mod string, version string, files []string, contents map[string][]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	prefix := mod + "@" + version + "/"
+	for _, file := range files {
+		w, err := zw.Create(prefix + file)
+		if err != nil {
+			return nil, err
+		}
+		_, err = w.Write(contents[file])
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func hashZipFiles(mod string, version string, files []string, contents map[string][]byte) (string, error) {
+	prefix := mod + "@" + version + "/"
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, prefix+file)
+	}
+	return dirhash.Hash1(names, func(name string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(contents[strings.TrimPrefix(name, prefix)])), nil
+	})
+}
+
+// same as $GOROOT/src/cmd/go/internal/modfetch/fetch.go
+//
+//	func goModSum(data []byte) (string, error)
+func hashGoMod(data []byte) (string, error) {
+	return dirhash.Hash1([]string{"go.mod"}, func(string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader(data)), nil
+	})
+}
+
+func existsFS(wfs writefs.FS, f string) (bool, error) {
+	_, err := wfs.Stat(f)
+	if err == nil {
+		return true, nil
+	}
+	if writefs.IsNotExist(err) {
+		return false, nil
+	}
+	return false, err
+}
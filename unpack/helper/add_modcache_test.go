@@ -0,0 +1,149 @@
+package helper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestAddModCache -v ./unpack/helper
+func TestAddModCache(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{Files: map[string]string{
+		"vendor/example.com/Upper/a.go":        "package upper\n",
+		"vendor/example.com/Upper/sub/b.go":    "package sub\n",
+		"vendor/example.com/Upper/nested/c.go": "package nested\n",
+	}})
+	goMod := []byte("module example.com/Upper\n")
+	goModHash, err := hashGoMod(goMod)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zipHash, err := hashZipFiles("example.com/Upper", "v1.0.0", []string{"a.go", "go.mod", "sub/b.go"}, map[string][]byte{
+		"a.go":     []byte("package upper\n"),
+		"go.mod":   goMod,
+		"sub/b.go": []byte("package sub\n"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wfs := memfs.New()
+	// the upstream module has more files than the pack
+	_, err = AddModCacheFS(wfs, "/modcache", "example.com/Upper", "v1.0.0", fs, &ModCacheOptions{
+		GoMod:       goMod,
+		Sums:        []string{"v1.0.0 h1:wholeModuleHash=", "v1.0.0/go.mod " + goModHash},
+		ExcludeDirs: map[string]bool{"nested": true},
+	})
+	if !errors.Is(err, ErrPartialModule) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", ErrPartialModule, err)
+	}
+	_, err = AddModCacheFS(wfs, "/modcache", "example.com/Upper", "v1.0.0", fs, &ModCacheOptions{
+		GoMod:       goMod,
+		Sums:        []string{"v1.0.0/go.mod " + goModHash},
+		ExcludeDirs: map[string]bool{"nested": true},
+	})
+	if !errors.Is(err, ErrPartialModule) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err without h1", ErrPartialModule, err)
+	}
+
+	added, err := AddModCacheFS(wfs, "/modcache", "example.com/Upper", "v1.0.0", fs, &ModCacheOptions{
+		GoMod:       goMod,
+		Sums:        []string{"v1.0.0 " + zipHash, "v1.0.0/go.mod " + goModHash},
+		ExcludeDirs: map[string]bool{"nested": true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !added {
+		t.Fatalf("expect %s = %+v, actual:%+v", `added`, true, added)
+	}
+
+	expectFile := func(name string, content string) {
+		t.Helper()
+		data, err := writefs.ReadFile(wfs, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != content {
+			t.Fatalf("expect %s = %q, actual:%q", name, content, string(data))
+		}
+	}
+	expectFile("/modcache/example.com/!upper@v1.0.0/a.go", "package upper\n")
+	expectFile("/modcache/example.com/!upper@v1.0.0/sub/b.go", "package sub\n")
+	expectFile("/modcache/cache/download/example.com/!upper/@v/v1.0.0.mod", string(goMod))
+	expectFile("/modcache/example.com/!upper@v1.0.0/go.mod", string(goMod))
+	expectFile("/modcache/cache/download/example.com/!upper/@v/v1.0.0.ziphash", zipHash+"\n")
+
+	_, err = wfs.Stat("/modcache/example.com/!upper@v1.0.0/nested")
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect nested module excluded, actual err: %v", err)
+	}
+
+	// second install is a no-op
+	added, err = AddModCacheFS(wfs, "/modcache", "example.com/Upper", "v1.0.0", fs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added {
+		t.Fatalf("expect %s = %+v, actual:%+v", `added`, false, added)
+	}
+}
+
+// go test -run TestAddModCacheGoModMismatch -v ./unpack/helper
+func TestAddModCacheGoModMismatch(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{Files: map[string]string{
+		"vendor/example.com/a/a.go": "package a\n",
+	}})
+	_, err := AddModCacheFS(memfs.New(), "/modcache", "example.com/a", "v1.0.0", fs, &ModCacheOptions{
+		Sums: []string{"v1.0.0/go.mod h1:notMatch="},
+	})
+	if err == nil {
+		t.Fatalf("expect error when pack has no go.mod")
+	}
+	if !strings.Contains(err.Error(), "pack does not contain original go.mod") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// go test -run TestAddModCacheSkipsSymlinks -v ./unpack/helper
+func TestAddModCacheSkipsSymlinks(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{
+		Files: map[string]string{
+			"vendor/example.com/a/a.go":         "package a\n",
+			"vendor/example.com/a/assets/x.txt": "x\n",
+		},
+		Symlinks: map[string]string{
+			"vendor/example.com/a/link.go": "a.go",
+			"vendor/example.com/a/static":  "assets",
+		},
+	})
+	goMod := []byte("module example.com/a\n")
+	// like module zips made by the go command
+	zipHash, err := hashZipFiles("example.com/a", "v1.0.0", []string{"a.go", "assets/x.txt", "go.mod"}, map[string][]byte{
+		"a.go":         []byte("package a\n"),
+		"assets/x.txt": []byte("x\n"),
+		"go.mod":       goMod,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wfs := memfs.New()
+	_, err = AddModCacheFS(wfs, "/modcache", "example.com/a", "v1.0.0", fs, &ModCacheOptions{
+		GoMod: goMod,
+		Sums:  []string{"v1.0.0 " + zipHash},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"link.go", "static"} {
+		_, err := wfs.Stat("/modcache/example.com/a@v1.0.0/" + name)
+		if !writefs.IsNotExist(err) {
+			t.Fatalf("expect %s = %+v, actual:%+v", name, "not exist", err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package helper
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -10,70 +11,112 @@ import (
 	"github.com/xhd2015/go-vendor-pack/go_cmd"
 	"github.com/xhd2015/go-vendor-pack/go_cmd/model"
 	"github.com/xhd2015/go-vendor-pack/writefs"
+	"golang.org/x/mod/modfile"
 )
 
 func AddVersionAndSum(dir string, mod string, version string, sum string, replace string) error {
 	return AddVersionAndSumFS(writefs.SysFS{}, dir, mod, version, sum, replace)
 }
 func AddVersionAndSumFS(fs writefs.FS, dir string, mod string, version string, sum string, replace string) error {
-	editGoMod := func(goModFile string) error {
-		err := go_cmd.GoModRequire(goModFile, mod, version)
-		if err != nil {
-			return err
+	goMod, err := AddRequireAndSumFS(fs, dir, mod, version, sum, replace)
+	if err != nil {
+		return err
+	}
+	return updateModulesTxt(fs, dir, mod, version, goMod)
+}
+
+// AddReplace adds `replace mod => replace` to dir/go.mod
+func AddReplace(dir string, mod string, replace string) error {
+	return AddReplaceFS(writefs.SysFS{}, dir, mod, replace)
+}
+
+func AddReplaceFS(fs writefs.FS, dir string, mod string, replace string) error {
+	_, err := editGoModFS(fs, filepath.Join(dir, "go.mod"), mod, "", replace)
+	return err
+}
+
+// editGoModFS adds `require mod version` if version is not empty,
+// and `replace mod => replace` if replace is not empty.
+// For SysFS, go.mod is edited by `go mod edit`, other FS
+// is edited in memory, which does not require the go command.
+func editGoModFS(fs writefs.FS, goModFile string, mod string, version string, replace string) (*model.GoMod, error) {
+	if _, ok := fs.(writefs.SysFS); ok {
+		// go mod edit
+		if version != "" {
+			err := go_cmd.GoModRequire(goModFile, mod, version)
+			if err != nil {
+				return nil, err
+			}
 		}
 		if replace != "" {
 			err := go_cmd.GoModReplace(goModFile, mod, replace)
 			if err != nil {
-				return err
+				return nil, err
 			}
 		}
-		return nil
+		return go_cmd.ParseGoMod(goModFile)
 	}
-	var goMod *model.GoMod
-	goModFile := filepath.Join(dir, "go.mod")
-	if _, ok := fs.(writefs.SysFS); ok {
-		// go mod edit
-		err := editGoMod(goModFile)
-		if err != nil {
-			return err
-		}
-		goMod, err = go_cmd.ParseGoMod(goModFile)
-		if err != nil {
-			return err
-		}
-	} else {
-		reader, err := fs.OpenFileRead(goModFile)
-		if err != nil {
-			return err
-		}
-		defer reader.Close()
-		content, err := ioutil.ReadAll(reader)
-		if err != nil {
-			return err
-		}
-		newContent, err := go_cmd.GoModEdit(string(content), editGoMod)
-		if err != nil {
-			return err
-		}
-		w, err := fs.OpenFileWrite(goModFile)
-		if err != nil {
-			return err
+	content, err := writefs.ReadFile(fs, goModFile)
+	if err != nil {
+		return nil, err
+	}
+	newContent, goMod, err := go_cmd.EditGoModContent(goModFile, content, func(f *modfile.File) error {
+		if version != "" {
+			err := f.AddRequire(mod, version)
+			if err != nil {
+				return err
+			}
 		}
-		defer w.Close()
-		_, err = w.Write([]byte(newContent))
-		if err != nil {
-			return err
+		if replace != "" {
+			err := f.AddReplace(mod, "", replace, "")
+			if err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(newContent, content) {
+		return goMod, nil
+	}
+	err = writefs.WriteFile(fs, goModFile, newContent)
+	if err != nil {
+		return nil, err
+	}
+	return goMod, nil
+}
 
-		goMod, err = go_cmd.ParseGoModContent(newContent)
-		if err != nil {
-			return err
-		}
+// AddRequireAndSum is like AddVersionAndSum, but leaves vendor/modules.txt untouched
+func AddRequireAndSum(dir string, mod string, version string, sum string, replace string) error {
+	_, err := AddRequireAndSumFS(writefs.SysFS{}, dir, mod, version, sum, replace)
+	return err
+}
+
+func AddRequireAndSumFS(fs writefs.FS, dir string, mod string, version string, sum string, replace string) (*model.GoMod, error) {
+	if mod == "" {
+		return nil, fmt.Errorf("requires module")
+	}
+	if version == "" {
+		return nil, fmt.Errorf("requires version")
+	}
+	goMod, err := editGoModFS(fs, filepath.Join(dir, "go.mod"), mod, version, replace)
+	if err != nil {
+		return nil, err
 	}
 
 	addGoSum := func() error {
-		// append to go sum
+		// append to go sum, lines already present are skipped
 		sumFile := filepath.Join(dir, "go.sum")
+		content, err := writefs.ReadFile(fs, sumFile)
+		if err != nil && !writefs.IsNotExist(err) {
+			return err
+		}
+		sum = missingLines(string(content), sum)
+		if sum == "" {
+			return nil
+		}
 
 		writer, err := fs.OpenFileAppend(sumFile)
 		if err != nil {
@@ -81,6 +124,9 @@ func AddVersionAndSumFS(fs writefs.FS, dir string, mod string, version string, s
 		}
 		defer writer.Close()
 
+		if len(content) > 0 && !bytes.HasSuffix(content, []byte("\n")) {
+			writer.Write([]byte("\n"))
+		}
 		writer.Write([]byte(sum))
 		if !strings.HasSuffix(sum, "\n") {
 			writer.Write([]byte("\n"))
@@ -90,10 +136,13 @@ func AddVersionAndSumFS(fs writefs.FS, dir string, mod string, version string, s
 	if sum != "" {
 		err := addGoSum()
 		if err != nil {
-			return fmt.Errorf("updating go.sum: %w", err)
+			return nil, fmt.Errorf("updating go.sum: %w", err)
 		}
 	}
+	return goMod, nil
+}
 
+func updateModulesTxt(fs writefs.FS, dir string, mod string, version string, goMod *model.GoMod) error {
 	// update modules.txt
 	modulesFile := filepath.Join(dir, "vendor/modules.txt")
 	modFileReader, err := fs.OpenFileRead(modulesFile)
@@ -183,6 +232,9 @@ func AddVersionAndSumFS(fs writefs.FS, dir string, mod string, version string, s
 	}
 
 	newModulesContent := strings.Join(lines, "\n")
+	if newModulesContent == string(modulesContent) {
+		return nil
+	}
 
 	w, err := fs.OpenFileWrite(modulesFile)
 	if err != nil {
@@ -198,3 +250,21 @@ func AddVersionAndSumFS(fs writefs.FS, dir string, mod string, version string, s
 	}
 	return nil
 }
+
+// missingLines returns lines of add not in content
+func missingLines(content string, add string) string {
+	existing := make(map[string]bool)
+	for _, line := range strings.Split(content, "\n") {
+		existing[strings.TrimSpace(line)] = true
+	}
+	var missing []string
+	for _, line := range strings.Split(add, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || existing[line] {
+			continue
+		}
+		existing[line] = true
+		missing = append(missing, line)
+	}
+	return strings.Join(missing, "\n")
+}
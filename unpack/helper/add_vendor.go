@@ -1,23 +1,78 @@
 package helper
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/xhd2015/go-vendor-pack/packfs"
 	"github.com/xhd2015/go-vendor-pack/writefs"
 )
 
+type PackageAction string
+
+const (
+	PackageAction_Add      PackageAction = "add"
+	PackageAction_Override PackageAction = "override"
+	PackageAction_Reuse    PackageAction = "reuse"
+)
+
+// PackageChange describes how a package dir is copied
+type PackageChange struct {
+	Dir         string // relative to the module root, "" for the root
+	Action      PackageAction
+	AddFiles    []string `json:",omitempty"` // files written, either created or overwritten
+	DeleteFiles []string `json:",omitempty"` // files removed and not written again
+}
+
 // must ensure HasVendor
+// added is false if all packages are reused
 func AddVendor(dir string, module string, fs packfs.FS, overrideAll bool, overrideSubPath map[string]bool) (added bool, err error) {
+	changes, err := AddVendorFS(writefs.SysFS{}, dir, module, fs, overrideAll, overrideSubPath)
+	if err != nil {
+		return false, err
+	}
+	return HasPackageChange(changes), nil
+}
+
+// HasPackageChange reports whether any package is added or overridden
+func HasPackageChange(changes []*PackageChange) bool {
+	for _, change := range changes {
+		if change.Action != PackageAction_Reuse {
+			return true
+		}
+	}
+	return false
+}
+
+// CopyOptions controls modes and times of copied files
+type CopyOptions struct {
+	// StableModTime sets the mtime of copied files to the time in fs,
+	// or DefaultModTime if fs has no time, so copying the same
+	// files again does not change their mtime
+	StableModTime  bool
+	DefaultModTime time.Time
+}
+
+// AddVendorFS copies vendor/<module> from fs into dir/vendor/<module>, returns changes of each package
+func AddVendorFS(wfs writefs.FS, dir string, module string, fs packfs.FS, overrideAll bool, overrideSubPath map[string]bool) (changes []*PackageChange, err error) {
+	return AddVendorFSWithOptions(wfs, dir, module, fs, overrideAll, overrideSubPath, nil)
+}
+
+func AddVendorFSWithOptions(wfs writefs.FS, dir string, module string, fs packfs.FS, overrideAll bool, overrideSubPath map[string]bool, opts *CopyOptions) (changes []*PackageChange, err error) {
 	vendorName := filepath.Join("vendor", module)
-	err = copyDirOverrideFiles(fs, writefs.SysFS{}, vendorName, filepath.Join(dir, vendorName), func(subPath string) bool {
+	err = copyDirOverrideFilesWithChange(fs, wfs, vendorName, filepath.Join(dir, vendorName), func(subPath string) bool {
 		return overrideAll || overrideSubPath[subPath]
-	})
-	added = true
+	}, func(change *PackageChange) {
+		changes = append(changes, change)
+	}, opts)
 	return
 }
 
@@ -29,7 +84,7 @@ func CopyFiles(fs packfs.FS, name string, dir string, shouldOverrideFiles func(s
 }
 
 func OverrideFilesFS(fs packfs.FS, wfs writefs.FS, srcDir string, dstDir string) error {
-	_, err := overrideFiles(fs, wfs, srcDir, dstDir, true)
+	_, err := overrideFiles(fs, wfs, srcDir, dstDir, true, nil)
 	return err
 }
 func CopyFilesFS(fs packfs.FS, wfs writefs.FS, name string, dir string, shouldOverrideFiles func(subPath string) bool) error {
@@ -42,12 +97,20 @@ func CopyFilesFS(fs packfs.FS, wfs writefs.FS, name string, dir string, shouldOv
 // this copy is aware of go's module inclusion logic, where files form a package, not dirs.
 // it treats all files as a unit, and either replace them all or just change nothing.
 func copyDirOverrideFiles(fs packfs.FS, wfs writefs.FS, name string, dir string, shouldOverrideFiles func(subPath string) bool) error {
+	return copyDirOverrideFilesWithChange(fs, wfs, name, dir, shouldOverrideFiles, nil, nil)
+}
+
+func copyDirOverrideFilesWithChange(fs packfs.FS, wfs writefs.FS, name string, dir string, shouldOverrideFiles func(subPath string) bool, onChange func(change *PackageChange), opts *CopyOptions) error {
 	var copyDir func(name string, dir string, relPath string) error
 	copyDir = func(name string, dir string, relPath string) error {
-		srcDirs, err := overrideFiles(fs, wfs, name, dir, shouldOverrideFiles != nil && shouldOverrideFiles(relPath))
+		srcDirs, change, err := overrideFilesWithChange(fs, wfs, name, dir, shouldOverrideFiles != nil && shouldOverrideFiles(relPath), opts)
 		if err != nil {
 			return err
 		}
+		if change != nil && onChange != nil {
+			change.Dir = strings.TrimPrefix(relPath, "/")
+			onChange(change)
+		}
 		// check all sub directorys
 		for _, srcDirName := range srcDirs {
 			subFile := path.Join(dir, srcDirName)
@@ -65,9 +128,16 @@ func copyDirOverrideFiles(fs packfs.FS, wfs writefs.FS, name string, dir string,
 
 const verboseLog = false
 
-func overrideFiles(fs packfs.FS, wfs writefs.FS, srcFsPath string, dstDir string, override bool) (srcDirs []string, err error) {
+func overrideFiles(fs packfs.FS, wfs writefs.FS, srcFsPath string, dstDir string, override bool, opts *CopyOptions) (srcDirs []string, err error) {
+	srcDirs, _, err = overrideFilesWithChange(fs, wfs, srcFsPath, dstDir, override, opts)
+	return
+}
+
+// overrideFilesWithChange returns nil change if srcFsPath has no files
+func overrideFilesWithChange(fs packfs.FS, wfs writefs.FS, srcFsPath string, dstDir string, override bool, opts *CopyOptions) (srcDirs []string, change *PackageChange, err error) {
 	var srcFiles []string
-	srcFiles, srcDirs, err = readEntries(fs, srcFsPath)
+	var srcLinks map[string]bool
+	srcFiles, srcDirs, srcLinks, err = readEntriesWithLinks(fs, srcFsPath)
 	if err != nil {
 		return
 	}
@@ -76,16 +146,19 @@ func overrideFiles(fs packfs.FS, wfs writefs.FS, srcFsPath string, dstDir string
 		return
 	}
 	var dirExists bool
-	dirExists, err = checkDirForRemoving(wfs, dstDir, override)
+	var removedFiles []string
+	dirExists, removedFiles, err = checkDirForRemoving(wfs, dstDir, override)
 	if err != nil {
 		err = fmt.Errorf("remove file in original directory: %w", err)
 		return
 	}
 
+	change = &PackageChange{}
 	if !dirExists {
 		if verboseLog {
 			log.Printf("package added: %v", srcFsPath)
 		}
+		change.Action = PackageAction_Add
 		err = wfs.MkdirAll(dstDir, 0755)
 		if err != nil {
 			return
@@ -95,43 +168,70 @@ func overrideFiles(fs packfs.FS, wfs writefs.FS, srcFsPath string, dstDir string
 			if verboseLog {
 				log.Printf("package override: %v", srcFsPath)
 			}
+			change.Action = PackageAction_Override
 		} else {
 			if verboseLog {
 				log.Printf("package reuse: %v", srcFsPath)
 			}
+			change.Action = PackageAction_Reuse
 			return
 		}
 	}
+	srcFileMap := make(map[string]bool, len(srcFiles))
+	for _, srcFile := range srcFiles {
+		srcFileMap[srcFile] = true
+	}
+	for _, removedFile := range removedFiles {
+		if !srcFileMap[removedFile] {
+			change.DeleteFiles = append(change.DeleteFiles, removedFile)
+		}
+	}
 	// override files when dir does not exist or should be overridden
 	// copy all source files
 	for _, srcFileName := range srcFiles {
 		subFile := path.Join(dstDir, srcFileName)
 		targetName := filepath.Join(srcFsPath, srcFileName)
-		err = CopyFileWFS(fs, wfs, targetName, subFile)
+		if !srcLinks[srcFileName] {
+			err = copyFile(fs, wfs, targetName, subFile, opts)
+			if err != nil {
+				return
+			}
+			change.AddFiles = append(change.AddFiles, srcFileName)
+			continue
+		}
+		var copied []string
+		copied, err = copySymlink(fs, wfs, targetName, subFile, opts)
 		if err != nil {
 			return
 		}
+		if copied == nil {
+			change.AddFiles = append(change.AddFiles, srcFileName)
+			continue
+		}
+		for _, file := range copied {
+			change.AddFiles = append(change.AddFiles, path.Join(srcFileName, file))
+		}
 	}
 	return
 }
 
-func checkDirForRemoving(wfs writefs.FS, dir string, override bool) (dirExists bool, err error) {
+func checkDirForRemoving(wfs writefs.FS, dir string, override bool) (dirExists bool, removedFiles []string, err error) {
 	if !override {
 		_, err := wfs.Stat(dir)
 		if err != nil {
 			if writefs.IsNotExist(err) {
-				return false, nil
+				return false, nil, nil
 			}
-			return false, err
+			return false, nil, err
 		}
-		return true, nil
+		return true, nil, nil
 	}
 	dstEntries, err := wfs.ReadDir(dir)
 	if err != nil {
 		if writefs.IsNotExist(err) {
-			return false, nil
+			return false, nil, nil
 		}
-		return false, err
+		return false, nil, err
 	}
 	// remove all dst files, except dirs
 	for _, dstEntry := range dstEntries {
@@ -143,13 +243,20 @@ func checkDirForRemoving(wfs writefs.FS, dir string, override bool) (dirExists b
 			if writefs.IsNotExist(err) {
 				continue
 			}
-			return false, err
+			return false, nil, err
 		}
+		removedFiles = append(removedFiles, dstEntry.Name())
 	}
-	return true, nil
+	return true, removedFiles, nil
 }
 
 func readEntries(fs packfs.FS, name string) (srcFiles []string, srcDirs []string, err error) {
+	srcFiles, srcDirs, _, err = readEntriesWithLinks(fs, name)
+	return
+}
+
+// readEntriesWithLinks lists symlinks as files, and also in links
+func readEntriesWithLinks(fs packfs.FS, name string) (srcFiles []string, srcDirs []string, links map[string]bool, err error) {
 	srcEntries, err := fs.ReadDir(name)
 	if err != nil {
 		return
@@ -159,10 +266,18 @@ func readEntries(fs packfs.FS, name string) (srcFiles []string, srcDirs []string
 		subName := entry.Name()
 		if entry.IsDir() {
 			srcDirs = append(srcDirs, subName)
-		} else {
-			srcFiles = append(srcFiles, subName)
+			continue
+		}
+		srcFiles = append(srcFiles, subName)
+		if entry.Type()&os.ModeSymlink != 0 {
+			if links == nil {
+				links = make(map[string]bool)
+			}
+			links[subName] = true
 		}
 	}
+	sort.Strings(srcFiles)
+	sort.Strings(srcDirs)
 	return
 }
 
@@ -172,17 +287,99 @@ func CopyFile(fs packfs.FS, name string, dst string) error {
 	return CopyFileWFS(fs, writefs.SysFS{}, name, dst)
 }
 func CopyFileWFS(fs packfs.FS, wfs writefs.FS, name string, dst string) error {
+	return copyFile(fs, wfs, name, dst, nil)
+}
+
+// copyFile writes the file with mode of FileMode, and
+// the mtime in fs if opts.StableModTime
+func copyFile(fs packfs.FS, wfs writefs.FS, name string, dst string, opts *CopyOptions) error {
 	content, err := fs.ReadFile(name)
 	if err != nil {
 		return err
 	}
-	writer, err := wfs.OpenFileWrite(dst)
+	info, err := packfs.Stat(fs, name)
 	if err != nil {
 		return err
 	}
-	defer writer.Close()
-	_, err = writer.Write(content)
-	return err
+	err = writefs.WriteFile(wfs, dst, content)
+	if err != nil {
+		return err
+	}
+	// an existing file keeps its mode when overwritten
+	if mfs, ok := wfs.(writefs.FSWithMode); ok {
+		err = mfs.Chmod(dst, FileMode(info))
+		if err != nil {
+			return err
+		}
+	}
+	if opts != nil && opts.StableModTime {
+		tfs, ok := wfs.(writefs.FSWithTime)
+		if !ok {
+			return nil
+		}
+		mtime := opts.DefaultModTime
+		if info != nil && info.ModTime().Unix() > 0 {
+			mtime = info.ModTime()
+		}
+		if mtime.IsZero() {
+			return nil
+		}
+		return tfs.Chtimes(dst, mtime, mtime)
+	}
+	return nil
+}
+
+// copySymlink recreates the symlink in wfs, if wfs cannot hold
+// symlinks, the content it points to is copied instead.
+// copied lists files under dst if a dir is copied, nil otherwise
+func copySymlink(fs packfs.FS, wfs writefs.FS, name string, dst string, opts *CopyOptions) (copied []string, err error) {
+	sfs, ok := fs.(packfs.SymlinkFS)
+	if !ok {
+		return nil, copyFile(fs, wfs, name, dst, opts)
+	}
+	target, err := sfs.Readlink(name)
+	if err != nil {
+		return nil, err
+	}
+	err = writefs.Symlink(wfs, target, dst)
+	if !errors.Is(err, writefs.ErrSymlinkNotSupported) {
+		return nil, err
+	}
+	info, err := packfs.Stat(fs, name)
+	if err != nil {
+		return nil, fmt.Errorf("symlink %s -> %s: %w", name, target, err)
+	}
+	if info == nil || !info.IsDir() {
+		return nil, copyFile(fs, wfs, name, dst, opts)
+	}
+	err = wfs.MkdirAll(dst, 0755)
+	if err != nil {
+		return nil, err
+	}
+	err = copyDirOverrideFilesWithChange(fs, wfs, name, dst, func(subPath string) bool {
+		return true
+	}, func(change *PackageChange) {
+		for _, file := range change.AddFiles {
+			copied = append(copied, path.Join(change.Dir, file))
+		}
+	}, opts)
+	if err != nil {
+		return nil, err
+	}
+	// an empty dir is still copied
+	if copied == nil {
+		copied = []string{}
+	}
+	return copied, nil
+}
+
+// FileMode is 0755 if any executable bit is set in info, otherwise 0644.
+// info can be nil for FS without modes.
+func FileMode(info fs.FileInfo) os.FileMode {
+	if info != nil && info.Mode().Perm()&0111 != 0 {
+		return 0755
+	}
+	return 0644
 }
 
 func HasVendor(dir string) bool {
@@ -190,6 +387,17 @@ func HasVendor(dir string) bool {
 	return err == nil && stat.IsDir()
 }
 
+func HasVendorFS(wfs writefs.FS, dir string) (bool, error) {
+	stat, err := wfs.Stat(path.Join(dir, "vendor"))
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return stat.IsDir(), nil
+}
+
 func exists(f string) (bool, error) {
 	_, err := os.Stat(f)
 	if err == nil {
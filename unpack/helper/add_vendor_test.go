@@ -0,0 +1,58 @@
+package helper
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// go test -run TestAddVendorModeAndModTime -v ./unpack/helper
+func TestAddVendorModeAndModTime(t *testing.T) {
+	modTime := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	fs := packtest.New(t, &packtest.Pack{
+		Files: map[string]string{
+			"vendor/example.com/a/a.go":   "package a\n",
+			"vendor/example.com/a/gen.sh": "#!/bin/sh\n",
+		},
+		Modes: map[string]os.FileMode{
+			"vendor/example.com/a/a.go":   0600,
+			"vendor/example.com/a/gen.sh": 0755,
+		},
+		ModTime: modTime,
+	})
+
+	dir := t.TempDir()
+	// an existing file with a wrong mode is fixed when overridden
+	err := os.MkdirAll(filepath.Join(dir, "vendor/example.com/a"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "vendor/example.com/a/a.go"), []byte("package old\n"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = AddVendorFSWithOptions(writefs.SysFS{}, dir, "example.com/a", fs, true, nil, &CopyOptions{StableModTime: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectModes := map[string]os.FileMode{
+		"a.go":   0644,
+		"gen.sh": 0755,
+	}
+	for name, expectMode := range expectModes {
+		info, err := os.Stat(filepath.Join(dir, "vendor/example.com/a", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != expectMode {
+			t.Fatalf("expect %s mode = %v, actual:%v", name, expectMode, info.Mode().Perm())
+		}
+		if !info.ModTime().Equal(modTime) {
+			t.Fatalf("expect %s mtime = %v, actual:%v", name, modTime, info.ModTime())
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package helper
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// RemoveModulesTxtFS removes the stanza of mod from dir/vendor/modules.txt:
+//
+//	# mod version
+//	## explicit
+//	mod/pkg
+func RemoveModulesTxtFS(fs writefs.FS, dir string, mod string) error {
+	modulesFile := filepath.Join(dir, "vendor/modules.txt")
+	content, err := writefs.ReadFile(fs, modulesFile)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	prefixModSpace := "# " + mod + " "
+	lines := strings.Split(string(content), "\n")
+	newLines := make([]string, 0, len(lines))
+	var inStanza bool
+	for _, line := range lines {
+		if strings.HasPrefix(line, "# ") {
+			inStanza = strings.HasPrefix(line, prefixModSpace)
+		}
+		if !inStanza {
+			newLines = append(newLines, line)
+		}
+	}
+	if len(newLines) == len(lines) {
+		return nil
+	}
+	return writefs.WriteFile(fs, modulesFile, []byte(strings.Join(newLines, "\n")))
+}
+
+// ReadModulesTxtStanzaFS returns the stanza of mod in dir/vendor/modules.txt,
+// empty if not found, see RemoveModulesTxtFS
+func ReadModulesTxtStanzaFS(fs writefs.FS, dir string, mod string) (string, error) {
+	content, err := writefs.ReadFile(fs, filepath.Join(dir, "vendor/modules.txt"))
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	prefixModSpace := "# " + mod + " "
+	var stanza []string
+	var inStanza bool
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, "# ") {
+			inStanza = strings.HasPrefix(line, prefixModSpace)
+		}
+		if inStanza {
+			stanza = append(stanza, line)
+		}
+	}
+	return strings.Join(stanza, "\n"), nil
+}
+
+// SetModulesTxtStanzaFS replaces the stanza of mod in dir/vendor/modules.txt
+// with stanza, which is appended if mod is not found
+func SetModulesTxtStanzaFS(fs writefs.FS, dir string, mod string, stanza string) error {
+	modulesFile := filepath.Join(dir, "vendor/modules.txt")
+	content, err := writefs.ReadFile(fs, modulesFile)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	prefixModSpace := "# " + mod + " "
+	lines := strings.Split(string(content), "\n")
+	newLines := make([]string, 0, len(lines))
+	var inStanza bool
+	var replaced bool
+	for _, line := range lines {
+		if strings.HasPrefix(line, "# ") {
+			inStanza = strings.HasPrefix(line, prefixModSpace)
+			if inStanza && !replaced {
+				newLines = append(newLines, strings.Split(stanza, "\n")...)
+				replaced = true
+			}
+		}
+		if !inStanza {
+			newLines = append(newLines, line)
+		}
+	}
+	if !replaced {
+		// keep the trailing newline last
+		n := len(newLines)
+		if n > 0 && newLines[n-1] == "" {
+			newLines = append(append(newLines[:n-1:n-1], strings.Split(stanza, "\n")...), "")
+		} else {
+			newLines = append(newLines, strings.Split(stanza, "\n")...)
+		}
+	}
+	newContent := strings.Join(newLines, "\n")
+	if newContent == string(content) {
+		return nil
+	}
+	return writefs.WriteFile(fs, modulesFile, []byte(newContent))
+}
+
+// SetModulesTxtVersionFS sets the version of mod in dir/vendor/modules.txt
+func SetModulesTxtVersionFS(fs writefs.FS, dir string, mod string, version string) error {
+	return updateModulesTxt(fs, dir, mod, version, nil)
+}
+
+// RemoveGoSumLinesFS removes lines from dir/go.sum
+func RemoveGoSumLinesFS(fs writefs.FS, dir string, lines []string) error {
+	if len(lines) == 0 {
+		return nil
+	}
+	sumFile := filepath.Join(dir, "go.sum")
+	content, err := writefs.ReadFile(fs, sumFile)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	remove := make(map[string]bool, len(lines))
+	for _, line := range lines {
+		remove[strings.TrimSpace(line)] = true
+	}
+	oldLines := strings.Split(string(content), "\n")
+	newLines := make([]string, 0, len(oldLines))
+	for _, line := range oldLines {
+		if remove[strings.TrimSpace(line)] {
+			continue
+		}
+		newLines = append(newLines, line)
+	}
+	if len(newLines) == len(oldLines) {
+		return nil
+	}
+	return writefs.WriteFile(fs, sumFile, []byte(strings.Join(newLines, "\n")))
+}
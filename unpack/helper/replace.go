@@ -11,6 +11,11 @@ func TruncateGoMod(goModFile string, module string, goVersionMajor int, goVersio
 	return TruncateGoModFS(writefs.SysFS{}, goModFile, module, goVersionMajor, goVersionMinor)
 }
 func TruncateGoModFS(wfs writefs.FS, goModFile string, module string, goVersionMajor int, goVersionMinor int) error {
+	content := fmt.Sprintf("module %s\n\ngo %d.%d\n", module, goVersionMajor, goVersionMinor)
+	old, err := writefs.ReadFile(wfs, goModFile)
+	if err == nil && string(old) == content {
+		return nil
+	}
 	w, err := wfs.OpenFileWrite(goModFile)
 	if err != nil {
 		return fmt.Errorf("write missing go.mod for %s: %w", module, err)
@@ -29,7 +34,7 @@ func TruncateGoModFS(wfs writefs.FS, goModFile string, module string, goVersionM
 	// it's important to add go version declaration(e.g. go 1.18)
 	// because go build system depends on this directive to
 	// decide whether a feature can be used(like generic)
-	_, err = w.Write([]byte(fmt.Sprintf("module %s\n\ngo %d.%d\n", module, goVersionMajor, goVersionMinor)))
+	_, err = w.Write([]byte(content))
 	if err != nil {
 		return fmt.Errorf("write missing go.mod for %s: %w", module, err)
 	}
@@ -0,0 +1,106 @@
+package helper
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// a module with a file symlink and a dir symlink
+var symlinkPack = &packtest.Pack{
+	Files: map[string]string{
+		"vendor/example.com/a/a.go":         "package a\n",
+		"vendor/example.com/a/assets/x.txt": "x\n",
+	},
+	Symlinks: map[string]string{
+		"vendor/example.com/a/link.go": "a.go",
+		"vendor/example.com/a/static":  "assets",
+	},
+}
+
+// go test -run TestTarFSSymlink -v ./unpack/helper
+func TestTarFSSymlink(t *testing.T) {
+	fs := packtest.New(t, symlinkPack)
+	content, err := fs.ReadFile("vendor/example.com/a/link.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "package a\n" {
+		t.Fatalf("expect %s = %q, actual:%q", "link.go", "package a\n", content)
+	}
+	content, err = fs.ReadFile("vendor/example.com/a/static/x.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "x\n" {
+		t.Fatalf("expect %s = %q, actual:%q", "static/x.txt", "x\n", content)
+	}
+	target, err := fs.(packfs.SymlinkFS).Readlink("vendor/example.com/a/static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target != "assets" {
+		t.Fatalf("expect %s = %q, actual:%q", "target", "assets", target)
+	}
+}
+
+// go test -run TestAddVendorSymlink -v ./unpack/helper
+func TestAddVendorSymlink(t *testing.T) {
+	fs := packtest.New(t, symlinkPack)
+
+	addFiles := func(changes []*PackageChange) string {
+		var files []string
+		for _, change := range changes {
+			for _, file := range change.AddFiles {
+				files = append(files, path.Join(change.Dir, file))
+			}
+		}
+		return strings.Join(files, ",")
+	}
+
+	dir := t.TempDir()
+	changes, err := AddVendorFS(writefs.SysFS{}, dir, "example.com/a", fs, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFiles := "a.go,link.go,static,assets/x.txt"
+	if files := addFiles(changes); files != expectFiles {
+		t.Fatalf("expect %s = %+v, actual:%+v", "added files", expectFiles, files)
+	}
+	for name, expectTarget := range map[string]string{"link.go": "a.go", "static": "assets"} {
+		target, err := os.Readlink(filepath.Join(dir, "vendor/example.com/a", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != expectTarget {
+			t.Fatalf("expect %s = %q, actual:%q", name, expectTarget, target)
+		}
+	}
+
+	// memfs cannot hold symlinks, content is copied
+	mfs := memfs.New()
+	changes, err = AddVendorFS(mfs, "/target", "example.com/a", fs, false, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFiles = "a.go,link.go,static/x.txt,assets/x.txt"
+	if files := addFiles(changes); files != expectFiles {
+		t.Fatalf("expect %s = %+v, actual:%+v", "added files", expectFiles, files)
+	}
+	for name, expect := range map[string]string{"link.go": "package a\n", "static/x.txt": "x\n"} {
+		content, err := writefs.ReadFile(mfs, "/target/vendor/example.com/a/"+name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(content) != expect {
+			t.Fatalf("expect %s = %q, actual:%q", name, expect, content)
+		}
+	}
+}
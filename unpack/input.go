@@ -0,0 +1,92 @@
+package unpack
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/tar"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// NewTarFSFromData reads a pack in any of the forms
+// accepted by DecodePackData
+func NewTarFSFromData(data []byte) (packfs.FS, error) {
+	raw, err := DecodePackData(data)
+	if err != nil {
+		return nil, err
+	}
+	return tar.NewTarFS(bytes.NewReader(raw))
+}
+
+// DecodePackData returns the gzipped tar of a pack given as
+// the raw archive, base64 of it, or a go file generated by pack
+func DecodePackData(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, gzipMagic) {
+		return data, nil
+	}
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("package ")) || bytes.HasPrefix(trimmed, []byte("//")) || bytes.HasPrefix(trimmed, []byte("/*")) {
+		s, err := PackLiteralFromGoSource(data, "")
+		if err != nil {
+			return nil, err
+		}
+		trimmed = []byte(s)
+	}
+	raw := make([]byte, base64.StdEncoding.DecodedLen(len(trimmed)))
+	n, err := base64.StdEncoding.Decode(raw, trimmed)
+	if err != nil {
+		return nil, fmt.Errorf("decode base64: %v", err)
+	}
+	return raw[:n], nil
+}
+
+// PackLiteralFromGoSource returns the string assigned to varName in a go file
+// generated by pack, if varName is empty, the longest string literal is returned
+func PackLiteralFromGoSource(src []byte, varName string) (string, error) {
+	file, err := parser.ParseFile(token.NewFileSet(), "", src, 0)
+	if err != nil {
+		return "", err
+	}
+	var found bool
+	var res string
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || (genDecl.Tok != token.VAR && genDecl.Tok != token.CONST) {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			valueSpec := spec.(*ast.ValueSpec)
+			for i, name := range valueSpec.Names {
+				if i >= len(valueSpec.Values) || (varName != "" && name.Name != varName) {
+					continue
+				}
+				lit, ok := valueSpec.Values[i].(*ast.BasicLit)
+				if !ok || lit.Kind != token.STRING {
+					continue
+				}
+				s, err := strconv.Unquote(lit.Value)
+				if err != nil {
+					return "", err
+				}
+				if !found || len(s) > len(res) {
+					found = true
+					res = s
+				}
+			}
+		}
+	}
+	if !found {
+		if varName != "" {
+			return "", fmt.Errorf("no string literal assigned to %s", varName)
+		}
+		return "", fmt.Errorf("no string literal found")
+	}
+	return res, nil
+}
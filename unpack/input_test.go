@@ -0,0 +1,62 @@
+package unpack
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+)
+
+// go test -run TestNewTarFSFromData -v ./unpack
+func TestNewTarFSFromData(t *testing.T) {
+	goSrc, err := ioutil.ReadFile("packdata_test.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectFS, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, err := ReadGoList(expectFS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inputs := map[string][]byte{
+		"base64":  []byte(testPack + "\n"),
+		"raw":     raw,
+		"go file": goSrc,
+	}
+	for name, data := range inputs {
+		fs, err := NewTarFSFromData(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		goList, err := ReadGoList(fs)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if goList.Digest != expect.Digest {
+			t.Fatalf("expect %s = %+v, actual:%+v", name+" digest", expect.Digest, goList.Digest)
+		}
+	}
+
+	_, err = PackLiteralFromGoSource(goSrc, "missing")
+	if err == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "no string literal assigned to missing", err)
+	}
+}
+
+// go test -run TestReadGoListMissing -v ./unpack
+func TestReadGoListMissing(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{Omit: []string{"go.list.json"}})
+	_, err := ReadGoList(fs)
+	if !packfs.IsNotExists(err) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "not exists", err)
+	}
+}
@@ -0,0 +1,444 @@
+package unpack
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/unpack/helper"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// LOCK_FILE records what the last unpack installed, relative to
+// the dir holding vendor, i.e. the target or NonVendorHostDir.
+// Targets using a cached or temp host dir keep it in CacheDir.
+const LOCK_FILE = "vendor/.go-vendor-pack.json"
+
+// LOCK_FORMAT_VERSION changes when the layout of unpacked files changes,
+// a lock with a different version is never treated as up to date
+const LOCK_FORMAT_VERSION = 1
+
+type Lock struct {
+	FormatVersion int
+	Digest        string // digest of the pack, from go.list.json
+	PackTimeUTC   string `json:",omitempty"`
+	HostDir       string `json:",omitempty"` // host dir of the modules, empty if unpacked into the target's vendor
+	GoVersion     string `json:",omitempty"` // go version of go.mod files in the host dir, e.g. 1.18
+	GoWork        string `json:",omitempty"` // absolute go.work file using the modules, in go.work mode
+	Modules       []*LockModule
+
+	// to revert go.work, see Uninstall
+	GoWorkCreated  bool                    `json:",omitempty"` // go.work did not exist before the first unpack
+	GoWorkUses     []string                `json:",omitempty"` // uses added to go.work, absolute dirs
+	GoWorkReplaces []*helper.GoWorkReplace `json:",omitempty"` // replaces dropped from go.work for the used modules
+}
+
+type LockModule struct {
+	Path    string
+	Version string
+	Files   []string // files written by unpack, relative to the dir holding vendor
+
+	// to revert the target, see Uninstall
+	PrevVersion    string   `json:",omitempty"` // version required by the target before the first unpack, empty if not required
+	PrevModulesTxt string   `json:",omitempty"` // stanza of vendor/modules.txt before the first unpack, empty if not listed
+	Replace        string   `json:",omitempty"` // replace added to the target's go.mod
+	Sums           []string `json:",omitempty"` // lines added to the target's go.sum
+}
+
+// ReadLock reads the lock in dir, returns nil if not exists
+func ReadLock(dir string) (*Lock, error) {
+	return ReadLockFS(writefs.SysFS{}, dir)
+}
+
+func ReadLockFS(wfs writefs.FS, dir string) (*Lock, error) {
+	data, err := writefs.ReadFile(wfs, path.Join(dir, LOCK_FILE))
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var lock *Lock
+	err = json.Unmarshal(data, &lock)
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+func writeLock(wfs writefs.FS, dir string, lock *Lock) error {
+	data, err := json.MarshalIndent(lock, "", "    ")
+	if err != nil {
+		return err
+	}
+	lockFile := path.Join(dir, LOCK_FILE)
+	err = wfs.MkdirAll(path.Dir(lockFile), 0755)
+	if err != nil {
+		return err
+	}
+	return writefs.WriteFile(wfs, lockFile, append(data, '\n'))
+}
+
+// Module finds the module by path, returns nil if not found
+func (c *Lock) Module(mod string) *LockModule {
+	if c == nil {
+		return nil
+	}
+	for _, m := range c.Modules {
+		if m.Path == mod {
+			return m
+		}
+	}
+	return nil
+}
+
+// upToDate reports whether the lock was written by the same pack, and
+// the target still requires every module at the recorded version
+func (c *Lock) upToDate(digest string, targetVersions map[string]string, checkVersions bool) bool {
+	if c == nil || digest == "" || c.FormatVersion != LOCK_FORMAT_VERSION || c.Digest != digest {
+		return false
+	}
+	if checkVersions {
+		for _, mod := range c.Modules {
+			if targetVersions[mod.Path] != mod.Version {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// outsideUpToDate reports whether what the lock points to outside the
+// target's vendor is still in place: the modules in the host dir, the replaces
+// in the target's go.mod, and the uses in go.work
+func (c *Lock) outsideUpToDate(wfs writefs.FS, dir string, useHostDir bool, useGoWork bool, goVersion string) (bool, error) {
+	if (c.HostDir != "") != useHostDir || (c.GoWork != "") != useGoWork {
+		return false, nil
+	}
+	if !useHostDir {
+		return true, nil
+	}
+	if c.GoVersion != goVersion {
+		return false, nil
+	}
+	for _, mod := range c.Modules {
+		_, err := wfs.Stat(path.Join(c.HostDir, "vendor", mod.Path, "go.mod"))
+		if err != nil {
+			if writefs.IsNotExist(err) {
+				return false, nil
+			}
+			return false, err
+		}
+	}
+	if !useGoWork {
+		replaces, err := readTargetReplaces(wfs, dir)
+		if err != nil {
+			return false, err
+		}
+		for _, mod := range c.Modules {
+			if mod.Replace != "" && replaces[mod.Path] != mod.Replace {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+	uses, _, _, err := helper.ReadGoWorkFS(wfs, c.GoWork)
+	if err != nil {
+		return false, err
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	if !uses[absDir] {
+		return false, nil
+	}
+	for _, mod := range c.Modules {
+		modDir, err := filepath.Abs(path.Join(c.HostDir, "vendor", mod.Path))
+		if err != nil {
+			return false, err
+		}
+		if !uses[modDir] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// readPackDigest reads the digest from go.list.json, for packs without digest,
+// a digest of all files is used, so packs differing in any file never share it
+func readPackDigest(fs packfs.FS) (digest string, packTime string, err error) {
+	data, err := fs.ReadFile("go.list.json")
+	if err != nil && !packfs.IsNotExists(err) {
+		return "", "", err
+	}
+	if len(data) > 0 {
+		var goList struct {
+			PackTimeUTC string
+			Digest      string
+		}
+		err = json.Unmarshal(data, &goList)
+		if err != nil {
+			return "", "", fmt.Errorf("parsing go.list.json: %w", err)
+		}
+		if goList.Digest != "" {
+			return goList.Digest, goList.PackTimeUTC, nil
+		}
+		packTime = goList.PackTimeUTC
+	}
+	h := md5.New()
+	var walk func(dir string) error
+	walk = func(dir string) error {
+		entries, err := fs.ReadDir(dir)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(entries))
+		isDir := make(map[string]bool, len(entries))
+		isLink := make(map[string]bool, len(entries))
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+			isDir[entry.Name()] = entry.IsDir()
+			isLink[entry.Name()] = entry.Type()&os.ModeSymlink != 0
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			file := path.Join(dir, name)
+			if isLink[name] {
+				// a link to a dir cannot be read, digest where it points
+				sfs, ok := fs.(packfs.SymlinkFS)
+				if ok {
+					target, err := sfs.Readlink(file)
+					if err != nil {
+						return err
+					}
+					fmt.Fprintf(h, "%s -> %s\n", file, target)
+					continue
+				}
+			}
+			if isDir[name] {
+				err := walk(file)
+				if err != nil && !packfs.IsNotExists(err) {
+					return err
+				}
+				continue
+			}
+			content, err := fs.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %d\n", file, len(content))
+			h.Write(content)
+		}
+		return nil
+	}
+	err = walk(".")
+	if err != nil {
+		return "", "", fmt.Errorf("digesting pack: %w", err)
+	}
+	return "content:" + hex.EncodeToString(h.Sum(nil)), packTime, nil
+}
+
+// listOwnedFiles lists files under vendor/<module> in the pack,
+// excluding nested modules, which own their files separately.
+// A symlink is listed itself, a dir symlink also with the files
+// under it, which are written instead if the target cannot hold symlinks
+func listOwnedFiles(fs packfs.FS, module string, versionMapping map[string]string) ([]string, error) {
+	var files []string
+	var walk func(name string, inLink bool) error
+	walk = func(name string, inLink bool) error {
+		entries, err := fs.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			subName := path.Join(name, entry.Name())
+			if entry.Type()&os.ModeSymlink != 0 {
+				files = append(files, subName)
+				if inLink {
+					continue
+				}
+				info, err := packfs.Stat(fs, subName)
+				if err != nil && !packfs.IsNotExists(err) {
+					return err
+				}
+				if info == nil || !info.IsDir() {
+					continue
+				}
+				err = walk(subName, true)
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			if !entry.IsDir() {
+				files = append(files, subName)
+				continue
+			}
+			if _, ok := versionMapping[strings.TrimPrefix(subName, "vendor/")]; ok {
+				continue
+			}
+			err := walk(subName, inLink)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	err := walk(path.Join("vendor", module), false)
+	if err != nil {
+		if packfs.IsNotExists(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// newLockModule records files owned by module: files written this time, and files
+// owned by the previous unpack, excluding those no longer in the pack.
+// Packages reused from the target are not owned.
+func newLockModule(fs packfs.FS, module string, version string, versionMapping map[string]string, oldMod *LockModule, pkgChanges []*helper.PackageChange) (*LockModule, error) {
+	packFiles, err := listOwnedFiles(fs, module, versionMapping)
+	if err != nil {
+		return nil, err
+	}
+	inPack := make(map[string]bool, len(packFiles))
+	for _, file := range packFiles {
+		inPack[file] = true
+	}
+	owned := make(map[string]bool)
+	if oldMod != nil {
+		for _, file := range oldMod.Files {
+			owned[file] = true
+		}
+	}
+	for _, pkg := range pkgChanges {
+		for _, file := range pkg.AddFiles {
+			owned[path.Join("vendor", module, pkg.Dir, file)] = true
+		}
+	}
+	files := make([]string, 0, len(owned))
+	for file := range owned {
+		if inPack[file] {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	return &LockModule{
+		Path:    module,
+		Version: version,
+		Files:   files,
+	}, nil
+}
+
+// readGoSumLines reads lines of dir/go.sum
+func readGoSumLines(wfs writefs.FS, dir string) (map[string]bool, error) {
+	content, err := writefs.ReadFile(wfs, path.Join(dir, "go.sum"))
+	if err != nil && !writefs.IsNotExist(err) {
+		return nil, err
+	}
+	lines := make(map[string]bool)
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines[line] = true
+		}
+	}
+	return lines, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanLockFile cleans a file recorded in the lock, the lock is read
+// from disk, so files outside vendor, e.g. ../x or /x, are rejected
+func cleanLockFile(file string) (string, error) {
+	cleaned := path.Clean(file)
+	if path.IsAbs(cleaned) || cleaned == ".." || strings.HasPrefix(cleaned, "../") || !strings.HasPrefix(cleaned, "vendor/") {
+		return "", fmt.Errorf("invalid file in unpack lock: %q", file)
+	}
+	return cleaned, nil
+}
+
+// removeStaleFiles removes files owned by the old lock but not by the new one,
+// and dirs left empty by them
+func removeStaleFiles(wfs writefs.FS, dir string, oldLock *Lock, newLock *Lock) ([]string, error) {
+	if oldLock == nil {
+		return nil, nil
+	}
+	owned := make(map[string]bool)
+	for _, mod := range newLock.Modules {
+		for _, file := range mod.Files {
+			owned[path.Clean(file)] = true
+		}
+	}
+	var removed []string
+	for _, mod := range oldLock.Modules {
+		for _, file := range mod.Files {
+			file, err := cleanLockFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if owned[file] {
+				continue
+			}
+			absFile := path.Join(dir, file)
+			_, err = wfs.Stat(absFile)
+			if err != nil {
+				if writefs.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			err = wfs.RemoveFile(absFile)
+			if err != nil {
+				return nil, err
+			}
+			removed = append(removed, file)
+			err = removeEmptyParents(wfs, dir, path.Dir(file))
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return removed, nil
+}
+
+// removeEmptyParents removes subDir and its parents if empty, stops at vendor
+func removeEmptyParents(wfs writefs.FS, dir string, subDir string) error {
+	for subDir != "vendor" && strings.HasPrefix(subDir, "vendor/") {
+		absDir := path.Join(dir, subDir)
+		entries, err := wfs.ReadDir(absDir)
+		if err != nil {
+			if writefs.IsNotExist(err) {
+				subDir = path.Dir(subDir)
+				continue
+			}
+			return err
+		}
+		if len(entries) > 0 {
+			return nil
+		}
+		err = wfs.RemoveFile(absDir)
+		if err != nil {
+			return err
+		}
+		subDir = path.Dir(subDir)
+	}
+	return nil
+}
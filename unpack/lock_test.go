@@ -0,0 +1,253 @@
+package unpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestUnpackLock -v ./unpack
+func TestUnpackLock(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+	res, err := UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UpToDate {
+		t.Fatalf("expect first unpack not up to date")
+	}
+	lock, err := ReadLockFS(mfs, "/target")
+	if err != nil {
+		t.Fatal(err)
+	}
+	toolsMod := lock.Module("golang.org/x/tools")
+	if toolsMod == nil || toolsMod.Version != "v0.8.0" {
+		t.Fatalf("expect lock contains golang.org/x/tools v0.8.0, actual:%+v", toolsMod)
+	}
+	expectFile := "vendor/golang.org/x/tools/cover/profile.go"
+	if !contains(toolsMod.Files, expectFile) {
+		t.Fatalf("expect golang.org/x/tools owns %s, actual:%v", expectFile, toolsMod.Files)
+	}
+
+	res, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.UpToDate || res.Changed() {
+		t.Fatalf("expect second unpack up to date, actual:%+v", res)
+	}
+
+	// pretend the previous pack owned a file that is gone in the new one
+	staleFile := "vendor/golang.org/x/tools/stale/stale.go"
+	err = mfs.MkdirAll("/target/vendor/golang.org/x/tools/stale", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writefs.WriteFile(mfs, "/target/"+staleFile, []byte("package stale\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lock.Digest = "old"
+	toolsMod.Files = append(toolsMod.Files, staleFile)
+	err = writeLock(mfs, "/target", lock)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.UpToDate || len(res.RemovedFiles) != 1 || res.RemovedFiles[0] != staleFile {
+		t.Fatalf("expect removed files = %v, actual:%+v", []string{staleFile}, res)
+	}
+	_, err = mfs.Stat("/target/vendor/golang.org/x/tools/stale")
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect empty stale dir removed, actual:%v", err)
+	}
+	_, err = mfs.Stat("/target/" + expectFile)
+	if err != nil {
+		t.Fatalf("expect %s kept, actual:%v", expectFile, err)
+	}
+}
+
+// go test -run TestRemoveStaleFilesRejectsEscapes -v ./unpack
+func TestRemoveStaleFilesRejectsEscapes(t *testing.T) {
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+	for _, file := range []string{"/target/secret.txt", "/secret.txt"} {
+		err := writefs.WriteFile(mfs, file, []byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"vendor/../secret.txt", "vendor/a/../../../secret.txt", "/secret.txt", "../secret.txt", "secret.txt"} {
+		oldLock := &Lock{Modules: []*LockModule{{Path: "a", Files: []string{file}}}}
+		_, err := removeStaleFiles(mfs, "/target", oldLock, &Lock{})
+		if err == nil {
+			t.Fatalf("expect %s = %+v, actual:%+v", file, "invalid file in unpack lock", err)
+		}
+	}
+	for _, file := range []string{"/target/secret.txt", "/secret.txt"} {
+		_, err := mfs.Stat(file)
+		if err != nil {
+			t.Fatalf("expect %s kept, actual:%v", file, err)
+		}
+	}
+}
+
+// go test -run TestUnpackLockSymlink -v ./unpack
+func TestUnpackLockSymlink(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{
+		Modules: map[string]string{"example.com/a": "v1.0.0"},
+		Files: map[string]string{
+			"vendor/example.com/a/a.go":         "package a",
+			"vendor/example.com/a/assets/x.txt": "x",
+		},
+		Symlinks: map[string]string{"vendor/example.com/a/static": "assets"},
+	})
+	// the link is written on disk, the content it points to in memfs
+	diskDir := filepath.Join(t.TempDir(), "target")
+	newTestTarget(t, writefs.SysFS{}, diskDir, true)
+	_, err := Unpack(fs, diskDir, &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+	_, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		wfs    writefs.FS
+		dir    string
+		expect string
+	}{
+		{writefs.SysFS{}, diskDir, "vendor/example.com/a/a.go,vendor/example.com/a/assets/x.txt,vendor/example.com/a/static"},
+		{mfs, "/target", "vendor/example.com/a/a.go,vendor/example.com/a/assets/x.txt,vendor/example.com/a/static/x.txt"},
+	} {
+		lock, err := ReadLockFS(tt.wfs, tt.dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files := strings.Join(lock.Module("example.com/a").Files, ",")
+		if files != tt.expect {
+			t.Fatalf("expect %s = %+v, actual:%+v", tt.dir+" files", tt.expect, files)
+		}
+	}
+}
+
+// go test -run TestUnpackUpToDateModes -v ./unpack
+func TestUnpackUpToDateModes(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{
+		Digest:  "up-to-date",
+		Modules: map[string]string{"example.com/a": "v1.0.0"},
+		Files:   map[string]string{"vendor/example.com/a/a.go": "package a"},
+	})
+	tests := []struct {
+		name   string
+		vendor bool
+		opts   Options // host dirs are relative to the test root
+	}{
+		{name: "vendor", vendor: true},
+		{name: "host dir", opts: Options{NonVendorHostDir: "host"}},
+		{name: "cached host dir", opts: Options{CacheDir: "cache"}},
+		{name: "go.work", vendor: true, opts: Options{UseGoWork: true, NonVendorHostDir: "host"}},
+		{name: "cached go.work", vendor: true, opts: Options{UseGoWork: true, CacheDir: "cache"}},
+	}
+	t.Setenv("GOFLAGS", "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "target")
+			newTestTarget(t, writefs.SysFS{}, dir, tt.vendor)
+			opts := tt.opts
+			opts.GoVersion = "1.18"
+			if opts.NonVendorHostDir != "" {
+				opts.NonVendorHostDir = filepath.Join(root, opts.NonVendorHostDir)
+			}
+			if opts.CacheDir != "" {
+				opts.CacheDir = filepath.Join(root, opts.CacheDir)
+			}
+			first, err := Unpack(fs, dir, &opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// anything written by the second unpack gets a newer mtime
+			old := time.Now().Add(-time.Hour).Truncate(time.Second)
+			err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				return os.Chtimes(file, old, old)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := readTestTree(t, root)
+
+			res, err := Unpack(fs, dir, &opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.UpToDate || res.Changed() {
+				t.Fatalf("expect %s = %+v, actual:%+v", "up to date", true, res)
+			}
+			if res.HostDir != first.HostDir || strings.Join(res.Env, " ") != strings.Join(first.Env, " ") {
+				t.Fatalf("expect %s = %+v, actual:%+v", "host dir and env", first, res)
+			}
+			err = filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				// dir locks are rewritten with the pid of the holder
+				if strings.HasSuffix(file, ".lock") {
+					return nil
+				}
+				if !info.ModTime().Equal(old) {
+					t.Fatalf("expect %s = %+v, actual:%+v", file+" mtime", old, info.ModTime())
+				}
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := readTestTree(t, root)
+			if len(after) != len(before) {
+				t.Fatalf("expect %s = %+v, actual:%+v", "files", before, after)
+			}
+
+			// a removed host dir is unpacked again
+			if first.HostDir == "" {
+				return
+			}
+			err = os.RemoveAll(first.HostDir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err = Unpack(fs, dir, &opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res.UpToDate {
+				t.Fatalf("expect %s = %+v, actual:%+v", "up to date after removing host dir", false, res)
+			}
+			_, err = os.Stat(filepath.Join(res.HostDir, "vendor", "example.com", "a", "a.go"))
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package unpack
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/unpack/helper"
+	"golang.org/x/mod/sumdb/dirhash"
+)
+
+// testGoBuild runs go build with args in dir, extra env
+// overrides the environment, no network is used
+func testGoBuild(t *testing.T, dir string, env []string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("go", append([]string{"build"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOPROXY=off", "GOSUMDB=off", "GOWORK=off", "GOFLAGS=")
+	cmd.Env = append(cmd.Env, env...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build %v: %v\n%s", args, err, out)
+	}
+}
+
+func testHash1(t *testing.T, files map[string]string) string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	h, err := dirhash.Hash1(names, func(name string) (io.ReadCloser, error) {
+		return ioutil.NopCloser(bytes.NewReader([]byte(files[name]))), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+// go test -run TestUnpackModCacheBuild -v ./unpack
+func TestUnpackModCacheBuild(t *testing.T) {
+	tmpDir := t.TempDir()
+	goMod := "module example.com/m\n\ngo 1.14\n"
+	src := "package m\n\nfunc Hello() string { return \"hello\" }\n"
+	zipHash := testHash1(t, map[string]string{
+		"example.com/m@v1.0.0/go.mod": goMod,
+		"example.com/m@v1.0.0/m.go":   src,
+	})
+	goModHash := testHash1(t, map[string]string{"go.mod": goMod})
+	newPack := func(sum string) *packtest.Pack {
+		return &packtest.Pack{
+			Modules: map[string]string{"example.com/m": "v1.0.0"},
+			Sums: []string{
+				"example.com/m v1.0.0 " + sum,
+				"example.com/m v1.0.0/go.mod " + goModHash,
+			},
+			Files: map[string]string{
+				"vendor/example.com/m/m.go":            src,
+				"modcache/example.com/m/@v/v1.0.0.mod": goMod,
+			},
+		}
+	}
+
+	target := filepath.Join(tmpDir, "target")
+	modCacheDir := filepath.Join(tmpDir, "modcache")
+	files := map[string]string{
+		"go.mod":  "module example.com/target\n\ngo 1.14\n",
+		"go.sum":  "",
+		"main.go": "package main\n\nimport \"example.com/m\"\n\nfunc main() { println(m.Hello()) }\n",
+	}
+	for name, content := range files {
+		err := os.MkdirAll(target, 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ioutil.WriteFile(filepath.Join(target, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// a vendored subset of the module cannot go into the module cache
+	opts := &Options{UseModCache: true, ModCacheDir: modCacheDir}
+	_, err := Unpack(packtest.New(t, newPack("h1:wholeModuleHash=")), target, opts)
+	if !errors.Is(err, helper.ErrPartialModule) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", helper.ErrPartialModule, err)
+	}
+
+	_, err = Unpack(packtest.New(t, newPack(zipHash)), target, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGoBuild(t, target, []string{"GOMODCACHE=" + modCacheDir, "GOFLAGS=-mod=mod"}, "./...")
+}
@@ -0,0 +1,131 @@
+package unpack
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/unpack/helper"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+const OVERLAY_FILE = "overlay.json"
+
+// Overlay is the json accepted by `go build -overlay`,
+// a replacement of "" means the file is deleted
+type Overlay struct {
+	Replace map[string]string
+}
+
+type OverlayResult struct {
+	OverlayFile string   // scratchDir/overlay.json
+	Overlay     *Overlay // content of OverlayFile
+	Env         []string // extra env needed to build, e.g. GOWORK and GOFLAGS in go.work mode
+}
+
+// UnpackOverlay unpacks as if into dir, but dir is never modified.
+// Changed files are stored under scratchDir, and an overlay file
+// covering vendor files, go.mod, go.sum and modules.txt is written to scratchDir/overlay.json,
+// so the target can be built with `go build -overlay=scratchDir/overlay.json`.
+// NOTE: the go command does not read vendor/modules.txt through overlay, so
+// vendor-style targets fail unless UseGoWork or UseModCache is set.
+func UnpackOverlay(fs packfs.FS, dir string, scratchDir string, opts *Options) (*OverlayResult, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	if opts == nil || !(opts.UseGoWork || opts.UseModCache) {
+		hasVendorDir, err := helper.HasVendorFS(writefs.SysFS{}, absDir)
+		if err != nil {
+			return nil, err
+		}
+		if hasVendorDir {
+			return nil, fmt.Errorf("%s has vendor dir, the go command does not read vendor/modules.txt through -overlay, use UseGoWork or UseModCache", dir)
+		}
+	}
+	absScratchDir, err := filepath.Abs(scratchDir)
+	if err != nil {
+		return nil, err
+	}
+	var lockTimeout time.Duration
+	if opts != nil {
+		lockTimeout = opts.LockTimeout
+	}
+	unlock, err := lockDirs(writefs.SysFS{}, lockTimeout, absDir, absScratchDir)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	filesDir := filepath.Join(absScratchDir, "files")
+	mfs := memfs.NewReadThrough(writefs.SysFS{}, filesDir)
+	state, err := unpack(fs, mfs, absDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	overlay, err := makeOverlay(mfs, filesDir)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(overlay, "", "    ")
+	if err != nil {
+		return nil, err
+	}
+	overlayFile := filepath.Join(absScratchDir, OVERLAY_FILE)
+	err = os.WriteFile(overlayFile, data, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &OverlayResult{
+		OverlayFile: overlayFile,
+		Overlay:     overlay,
+		Env:         state.env,
+	}, nil
+}
+
+// makeOverlay maps every file written to mfs to its backing file
+// in filesDir, and every removed file of the base to ""
+func makeOverlay(mfs *memfs.MemFS, filesDir string) (*Overlay, error) {
+	replace := make(map[string]string)
+	mfs.TraversePath(func(path string, e memfs.MemFileInfo) bool {
+		if !e.IsDir() && !e.InBase() {
+			replace[path] = filepath.Join(filesDir, path)
+		}
+		return true
+	})
+	for _, removed := range mfs.Removed() {
+		err := filepath.Walk(removed, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				if os.IsNotExist(err) {
+					return nil
+				}
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			if _, ok := replace[path]; !ok {
+				replace[path] = ""
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("list removed %s: %w", removed, err)
+		}
+	}
+	return &Overlay{Replace: replace}, nil
+}
+
+// Files returns replaced files, sorted
+func (c *Overlay) Files() []string {
+	files := make([]string, 0, len(c.Replace))
+	for file := range c.Replace {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
@@ -0,0 +1,123 @@
+package unpack
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// go test -run TestUnpackOverlay -v ./unpack
+func TestUnpackOverlay(t *testing.T) {
+	tmpDir := t.TempDir()
+	dir := filepath.Join(tmpDir, "target")
+	hostDir := filepath.Join(tmpDir, "host")
+	scratchDir := filepath.Join(tmpDir, "scratch")
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	goMod := "module example\n\ngo 1.18\n"
+	err = os.WriteFile(filepath.Join(dir, "go.mod"), []byte(goMod), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "go.sum"), nil, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := UnpackOverlay(fs, dir, scratchDir, &Options{NonVendorHostDir: hostDir, GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// target and host dir are untouched
+	actualGoMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actualGoMod) != goMod {
+		t.Fatalf("expect go.mod unchanged = %q, actual:%q", goMod, actualGoMod)
+	}
+	_, err = os.Stat(hostDir)
+	if !os.IsNotExist(err) {
+		t.Fatalf("expect host dir not created, actual:%v", err)
+	}
+
+	data, err := os.ReadFile(res.OverlayFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var overlay *Overlay
+	err = json.Unmarshal(data, &overlay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{
+		filepath.Join(dir, "go.mod"),
+		filepath.Join(dir, "go.sum"),
+		filepath.Join(hostDir, "vendor/golang.org/x/tools/go.mod"),
+		filepath.Join(hostDir, "vendor/golang.org/x/tools/cover/profile.go"),
+	} {
+		if overlay.Replace[file] == "" {
+			t.Fatalf("expect overlay contains %s, actual:%v", file, overlay.Files())
+		}
+	}
+	overlayGoMod, err := os.ReadFile(overlay.Replace[filepath.Join(dir, "go.mod")])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReplace := "golang.org/x/tools => " + filepath.Join(hostDir, "vendor/golang.org/x/tools")
+	if !strings.Contains(string(overlayGoMod), expectReplace) {
+		t.Fatalf("expect overlay go.mod contains %s, actual:%s", expectReplace, overlayGoMod)
+	}
+}
+
+// go test -run TestUnpackOverlayBuild -v ./unpack
+func TestUnpackOverlayBuild(t *testing.T) {
+	tmpDir := t.TempDir()
+	fs := packtest.New(t, &packtest.Pack{
+		Digest:  "overlay-build-test",
+		Modules: map[string]string{"example.com/m": "v1.0.0"},
+		Files: map[string]string{
+			"vendor/example.com/m/m.go": "package m\n\nfunc Hello() string { return \"hello\" }\n",
+		},
+	})
+	newTarget := func(name string, vendor bool) string {
+		dir := filepath.Join(tmpDir, name)
+		newTestTarget(t, writefs.SysFS{}, dir, vendor)
+		err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nimport \"example.com/m\"\n\nfunc main() { println(m.Hello()) }\n"), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return dir
+	}
+
+	dir := newTarget("target", false)
+	res, err := UnpackOverlay(fs, dir, filepath.Join(tmpDir, "scratch"), &Options{NonVendorHostDir: filepath.Join(tmpDir, "host"), GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGoBuild(t, dir, res.Env, "-overlay="+res.OverlayFile, ".")
+
+	// vendor/modules.txt is not read through overlay
+	vendorDir := newTarget("vendor_target", true)
+	_, err = UnpackOverlay(fs, vendorDir, filepath.Join(tmpDir, "vendor_scratch"), &Options{GoVersion: "1.18"})
+	if err == nil {
+		t.Fatalf("expect %s = %+v, actual:%+v", "err", "vendor dir not supported", err)
+	}
+	res, err = UnpackOverlay(fs, vendorDir, filepath.Join(tmpDir, "vendor_scratch"), &Options{UseGoWork: true, NoCache: true, GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testGoBuild(t, vendorDir, res.Env, "-overlay="+res.OverlayFile, ".")
+}
@@ -0,0 +1,332 @@
+package unpack
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/unpack/helper"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+	"golang.org/x/mod/modfile"
+)
+
+type ModuleAction string
+
+const (
+	ModuleAction_Add      ModuleAction = "add"      // all packages are new
+	ModuleAction_Override ModuleAction = "override" // some packages are overridden or added
+	ModuleAction_Reuse    ModuleAction = "reuse"    // all packages already exist
+	ModuleAction_Skip     ModuleAction = "skip"     // not unpacked, see Reason
+)
+
+type ModuleChange struct {
+	Path     string
+	Version  string
+	Action   ModuleAction
+	Reason   string                  `json:",omitempty"` // why skipped
+	Dir      string                  `json:",omitempty"` // where packages are copied to
+	Packages []*helper.PackageChange `json:",omitempty"`
+}
+
+// UnpackPlan describes what Unpack would do, without touching disk
+type UnpackPlan struct {
+	Dir     string
+	HostDir string `json:",omitempty"` // where modules go if not into the target's vendor
+	Modules []*ModuleChange
+
+	// Decisions explains the version taken for each module, see ConflictPolicy
+	Decisions []*ConflictDecision `json:",omitempty"`
+
+	GoModRequires []*RequireChange `json:",omitempty"`
+	GoModReplaces []*ReplaceChange `json:",omitempty"`
+	GoSumAdds     []string         `json:",omitempty"`
+
+	ModulesTxtAdds    []string `json:",omitempty"`
+	ModulesTxtRemoves []string `json:",omitempty"`
+
+	// GoWork is the content of go.work after unpack, only for UseGoWork
+	GoWork string   `json:",omitempty"`
+	Env    []string `json:",omitempty"`
+}
+
+type RequireChange struct {
+	Path       string
+	OldVersion string `json:",omitempty"` // empty if newly required
+	NewVersion string
+}
+
+type ReplaceChange struct {
+	Path   string
+	OldNew string `json:",omitempty"` // empty if newly replaced
+	New    string
+}
+
+// Plan runs unpack against a memfs reading through dir, and reports
+// every change Unpack would make with the same options.
+// Nothing is written to disk.
+func Plan(fs packfs.FS, dir string, opts *Options) (*UnpackPlan, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	mfs := memfs.NewReadThrough(writefs.SysFS{}, "")
+	state, err := unpack(fs, mfs, absDir, opts)
+	if err != nil {
+		return nil, err
+	}
+	plan := &UnpackPlan{
+		Dir:       dir,
+		HostDir:   state.hostDir,
+		Modules:   state.modules,
+		Decisions: state.decisions,
+		Env:       state.env,
+	}
+	sort.SliceStable(plan.Modules, func(i, j int) bool {
+		return plan.Modules[i].Path < plan.Modules[j].Path
+	})
+
+	goModFile := filepath.Join(absDir, "go.mod")
+	plan.GoModRequires, plan.GoModReplaces, err = diffGoMod(mfs, goModFile)
+	if err != nil {
+		return nil, err
+	}
+	plan.GoSumAdds, _, err = diffLines(mfs, filepath.Join(absDir, "go.sum"))
+	if err != nil {
+		return nil, err
+	}
+	plan.ModulesTxtAdds, plan.ModulesTxtRemoves, err = diffLines(mfs, filepath.Join(absDir, "vendor", "modules.txt"))
+	if err != nil {
+		return nil, err
+	}
+	if opts != nil && opts.UseGoWork {
+		for _, e := range state.env {
+			if strings.HasPrefix(e, "GOWORK=") {
+				goWork, err := writefs.ReadFile(mfs, strings.TrimPrefix(e, "GOWORK="))
+				if err != nil {
+					return nil, err
+				}
+				plan.GoWork = string(goWork)
+			}
+		}
+	}
+	return plan, nil
+}
+
+// diffGoMod compares goModFile on disk with the one in mfs
+func diffGoMod(mfs writefs.FS, goModFile string) ([]*RequireChange, []*ReplaceChange, error) {
+	before, err := parseModFile(writefs.SysFS{}, goModFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	after, err := parseModFile(mfs, goModFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	if before == nil || after == nil {
+		return nil, nil, nil
+	}
+	oldRequires := make(map[string]string, len(before.Require))
+	for _, req := range before.Require {
+		oldRequires[req.Mod.Path] = req.Mod.Version
+	}
+	var requires []*RequireChange
+	for _, req := range after.Require {
+		old, ok := oldRequires[req.Mod.Path]
+		if ok && old == req.Mod.Version {
+			continue
+		}
+		requires = append(requires, &RequireChange{
+			Path:       req.Mod.Path,
+			OldVersion: old,
+			NewVersion: req.Mod.Version,
+		})
+	}
+
+	formatReplace := func(rep *modfile.Replace) string {
+		if rep.New.Version == "" {
+			return rep.New.Path
+		}
+		return rep.New.Path + " " + rep.New.Version
+	}
+	oldReplaces := make(map[string]string, len(before.Replace))
+	for _, rep := range before.Replace {
+		oldReplaces[rep.Old.String()] = formatReplace(rep)
+	}
+	var replaces []*ReplaceChange
+	for _, rep := range after.Replace {
+		newReplace := formatReplace(rep)
+		old, ok := oldReplaces[rep.Old.String()]
+		if ok && old == newReplace {
+			continue
+		}
+		replaces = append(replaces, &ReplaceChange{
+			Path:   rep.Old.String(),
+			OldNew: old,
+			New:    newReplace,
+		})
+	}
+	return requires, replaces, nil
+}
+
+func parseModFile(wfs writefs.FS, file string) (*modfile.File, error) {
+	content, err := writefs.ReadFile(wfs, file)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return modfile.Parse(file, content, nil)
+}
+
+// diffLines returns lines added to and removed from file on disk in mfs, ignoring order
+func diffLines(mfs writefs.FS, file string) (adds []string, removes []string, err error) {
+	before, err := writefs.ReadFile(writefs.SysFS{}, file)
+	if err != nil && !writefs.IsNotExist(err) {
+		return nil, nil, err
+	}
+	after, err := writefs.ReadFile(mfs, file)
+	if err != nil && !writefs.IsNotExist(err) {
+		return nil, nil, err
+	}
+	count := make(map[string]int)
+	for _, line := range strings.Split(string(before), "\n") {
+		if line != "" {
+			count[line]++
+		}
+	}
+	for _, line := range strings.Split(string(after), "\n") {
+		if line == "" {
+			continue
+		}
+		if count[line] > 0 {
+			count[line]--
+			continue
+		}
+		adds = append(adds, line)
+	}
+	for _, line := range strings.Split(string(before), "\n") {
+		if count[line] > 0 {
+			count[line]--
+			removes = append(removes, line)
+		}
+	}
+	return adds, removes, nil
+}
+
+func moduleActionOf(pkgChanges []*helper.PackageChange) ModuleAction {
+	if len(pkgChanges) == 0 {
+		return ModuleAction_Reuse
+	}
+	allAdd := true
+	allReuse := true
+	for _, pkg := range pkgChanges {
+		if pkg.Action != helper.PackageAction_Add {
+			allAdd = false
+		}
+		if pkg.Action != helper.PackageAction_Reuse {
+			allReuse = false
+		}
+	}
+	if allAdd {
+		return ModuleAction_Add
+	}
+	if allReuse {
+		return ModuleAction_Reuse
+	}
+	return ModuleAction_Override
+}
+
+// String formats the plan for review
+func (c *UnpackPlan) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "target: %s\n", c.Dir)
+	if c.HostDir != "" {
+		fmt.Fprintf(&b, "host dir: %s\n", c.HostDir)
+	}
+	b.WriteString("modules:\n")
+	for _, mod := range c.Modules {
+		fmt.Fprintf(&b, "  %-8s %s %s", mod.Action, mod.Path, mod.Version)
+		if mod.Reason != "" {
+			fmt.Fprintf(&b, " (%s)", mod.Reason)
+		}
+		b.WriteString("\n")
+		for _, pkg := range mod.Packages {
+			if pkg.Action == helper.PackageAction_Reuse {
+				continue
+			}
+			pkgDir := pkg.Dir
+			if pkgDir == "" {
+				pkgDir = "."
+			}
+			fmt.Fprintf(&b, "    %-8s %s\n", pkg.Action, pkgDir)
+			for _, file := range pkg.AddFiles {
+				fmt.Fprintf(&b, "      + %s\n", file)
+			}
+			for _, file := range pkg.DeleteFiles {
+				fmt.Fprintf(&b, "      - %s\n", file)
+			}
+		}
+	}
+	var conflicts []*ConflictDecision
+	for _, decision := range c.Decisions {
+		if decision.TargetVersion != "" && decision.TargetVersion != decision.PackVersion {
+			conflicts = append(conflicts, decision)
+		}
+	}
+	if len(conflicts) > 0 {
+		b.WriteString("conflicts:\n")
+		for _, decision := range conflicts {
+			fmt.Fprintf(&b, "  %s\n", decision.String())
+		}
+	}
+	if len(c.GoModRequires) > 0 || len(c.GoModReplaces) > 0 {
+		b.WriteString("go.mod:\n")
+		for _, req := range c.GoModRequires {
+			if req.OldVersion == "" {
+				fmt.Fprintf(&b, "  require %s %s\n", req.Path, req.NewVersion)
+			} else {
+				fmt.Fprintf(&b, "  require %s %s => %s\n", req.Path, req.OldVersion, req.NewVersion)
+			}
+		}
+		for _, rep := range c.GoModReplaces {
+			if rep.OldNew == "" {
+				fmt.Fprintf(&b, "  replace %s => %s\n", rep.Path, rep.New)
+			} else {
+				fmt.Fprintf(&b, "  replace %s => %s (was %s)\n", rep.Path, rep.New, rep.OldNew)
+			}
+		}
+	}
+	if len(c.GoSumAdds) > 0 {
+		b.WriteString("go.sum:\n")
+		for _, line := range c.GoSumAdds {
+			fmt.Fprintf(&b, "  + %s\n", line)
+		}
+	}
+	if len(c.ModulesTxtAdds) > 0 || len(c.ModulesTxtRemoves) > 0 {
+		b.WriteString("vendor/modules.txt:\n")
+		for _, line := range c.ModulesTxtRemoves {
+			fmt.Fprintf(&b, "  - %s\n", line)
+		}
+		for _, line := range c.ModulesTxtAdds {
+			fmt.Fprintf(&b, "  + %s\n", line)
+		}
+	}
+	if c.GoWork != "" {
+		b.WriteString("go.work:\n")
+		for _, line := range strings.Split(strings.TrimSuffix(c.GoWork, "\n"), "\n") {
+			if line == "" {
+				b.WriteString("\n")
+				continue
+			}
+			fmt.Fprintf(&b, "  %s\n", line)
+		}
+	}
+	if len(c.Env) > 0 {
+		fmt.Fprintf(&b, "env: %s\n", strings.Join(c.Env, " "))
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+package unpack
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// readTestTree reads every file under dir, keyed by slash path
+func readTestTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+	files := make(map[string]string)
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		files[filepath.ToSlash(rel)] = string(content)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+// go test -run TestPlan -v ./unpack
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		goMod   string // requires of the target
+		vendor  map[string]string
+		modules map[string]string // modules of the pack
+		files   map[string]string // files of the pack
+		opts    *Options
+		hostDir bool   // use TMP/host as NonVendorHostDir
+		expect  string // TMP is the parent of the target
+	}{
+		{
+			name:    "add",
+			modules: map[string]string{"example.com/a": "v1.0.0"},
+			files:   map[string]string{"vendor/example.com/a/a.go": "package a"},
+			expect: "target: TMP/target\n" +
+				"modules:\n" +
+				"  add      example.com/a v1.0.0\n" +
+				"    add      .\n" +
+				"      + a.go\n" +
+				"go.mod:\n" +
+				"  require example.com/a v1.0.0\n" +
+				"go.sum:\n" +
+				"  + example.com/a v1.0.0 h1:example.com/a@v1.0.0\n" +
+				"vendor/modules.txt:\n" +
+				"  + # example.com/a v1.0.0\n" +
+				"  + ## explicit; go1.18\n" +
+				"  + example.com/a\n",
+		},
+		{
+			name:    "override",
+			goMod:   "require example.com/a v1.0.0\n",
+			vendor:  map[string]string{"example.com/a/a.go": "package a // v1.0.0"},
+			modules: map[string]string{"example.com/a": "v1.1.0"},
+			files:   map[string]string{"vendor/example.com/a/a.go": "package a // v1.1.0"},
+			opts:    &Options{ConflictPolicy: ConflictPolicy_TakePack},
+			expect: "target: TMP/target\n" +
+				"modules:\n" +
+				"  override example.com/a v1.1.0\n" +
+				"    override .\n" +
+				"      + a.go\n" +
+				"conflicts:\n" +
+				"  example.com/a target=v1.0.0 pack=v1.1.0 policy=take-pack => take-pack (pack version taken)\n" +
+				"go.mod:\n" +
+				"  require example.com/a v1.0.0 => v1.1.0\n" +
+				"go.sum:\n" +
+				"  + example.com/a v1.1.0 h1:example.com/a@v1.1.0\n" +
+				"vendor/modules.txt:\n" +
+				"  - # example.com/a v1.0.0\n" +
+				"  + # example.com/a v1.1.0\n",
+		},
+		{
+			name:    "reuse",
+			goMod:   "require example.com/a v1.0.0\n",
+			vendor:  map[string]string{"example.com/a/a.go": "package a"},
+			modules: map[string]string{"example.com/a": "v1.0.0"},
+			files:   map[string]string{"vendor/example.com/a/a.go": "package a"},
+			expect: "target: TMP/target\n" +
+				"modules:\n" +
+				"  reuse    example.com/a v1.0.0\n",
+		},
+		{
+			name:    "skip",
+			goMod:   "require example.com/a v1.2.0\n",
+			vendor:  map[string]string{"example.com/a/a.go": "package a // v1.2.0"},
+			modules: map[string]string{"example.com/a": "v1.0.0"},
+			files:   map[string]string{"vendor/example.com/a/a.go": "package a // v1.0.0"},
+			opts:    &Options{ConflictPolicy: ConflictPolicy_KeepTarget},
+			expect: "target: TMP/target\n" +
+				"modules:\n" +
+				"  skip     example.com/a v1.2.0 (target version kept)\n" +
+				"conflicts:\n" +
+				"  example.com/a target=v1.2.0 pack=v1.0.0 policy=keep-target => keep-target (target version kept)\n",
+		},
+		{
+			name:    "go.work",
+			modules: map[string]string{"example.com/a": "v1.0.0"},
+			files:   map[string]string{"vendor/example.com/a/a.go": "package a"},
+			opts:    &Options{UseGoWork: true},
+			hostDir: true,
+			expect: "target: TMP/target\n" +
+				"host dir: TMP/host\n" +
+				"modules:\n" +
+				"  add      example.com/a v1.0.0\n" +
+				"    add      .\n" +
+				"      + a.go\n" +
+				"go.work:\n" +
+				"  go 1.18\n" +
+				"\n" +
+				"  use (\n" +
+				"  \tTMP/host/vendor/example.com/a\n" +
+				"  \tTMP/target\n" +
+				"  )\n" +
+				"env: GOWORK=TMP/host/go.work GOFLAGS=-mod=readonly\n",
+		},
+	}
+	t.Setenv("GOFLAGS", "")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "target")
+			newTestTarget(t, writefs.SysFS{}, dir, true)
+			if tt.goMod != "" {
+				err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example\n\ngo 1.18\n\n"+tt.goMod), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			var modulesTxt []string
+			for name, content := range tt.vendor {
+				file := filepath.Join(dir, "vendor", filepath.FromSlash(name))
+				err := os.MkdirAll(filepath.Dir(file), 0755)
+				if err != nil {
+					t.Fatal(err)
+				}
+				err = os.WriteFile(file, []byte(content), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+				mod := filepath.ToSlash(filepath.Dir(name))
+				modulesTxt = append(modulesTxt, "# "+mod+" "+strings.Fields(tt.goMod)[2]+"\n## explicit\n"+mod+"\n")
+			}
+			err := os.WriteFile(filepath.Join(dir, "vendor", "modules.txt"), []byte(strings.Join(modulesTxt, "")), 0644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := readTestTree(t, dir)
+
+			opts := &Options{}
+			if tt.opts != nil {
+				*opts = *tt.opts
+			}
+			opts.GoVersion = "1.18"
+			if tt.hostDir {
+				opts.NonVendorHostDir = filepath.Join(filepath.Dir(dir), "host")
+			}
+			fs := packtest.New(t, &packtest.Pack{Digest: "plan-" + tt.name, Modules: tt.modules, Files: tt.files})
+			plan, err := Plan(fs, dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			actual := strings.ReplaceAll(plan.String(), filepath.Dir(dir), "TMP")
+			if actual != tt.expect {
+				t.Fatalf("expect %s = %q, actual:%q", "plan", tt.expect, actual)
+			}
+			_, err = os.Stat(filepath.Join(filepath.Dir(dir), "host"))
+			if !os.IsNotExist(err) {
+				t.Fatalf("expect %s = %+v, actual:%+v", "host dir", "not exist", err)
+			}
+			after := readTestTree(t, dir)
+			if len(after) != len(before) {
+				t.Fatalf("expect %s = %+v, actual:%+v", "files", before, after)
+			}
+			for name, content := range before {
+				if after[name] != content {
+					t.Fatalf("expect %s = %q, actual:%q", name, content, after[name])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package unpack
+
+import (
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// UnpackResult describes what Unpack did
+type UnpackResult struct {
+	Dir     string
+	HostDir string `json:",omitempty"` // where modules go if not into the target's vendor
+
+	// Modules lists each module and its packages, whether added, overridden or reused
+	Modules   []*ModuleChange
+	Decisions []*ConflictDecision `json:",omitempty"`
+
+	// metadata files changed
+	GoModChanged      bool
+	GoSumChanged      bool
+	ModulesTxtChanged bool
+	GoWorkChanged     bool
+
+	// UpToDate is true if the unpack lock shows the same pack is already unpacked
+	UpToDate bool
+	// RemovedFiles are files of the previous unpack no longer in the pack, relative to the dir holding vendor
+	RemovedFiles []string `json:",omitempty"`
+
+	Env []string `json:",omitempty"`
+}
+
+func newUnpackResult(dir string, state *unpackState) *UnpackResult {
+	return &UnpackResult{
+		Dir:               dir,
+		HostDir:           state.hostDir,
+		Modules:           state.modules,
+		Decisions:         state.decisions,
+		GoModChanged:      state.goModChanged,
+		GoSumChanged:      state.goSumChanged,
+		ModulesTxtChanged: state.modulesTxtChanged,
+		GoWorkChanged:     state.goWorkChanged,
+		UpToDate:          state.upToDate,
+		RemovedFiles:      state.removedFiles,
+		Env:               state.env,
+	}
+}
+
+// Changed reports whether any file is changed
+func (c *UnpackResult) Changed() bool {
+	if c.GoModChanged || c.GoSumChanged || c.ModulesTxtChanged || c.GoWorkChanged || len(c.RemovedFiles) > 0 {
+		return true
+	}
+	for _, mod := range c.Modules {
+		if mod.Action == ModuleAction_Add || mod.Action == ModuleAction_Override {
+			return true
+		}
+	}
+	return false
+}
+
+// readMetaFiles reads files that exist, missing files are absent in the result
+func readMetaFiles(wfs writefs.FS, files []string) (map[string]string, error) {
+	contents := make(map[string]string, len(files))
+	for _, file := range files {
+		content, err := writefs.ReadFile(wfs, file)
+		if err != nil {
+			if writefs.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		contents[file] = string(content)
+	}
+	return contents, nil
+}
@@ -0,0 +1,106 @@
+package unpack
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/txfs"
+)
+
+// Tx is an unpack whose changes are kept until Commit or Rollback
+type Tx struct {
+	tfs    *txfs.FS
+	dir    string
+	state  *unpackState
+	unlock func()
+	done   bool
+}
+
+// Begin unpacks like Unpack, but snapshots go.mod, go.sum, vendor/modules.txt
+// and every vendor package dir before touching them, so the caller can run
+// a build before deciding to Commit or Rollback.
+// If unpack fails or panics, all changes are rolled back before Begin returns.
+// Paths written, e.g. the target, NonVendorHostDir and GoWorkFile, stay locked against other unpacks until Commit or Rollback.
+func Begin(fs packfs.FS, dir string, opts *Options) (*Tx, error) {
+	return BeginFS(fs, writefs.SysFS{}, dir, opts)
+}
+
+func BeginFS(fs packfs.FS, wfs writefs.FS, dir string, opts *Options) (*Tx, error) {
+	var lockTimeout time.Duration
+	if opts != nil {
+		lockTimeout = opts.LockTimeout
+	}
+	paths, err := writtenPaths(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockDirs(wfs, lockTimeout, paths...)
+	if err != nil {
+		return nil, err
+	}
+	tfs := txfs.New(wfs)
+	defer func() {
+		if e := recover(); e != nil {
+			defer unlock()
+			rbErr := tfs.Rollback()
+			if rbErr != nil {
+				log.Printf("rollback: %v", rbErr)
+			}
+			panic(e)
+		}
+	}()
+	state, err := unpack(fs, tfs, dir, opts)
+	if err != nil {
+		defer unlock()
+		rbErr := tfs.Rollback()
+		if rbErr != nil {
+			return nil, fmt.Errorf("%w, and rollback failed: %v", err, rbErr)
+		}
+		return nil, err
+	}
+	return &Tx{tfs: tfs, dir: dir, state: state, unlock: unlock}, nil
+}
+
+// Env returns the environment needed to build the target, see UnpackGoWork
+func (c *Tx) Env() []string {
+	return c.state.env
+}
+
+// Decisions returns how the version of each module is decided
+func (c *Tx) Decisions() []*ConflictDecision {
+	return c.state.decisions
+}
+
+// Result describes what unpack did
+func (c *Tx) Result() *UnpackResult {
+	return newUnpackResult(c.dir, c.state)
+}
+
+// Changed returns paths changed by unpack
+func (c *Tx) Changed() []string {
+	return c.tfs.Changed()
+}
+
+// Commit keeps all changes
+func (c *Tx) Commit() error {
+	if c.done {
+		return fmt.Errorf("transaction already done")
+	}
+	c.done = true
+	defer c.unlock()
+	c.tfs.Commit()
+	return nil
+}
+
+// Rollback restores the target to the state before Begin
+func (c *Tx) Rollback() error {
+	if c.done {
+		return fmt.Errorf("transaction already done")
+	}
+	c.done = true
+	defer c.unlock()
+	return c.tfs.Rollback()
+}
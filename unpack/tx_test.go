@@ -0,0 +1,152 @@
+package unpack
+
+import (
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+	"github.com/xhd2015/go-vendor-pack/writefs/txfs"
+)
+
+// dropSumFS hides go.sum lines of a module
+type dropSumFS struct {
+	packfs.FS
+	module string
+}
+
+func (c *dropSumFS) ReadFile(name string) ([]byte, error) {
+	content, err := c.FS.ReadFile(name)
+	if err != nil || name != "go.sum" {
+		return content, err
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.HasPrefix(line, c.module+" ") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+const testTargetGoMod = "module example\n\ngo 1.18\n"
+
+// newTestTarget writes an empty module into dir of wfs, with an empty vendor/modules.txt if vendor
+func newTestTarget(t *testing.T, wfs writefs.FS, dir string, vendor bool) {
+	t.Helper()
+	files := map[string]string{
+		"go.mod": testTargetGoMod,
+		"go.sum": "",
+	}
+	if vendor {
+		files["vendor/modules.txt"] = ""
+	}
+	for name, content := range files {
+		file := path.Join(dir, name)
+		err := wfs.MkdirAll(path.Dir(file), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writefs.WriteFile(wfs, file, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func expectTxTargetUntouched(t *testing.T, mfs *memfs.MemFS) {
+	goMod, err := writefs.ReadFile(mfs, "/target/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(goMod) != testTargetGoMod {
+		t.Fatalf("expect go.mod = %q, actual:%q", testTargetGoMod, goMod)
+	}
+	for _, name := range []string{"/target/go.sum", "/target/vendor/modules.txt"} {
+		content, err := writefs.ReadFile(mfs, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content) != 0 {
+			t.Fatalf("expect %s empty, actual:%q", name, content)
+		}
+	}
+	_, err = mfs.Stat("/target/vendor/github.com")
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect vendor/github.com removed, actual:%v", err)
+	}
+}
+
+// go test -run TestUnpackRollbackOnError -v ./unpack
+func TestUnpackRollbackOnError(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+
+	// github.com/xhd2015/go-inspect is unpacked before golang.org/x/tools fails
+	_, err = UnpackFS(&dropSumFS{FS: fs, module: "golang.org/x/tools"}, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err == nil || !strings.Contains(err.Error(), "golang.org/x/tools does not appear in go.sum") {
+		t.Fatalf("expect err = %s, actual:%v", "golang.org/x/tools does not appear in go.sum", err)
+	}
+	expectTxTargetUntouched(t, mfs)
+}
+
+// go test -run TestUnpackTxRollback -v ./unpack
+func TestUnpackTxRollback(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+
+	tx, err := BeginFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = mfs.Stat("/target/vendor/golang.org/x/tools/cover/profile.go")
+	if err != nil {
+		t.Fatalf("expect vendor file unpacked before rollback, actual:%v", err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectTxTargetUntouched(t, mfs)
+
+	err = tx.Commit()
+	if err == nil {
+		t.Fatalf("expect commit after rollback fails")
+	}
+}
+
+// go test -run TestMkdirTempThroughTx -v ./unpack
+func TestMkdirTempThroughTx(t *testing.T) {
+	tfs := txfs.New(writefs.SysFS{})
+	dirA, err := mkdirTemp(tfs, "vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirA)
+	dirB, err := mkdirTemp(tfs, "vendor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirB)
+	if dirA == dirB {
+		t.Fatalf("expect %s = %+v, actual:%+v", "distinct temp dirs", dirA+" != "+dirB, dirB)
+	}
+	for _, dir := range []string{dirA, dirB} {
+		info, err := os.Stat(dir)
+		if err != nil || !info.IsDir() {
+			t.Fatalf("expect %s = %+v, actual:%+v", dir, "created by os.MkdirTemp", err)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+package unpack
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/go_cmd"
+	"github.com/xhd2015/go-vendor-pack/unpack/helper"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/txfs"
+	"golang.org/x/mod/modfile"
+)
+
+type UninstallOptions struct {
+	// NonVendorHostDir is the host dir passed to unpack, where the lock is kept
+	// for non-vendor targets
+	NonVendorHostDir string
+	// CacheDir is the cache dir passed to unpack, where the lock is kept for
+	// targets using a cached or temp host dir
+	CacheDir string
+
+	// LockTimeout is like Options.LockTimeout
+	LockTimeout time.Duration
+}
+
+type UninstallResult struct {
+	Modules      []string // modules uninstalled
+	RemovedFiles []string // relative to the dir holding vendor
+}
+
+// Uninstall reverts a previous unpack of dir using the unpack lock: files written
+// by unpack are removed, requires and vendor/modules.txt stanzas are restored
+// to the ones before unpack or dropped, and added replaces and go.sum lines are removed.
+// In go.work mode, added uses are dropped and dropped replaces restored, go.work
+// is removed if unpack created it and nothing else is left in it.
+// Without a lock, what unpack changed is unknown, so Uninstall fails, which
+// is always the case with UseModCache.
+func Uninstall(dir string, opts *UninstallOptions) (*UninstallResult, error) {
+	return UninstallFS(writefs.SysFS{}, dir, opts)
+}
+
+func UninstallFS(wfs writefs.FS, dir string, opts *UninstallOptions) (*UninstallResult, error) {
+	if opts == nil {
+		opts = &UninstallOptions{}
+	}
+	// go.work may be shared, it is known only from the lock, read again once locked
+	lock, _, err := readUninstallLock(wfs, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	unlock, err := lockDirs(wfs, opts.LockTimeout, dir, opts.NonVendorHostDir, lock.GoWork)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	tfs := txfs.New(wfs)
+	res, err := uninstall(tfs, dir, opts)
+	if err != nil {
+		rbErr := tfs.Rollback()
+		if rbErr != nil {
+			return nil, fmt.Errorf("%w, and rollback failed: %v", err, rbErr)
+		}
+		return nil, err
+	}
+	tfs.Commit()
+	return res, nil
+}
+
+func uninstall(wfs writefs.FS, dir string, opts *UninstallOptions) (*UninstallResult, error) {
+	lock, lockDir, err := readUninstallLock(wfs, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &UninstallResult{}
+	res.RemovedFiles, err = removeStaleFiles(wfs, lockDir, lock, &Lock{})
+	if err != nil {
+		return nil, fmt.Errorf("removing files: %w", err)
+	}
+
+	if lock.GoWork != "" {
+		// go.mod, go.sum and modules.txt are not changed in go.work mode
+		for _, mod := range lock.Modules {
+			res.Modules = append(res.Modules, mod.Path)
+		}
+		err := revertGoWork(wfs, lock)
+		if err != nil {
+			return nil, err
+		}
+		err = removeLockFile(wfs, lockDir)
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(res.Modules)
+		return res, nil
+	}
+
+	goModFile := path.Join(dir, "go.mod")
+	goModContent, err := writefs.ReadFile(wfs, goModFile)
+	if err != nil && !writefs.IsNotExist(err) {
+		return nil, err
+	}
+	hasGoMod := err == nil
+	var newGoModContent []byte
+	if hasGoMod {
+		newGoModContent, _, err = go_cmd.EditGoModContent(goModFile, goModContent, func(f *modfile.File) error {
+			for _, mod := range lock.Modules {
+				if mod.Replace != "" {
+					for _, rep := range f.Replace {
+						if rep.Old.Path == mod.Path && rep.New.Path == mod.Replace {
+							err := f.DropReplace(rep.Old.Path, rep.Old.Version)
+							if err != nil {
+								return err
+							}
+						}
+					}
+				}
+				if mod.PrevVersion != "" {
+					err := f.AddRequire(mod.Path, mod.PrevVersion)
+					if err != nil {
+						return err
+					}
+					continue
+				}
+				err := f.DropRequire(mod.Path)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		if string(newGoModContent) != string(goModContent) {
+			err = writefs.WriteFile(wfs, goModFile, newGoModContent)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, mod := range lock.Modules {
+		res.Modules = append(res.Modules, mod.Path)
+		err := helper.RemoveGoSumLinesFS(wfs, dir, mod.Sums)
+		if err != nil {
+			return nil, fmt.Errorf("uninstalling %s: updating go.sum: %w", mod.Path, err)
+		}
+		if mod.PrevModulesTxt != "" {
+			err = helper.SetModulesTxtStanzaFS(wfs, dir, mod.Path, mod.PrevModulesTxt)
+		} else if mod.PrevVersion != "" {
+			// locks written before PrevModulesTxt
+			err = helper.SetModulesTxtVersionFS(wfs, dir, mod.Path, mod.PrevVersion)
+		} else {
+			err = helper.RemoveModulesTxtFS(wfs, dir, mod.Path)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("uninstalling %s: updating modules.txt: %w", mod.Path, err)
+		}
+	}
+
+	err = removeLockFile(wfs, lockDir)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(res.Modules)
+	return res, nil
+}
+
+// readUninstallLock finds the lock in NonVendorHostDir, dir, or the state dir of dir in CacheDir
+func readUninstallLock(wfs writefs.FS, dir string, opts *UninstallOptions) (lock *Lock, lockDir string, err error) {
+	lockDir = dir
+	if opts.NonVendorHostDir != "" {
+		lockDir = opts.NonVendorHostDir
+	}
+	lock, err = ReadLockFS(wfs, lockDir)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading unpack lock: %w", err)
+	}
+	if lock == nil && opts.NonVendorHostDir == "" {
+		stateDir, err := targetStateDir(opts.CacheDir, dir)
+		if err != nil {
+			return nil, "", err
+		}
+		lock, err = ReadLockFS(wfs, stateDir)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading unpack lock: %w", err)
+		}
+		lockDir = stateDir
+	}
+	if lock == nil {
+		return nil, "", fmt.Errorf("no unpack lock found for %s, cannot tell what unpack changed, unpacks with UseModCache keep none", dir)
+	}
+	return lock, lockDir, nil
+}
+
+// revertGoWork drops the uses added by unpack and restores dropped replaces,
+// go.work created by unpack is removed once nothing else is left in it
+func revertGoWork(wfs writefs.FS, lock *Lock) error {
+	empty, err := helper.RevertGoWorkFS(wfs, lock.GoWork, lock.GoWorkUses, lock.GoWorkReplaces)
+	if err != nil {
+		return fmt.Errorf("reverting %s: %w", lock.GoWork, err)
+	}
+	if !empty || !lock.GoWorkCreated {
+		return nil
+	}
+	// go.work.sum is written by the go command for the created go.work
+	for _, file := range []string{lock.GoWork, lock.GoWork + ".sum"} {
+		err := removeFileIfExists(wfs, file)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeLockFile(wfs writefs.FS, lockDir string) error {
+	return removeFileIfExists(wfs, path.Join(lockDir, LOCK_FILE))
+}
+
+func removeFileIfExists(wfs writefs.FS, file string) error {
+	_, err := wfs.Stat(file)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	return wfs.RemoveFile(file)
+}
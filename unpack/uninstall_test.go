@@ -0,0 +1,193 @@
+package unpack
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/packfs/packtest"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestUninstall -v ./unpack
+func TestUninstall(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+	_, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := UninstallFS(mfs, "/target", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Modules) == 0 || len(res.RemovedFiles) == 0 {
+		t.Fatalf("expect modules and files uninstalled, actual:%+v", res)
+	}
+	expectTxTargetUntouched(t, mfs)
+	_, err = mfs.Stat("/target/vendor/golang.org")
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect vendor/golang.org removed, actual:%v", err)
+	}
+	_, err = mfs.Stat("/target/" + LOCK_FILE)
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect lock removed, actual:%v", err)
+	}
+
+	// without lock nor pack
+	_, err = UninstallFS(mfs, "/target", nil)
+	if err == nil {
+		t.Fatalf("expect uninstall without lock fails")
+	}
+}
+
+// go test -run TestUninstallRestoresTarget -v ./unpack
+func TestUninstallRestoresTarget(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+	before := map[string]string{
+		"/target/go.mod":                                     "module example\n\ngo 1.14\n\nrequire golang.org/x/tools v0.9.0\n",
+		"/target/vendor/modules.txt":                         "# golang.org/x/tools v0.9.0\ngolang.org/x/tools/cover\n",
+		"/target/vendor/golang.org/x/tools/cover/profile.go": "package cover\n",
+	}
+	for name, content := range before {
+		err := mfs.MkdirAll(path.Dir(name), 0755)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = writefs.WriteFile(mfs, name, []byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	modulesTxt, err := writefs.ReadFile(mfs, "/target/vendor/modules.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(modulesTxt) == before["/target/vendor/modules.txt"] {
+		t.Fatalf("expect modules.txt updated by unpack, actual:%q", modulesTxt)
+	}
+
+	_, err = UninstallFS(mfs, "/target", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range before {
+		actual, err := writefs.ReadFile(mfs, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != content {
+			t.Fatalf("expect %s = %q, actual:%q", name, content, actual)
+		}
+	}
+}
+
+// go test -run TestUninstallCachedHostDir -v ./unpack
+func TestUninstallCachedHostDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	cacheDir := filepath.Join(tmpDir, "cache")
+	dir := filepath.Join(tmpDir, "target")
+	newTestTarget(t, writefs.SysFS{}, dir, false)
+	goMod, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = UnpackFromBase64Decode(testPack, dir, &Options{CacheDir: cacheDir, GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Uninstall(dir, &UninstallOptions{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != string(goMod) {
+		t.Fatalf("expect %s = %q, actual:%q", "go.mod", goMod, actual)
+	}
+	// the cached host dir is shared, not removed
+	entries, err := ListCache(cacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expect %s = %+v, actual:%+v", "len(entries)", 1, len(entries))
+	}
+}
+
+// go test -run TestUninstallGoWork -v ./unpack
+func TestUninstallGoWork(t *testing.T) {
+	fs := packtest.New(t, &packtest.Pack{
+		Modules: map[string]string{"example.com/a": "v1.0.0"},
+		Files:   map[string]string{"vendor/example.com/a/a.go": "package a"},
+	})
+	tests := []struct {
+		name   string
+		goWork string // existing go.work shared with other modules, empty to let unpack create one
+	}{
+		{name: "created"},
+		{name: "shared", goWork: "go 1.18\n\nuse ./tools\n\nreplace example.com/a => ../a\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			dir := filepath.Join(tmpDir, "target")
+			hostDir := filepath.Join(tmpDir, "host")
+			newTestTarget(t, writefs.SysFS{}, dir, true)
+			opts := &Options{GoVersion: "1.18", UseGoWork: true, NonVendorHostDir: hostDir}
+			if tt.goWork != "" {
+				opts.GoWorkFile = filepath.Join(tmpDir, "go.work")
+				err := os.WriteFile(opts.GoWorkFile, []byte(tt.goWork), 0644)
+				if err != nil {
+					t.Fatal(err)
+				}
+			}
+			before := readTestTree(t, tmpDir)
+			res, err := Unpack(fs, dir, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !res.GoWorkChanged {
+				t.Fatalf("expect %s = %+v, actual:%+v", "go.work changed", true, res)
+			}
+			_, err = Uninstall(dir, &UninstallOptions{NonVendorHostDir: hostDir})
+			if err != nil {
+				t.Fatal(err)
+			}
+			after := readTestTree(t, tmpDir)
+			for name := range after {
+				// dir locks are kept
+				if strings.HasSuffix(name, ".lock") {
+					delete(after, name)
+				}
+			}
+			if len(after) != len(before) {
+				t.Fatalf("expect %s = %+v, actual:%+v", "files", before, after)
+			}
+			for name, content := range before {
+				if after[name] != content {
+					t.Fatalf("expect %s = %q, actual:%q", name, content, after[name])
+				}
+			}
+		})
+	}
+}
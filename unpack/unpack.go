@@ -5,22 +5,27 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"math/rand"
 	"os"
-	"os/exec"
 	"path"
-
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
-	"github.com/xhd2015/go-inspect/sh"
+	"github.com/xhd2015/go-vendor-pack/go_cmd"
 	"github.com/xhd2015/go-vendor-pack/go_info"
 	pack_model "github.com/xhd2015/go-vendor-pack/pack/model"
 	"github.com/xhd2015/go-vendor-pack/packfs"
 	"github.com/xhd2015/go-vendor-pack/tar"
 	"github.com/xhd2015/go-vendor-pack/unpack/helper"
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"golang.org/x/mod/module"
 )
 
 type Options struct {
-	NonVendorHostDir       string // when the target project is not vendor-style, this defines where to put modules.if empty, will create a temporary dir
+	NonVendorHostDir       string // when the target project is not vendor-style, this defines where to put modules.if empty, will use a host dir in CacheDir
 	ForceUpgradeAllModules bool
 	ForceUpgradeModules    map[string]bool
 	ForceUpgradeModulePkgs map[string]map[string]bool // exmaple: {"a.b.c":{"d":true}}, NOTE: sub path should be relative
@@ -28,6 +33,51 @@ type Options struct {
 	IgnoreSums         bool
 	IgnoreUpdatingSums bool
 	OptionalSumModules map[string]bool // some modules is replaced, they will not appear in go.sum
+
+	// UseModCache installs modules into the module cache instead of vendor or NonVendorHostDir,
+	// and only adds require and go.sum lines to the target's go.mod, so the target
+	// can be built with -mod=mod without replace directives and without network.
+	// NOTE: vendor/modules.txt is not updated, build with -mod=mod if the target has a vendor dir.
+	// Each module must be packed whole, a vendored subset fails with helper.ErrPartialModule.
+	UseModCache bool
+	ModCacheDir string // the module cache dir, if empty, will use `go env GOMODCACHE`
+
+	// UseGoWork extracts modules into NonVendorHostDir(or a temp dir) even if the target has vendor,
+	// and adds them as `use` directives of a go.work, the target's go.mod and go.sum are never touched.
+	// No `replace` is written: a workspace module wins over replaces in the target's go.mod,
+	// and the go command rejects a go.work replacing a workspace module at all versions.
+	// See UnpackGoWork for the environment needed to build.
+	UseGoWork bool
+	// GoWorkFile is the go.work to create or merge, if empty, will use go.work in the host dir,
+	// or in a per-target dir of CacheDir if the host dir is cached, so the target is never touched.
+	GoWorkFile string
+
+	// GoVersion is the go version written to truncated go.mod and go.work, e.g. 1.18.
+	// If empty, will use `go version`.
+	GoVersion string
+
+	// ConflictPolicy decides which version wins when the target requires a module
+	// at a version different from the pack, see ConflictPolicy_*
+	ConflictPolicy ConflictPolicy
+	// ModuleConflictPolicies overrides ConflictPolicy for specific modules
+	ModuleConflictPolicies map[string]ConflictPolicy
+
+	// StableModTime sets the mtime of unpacked files to the time recorded in the pack,
+	// or its PackTimeUTC, so re-unpacking the same pack does not change mtimes
+	StableModTime bool
+
+	// CacheDir keeps host dirs extracted from packs when NonVendorHostDir is empty,
+	// one per pack digest and go version, so later unpacks reuse them, and the
+	// unpack lock of each target using them, see Uninstall.
+	// If empty, will use DefaultCacheDir(). See ListCache and GCCache.
+	CacheDir string
+	// NoCache creates a temp host dir on every unpack instead of using CacheDir,
+	// the unpack lock is still kept in CacheDir
+	NoCache bool
+
+	// LockTimeout is how long to wait for other processes writing the same target,
+	// NonVendorHostDir, GoWorkFile or ModCacheDir, 0 means DEFAULT_LOCK_TIMEOUT, negative disables locking
+	LockTimeout time.Duration
 }
 
 func NewTarFSWithBase64Decode(s string) (packfs.FS, error) {
@@ -37,10 +87,10 @@ func NewTarFSWithBase64Decode(s string) (packfs.FS, error) {
 }
 
 // UnpackFromBase64Decode will unpack files compressed in `s` into `dir`
-func UnpackFromBase64Decode(s string, dir string, opts *Options) error {
+func UnpackFromBase64Decode(s string, dir string, opts *Options) (*UnpackResult, error) {
 	fs, err := NewTarFSWithBase64Decode(s)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	return Unpack(fs, dir, opts)
 }
@@ -48,8 +98,8 @@ func UnpackFromBase64Decode(s string, dir string, opts *Options) error {
 func ReadGoList(fs packfs.FS) (*pack_model.GoList, error) {
 	jsonData, err := fs.ReadFile("go.list.json")
 	if err != nil {
-		if !packfs.IsNotExists(err) {
-			return nil, fmt.Errorf("missing go.list.json")
+		if packfs.IsNotExists(err) {
+			return nil, packfs.NewError(packfs.ErrKind_NotExists, fmt.Errorf("missing go.list.json"))
 		}
 		return nil, err
 	}
@@ -61,117 +111,728 @@ func ReadGoList(fs packfs.FS) (*pack_model.GoList, error) {
 	return goList, nil
 }
 
-func Unpack(fs packfs.FS, dir string, opts *Options) error {
+func Unpack(fs packfs.FS, dir string, opts *Options) (*UnpackResult, error) {
+	return UnpackFS(fs, writefs.SysFS{}, dir, opts)
+}
+
+// UnpackFS unpacks into dir of wfs, all reads and writes of the target
+// go through wfs, so it can unpack into memfs, an overlay or any other writefs.FS.
+// Changes are rolled back if unpack fails, see Begin.
+func UnpackFS(fs packfs.FS, wfs writefs.FS, dir string, opts *Options) (*UnpackResult, error) {
+	tx, err := BeginFS(fs, wfs, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Result(), nil
+}
+
+// UnpackGoWork unpacks with UseGoWork set, returns the environment
+// needed to build the target, e.g. GOWORK=/path/to/go.work
+func UnpackGoWork(fs packfs.FS, dir string, opts *Options) (env []string, err error) {
+	var goWorkOpts Options
+	if opts != nil {
+		goWorkOpts = *opts
+	}
+	goWorkOpts.UseGoWork = true
+	tx, err := Begin(fs, dir, &goWorkOpts)
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return tx.Env(), nil
+}
+
+// unpackState records what unpack did
+type unpackState struct {
+	hostDir      string
+	hasVendorDir bool
+	modules      []*ModuleChange
+	decisions    []*ConflictDecision
+	env          []string
+
+	goModChanged      bool
+	goSumChanged      bool
+	modulesTxtChanged bool
+	goWorkChanged     bool
+
+	upToDate     bool     // the lock matches the pack, nothing is done
+	removedFiles []string // stale files of the previous unpack
+}
+
+func unpack(fs packfs.FS, wfs writefs.FS, dir string, opts *Options) (*unpackState, error) {
+	metaFiles := []string{
+		path.Join(dir, "go.mod"),
+		path.Join(dir, "go.sum"),
+		path.Join(dir, "vendor", "modules.txt"),
+	}
+	before, err := readMetaFiles(wfs, metaFiles)
+	if err != nil {
+		return nil, err
+	}
+	state, err := doUnpack(fs, wfs, dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	after, err := readMetaFiles(wfs, metaFiles)
+	if err != nil {
+		return nil, err
+	}
+	state.goModChanged = before[metaFiles[0]] != after[metaFiles[0]]
+	state.goSumChanged = before[metaFiles[1]] != after[metaFiles[1]]
+	state.modulesTxtChanged = before[metaFiles[2]] != after[metaFiles[2]]
+	return state, nil
+}
+
+func doUnpack(fs packfs.FS, wfs writefs.FS, dir string, opts *Options) (*unpackState, error) {
 	if opts == nil {
 		opts = &Options{}
 	}
-	goVersion, err := go_info.GetGoVersionCached()
+	if opts.UseModCache && opts.UseGoWork {
+		return nil, fmt.Errorf("UseModCache and UseGoWork cannot be used together")
+	}
+	goVersion, err := getGoVersion(opts.GoVersion)
 	if err != nil {
-		return fmt.Errorf("get go version: %w", err)
+		return nil, err
 	}
 	forceUpgradeAll := opts.ForceUpgradeAllModules
 	forceUpgradeModules := opts.ForceUpgradeModules
 	versions, err := fs.ReadFile("go.mod.versions")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	versionMapping := parseGoModVersions(string(versions))
 
 	gomodWhitelistBytes, err := fs.ReadFile("go.mod.whitelist")
 	if err != nil {
 		if !packfs.IsNotExists(err) {
-			return err
+			return nil, err
 		}
 	}
 	gomodWhitelist := parseGoModWhitelist(string(gomodWhitelistBytes))
 
 	goSums, err := fs.ReadFile("go.sum")
 	if err != nil {
-		return err
+		return nil, err
 	}
 	goSumMapping := parseGoSums(string(goSums))
 
+	modules := listModules(versionMapping, gomodWhitelist)
+	targetVersions, err := readTargetVersions(wfs, dir)
+	if err != nil {
+		return nil, err
+	}
+	decisions, err := decideConflicts(modules, versionMapping, targetVersions, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &unpackState{
+		modules:   listSkippedModules(versionMapping, gomodWhitelist),
+		decisions: decisions,
+	}
+	if opts.UseModCache {
+		err := unpackModCache(fs, wfs, dir, opts, versionMapping, goSumMapping, state)
+		if err != nil {
+			return nil, err
+		}
+		return state, nil
+	}
+	if opts.UseGoWork && (goVersion.Major < 1 || (goVersion.Major == 1 && goVersion.Minor < 18)) {
+		return nil, fmt.Errorf("go.work requires go1.18 or above, actual: go%d.%d", goVersion.Major, goVersion.Minor)
+	}
+
 	// check if has vendor dir
-	vendorDir := path.Join(dir, "vendor")
-	stat, statErr := os.Stat(vendorDir)
-	var hasVendorDir bool
-	if statErr != nil {
-		if !os.IsNotExist(statErr) {
-			return statErr
+	hasVendorDir, err := helper.HasVendorFS(wfs, dir)
+	if err != nil {
+		return nil, err
+	}
+	state.hasVendorDir = hasVendorDir
+	// with go.work, modules always go to the host dir
+	useHostDir := !hasVendorDir || opts.UseGoWork
+
+	// the lock is kept where modules are copied to, a cached host dir is shared and
+	// a temp one is never reused, so their targets keep the lock in the cache, owning no files
+	lockDir := dir
+	lockOwnsFiles := true
+	if useHostDir {
+		if opts.NonVendorHostDir != "" {
+			lockDir = opts.NonVendorHostDir
+		} else {
+			lockDir, err = targetStateDir(opts.CacheDir, dir)
+			if err != nil {
+				return nil, err
+			}
+			lockOwnsFiles = false
+		}
+	}
+	oldLock, err := ReadLockFS(wfs, lockDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading unpack lock: %w", err)
+	}
+	packDigest, packTime, err := readPackDigest(fs)
+	if err != nil {
+		return nil, err
+	}
+	goVersionStr := fmt.Sprintf("%d.%d", goVersion.Major, goVersion.Minor)
+	// checked before the host dir is set up, so an up to date target is never written
+	if oldLock.upToDate(packDigest, targetVersions, !opts.UseGoWork) {
+		ok, err := oldLock.outsideUpToDate(wfs, dir, useHostDir, opts.UseGoWork, goVersionStr)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			state.upToDate = true
+			state.hostDir = oldLock.HostDir
+			if oldLock.GoWork != "" {
+				state.env = goWorkEnv(oldLock.GoWork, hasVendorDir)
+			}
+			vendorDir := dir
+			if useHostDir {
+				vendorDir = oldLock.HostDir
+			}
+			for _, mod := range oldLock.Modules {
+				state.modules = append(state.modules, &ModuleChange{
+					Path:    mod.Path,
+					Version: mod.Version,
+					Action:  ModuleAction_Reuse,
+					Dir:     path.Join(vendorDir, "vendor", mod.Path),
+				})
+			}
+			return state, nil
+		}
+	}
+
+	var copyOpts *helper.CopyOptions
+	if opts.StableModTime {
+		copyOpts, err = stableCopyOptions(packTime)
+		if err != nil {
+			return nil, err
 		}
-	} else if stat.IsDir() {
-		hasVendorDir = true
 	}
 	var tmpVendorDir string
-	if !hasVendorDir {
+	var cached bool
+	if useHostDir {
 		if opts.NonVendorHostDir != "" {
 			tmpVendorDir = opts.NonVendorHostDir
+		} else if !opts.NoCache && isSysFS(wfs) {
+			var unlock func()
+			tmpVendorDir, unlock, err = cachedHostDir(fs, opts.CacheDir, packDigest, packTime, modules, goVersion, opts.LockTimeout)
+			if err != nil {
+				return nil, err
+			}
+			defer unlock()
+			cached = true
 		} else {
 			var err error
-			tmpVendorDir, err = os.MkdirTemp(os.TempDir(), "vendor")
+			tmpVendorDir, err = mkdirTemp(wfs, "vendor")
 			if err != nil {
-				return err
+				return nil, err
 			}
 			log.Printf("creating temp non-vendor host dir: %s", tmpVendorDir)
 		}
+		state.hostDir = tmpVendorDir
+	}
+	newLock := &Lock{
+		FormatVersion: LOCK_FORMAT_VERSION,
+		Digest:        packDigest,
+		PackTimeUTC:   packTime,
+	}
+	if useHostDir {
+		newLock.HostDir = tmpVendorDir
+		newLock.GoVersion = goVersionStr
+	}
+	goSumBefore, err := readGoSumLines(wfs, dir)
+	if err != nil {
+		return nil, err
 	}
 
-	for module, version := range versionMapping {
-		// skip non-whitelist
-		if len(gomodWhitelist) > 0 && !gomodWhitelist[module] {
-			continue
-		}
-		if version == "" {
+	var goWorkUses []*helper.GoWorkUse
+	for _, decision := range state.decisions {
+		module := decision.Path
+		version := decision.PackVersion
+		if decision.Action == ConflictAction_KeepTarget {
+			state.modules = append(state.modules, &ModuleChange{
+				Path:    module,
+				Version: decision.TargetVersion,
+				Action:  ModuleAction_Skip,
+				Reason:  decision.Reason,
+			})
+			// files installed by a previous unpack are still in use
+			if oldMod := oldLock.Module(module); oldMod != nil {
+				newLock.Modules = append(newLock.Modules, oldMod)
+			}
 			continue
 		}
-		// get sum
-		optionalSum := opts.OptionalSumModules[module]
+		// get sum, workspace modules do not need sums
+		optionalSum := opts.OptionalSumModules[module] || opts.UseGoWork
 		sums := goSumMapping[module]
 		if len(sums) == 0 && !optionalSum {
-			return fmt.Errorf("module %s does not appear in go.sum, check if it is replaced, if so add it to OptionalSumModules", module)
+			return nil, fmt.Errorf("module %s does not appear in go.sum, check if it is replaced, if so add it to OptionalSumModules", module)
 		}
 		targetDir := dir
-		if !hasVendorDir {
+		if useHostDir {
 			targetDir = tmpVendorDir
 		}
-		added, err := helper.AddVendor(targetDir, module, fs, forceUpgradeAll || forceUpgradeModules[module], opts.ForceUpgradeModulePkgs[module])
+		// packages installed by a previous unpack at another version are owned, override them
+		oldMod := oldLock.Module(module)
+		ownedUpgrade := oldMod != nil && oldMod.Version != version
+		// a cached host dir is complete and shared, never override it
+		override := !cached && (forceUpgradeAll || forceUpgradeModules[module] || decision.Override || ownedUpgrade)
+		var overrideSubPath map[string]bool
+		if !cached {
+			overrideSubPath = opts.ForceUpgradeModulePkgs[module]
+		}
+		pkgChanges, err := helper.AddVendorFSWithOptions(wfs, targetDir, module, fs, override, overrideSubPath, copyOpts)
 		if err != nil {
-			return fmt.Errorf("unpacking %s: add vendor: %w", module, err)
+			return nil, fmt.Errorf("unpacking %s: add vendor: %w", module, err)
 		}
-		if added && !(opts.IgnoreUpdatingSums || opts.IgnoreSums) {
+		lockMod, err := newLockModule(fs, module, version, versionMapping, oldMod, pkgChanges)
+		if err != nil {
+			return nil, fmt.Errorf("unpacking %s: list files: %w", module, err)
+		}
+		if oldMod != nil {
+			lockMod.PrevVersion = oldMod.PrevVersion
+			lockMod.PrevModulesTxt = oldMod.PrevModulesTxt
+			lockMod.Sums = oldMod.Sums
+		} else {
+			lockMod.PrevVersion = targetVersions[module]
+			lockMod.PrevModulesTxt, err = helper.ReadModulesTxtStanzaFS(wfs, dir, module)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if !opts.UseGoWork && !(opts.IgnoreUpdatingSums || opts.IgnoreSums) {
+			for _, sum := range sums {
+				line := fmt.Sprintf("%s %s", module, sum)
+				if !goSumBefore[line] && !contains(lockMod.Sums, line) {
+					lockMod.Sums = append(lockMod.Sums, line)
+				}
+			}
+		}
+		if !lockOwnsFiles {
+			lockMod.Files = nil
+		} else if useHostDir {
+			lockMod.Files = append(lockMod.Files, path.Join("vendor", module, "go.mod"))
+		}
+		if useHostDir && !opts.UseGoWork {
+			lockMod.Replace = path.Join(tmpVendorDir, "vendor", module)
+		}
+		newLock.Modules = append(newLock.Modules, lockMod)
+		state.modules = append(state.modules, &ModuleChange{
+			Path:     module,
+			Version:  version,
+			Action:   moduleActionOf(pkgChanges),
+			Dir:      path.Join(targetDir, "vendor", module),
+			Packages: pkgChanges,
+		})
+		// go.mod, go.sum and modules.txt are already up to date
+		added := helper.HasPackageChange(pkgChanges) || targetVersions[module] != version
+		if added && !opts.UseGoWork && !(opts.IgnoreUpdatingSums || opts.IgnoreSums) {
 			modSums := make([]string, 0, len(sums))
 			for _, sum := range sums {
 				modSums = append(modSums, fmt.Sprintf("%s %s", module, sum))
 			}
-			err := helper.AddVersionAndSum(dir, module, version, strings.Join(modSums, "\n"), "")
+			err := helper.AddVersionAndSumFS(wfs, dir, module, version, strings.Join(modSums, "\n"), "")
 			if err != nil {
-				return fmt.Errorf("unpacking %s: add dep %v", module, err)
+				return nil, fmt.Errorf("unpacking %s: add dep %v", module, err)
 			}
 		}
 		// update go.mod with replace, and add missing go.mod
-		if !hasVendorDir {
+		if useHostDir {
 			tmpModuleDir := path.Join(tmpVendorDir, "vendor", module)
-			_, _, err := sh.RunBashWithOpts([]string{goModEditReplace(module, tmpModuleDir)}, sh.RunBashOptions{
-				Verbose: true,
-				FilterCmd: func(cmd *exec.Cmd) {
-					cmd.Dir = dir
-				},
-			})
+			err := helper.TruncateGoModFS(wfs, path.Join(tmpModuleDir, "go.mod"), module, goVersion.Major, goVersion.Minor)
 			if err != nil {
-				return fmt.Errorf("replacing non-vendor module:%s %w", module, err)
+				return nil, err
 			}
-
-			err = helper.TruncateGoMod(path.Join(tmpModuleDir, "go.mod"), module, goVersion.Major, goVersion.Minor)
+			if opts.UseGoWork {
+				goWorkUses = append(goWorkUses, &helper.GoWorkUse{Dir: tmpModuleDir, Module: module})
+				continue
+			}
+			err = helper.AddReplaceFS(wfs, dir, module, tmpModuleDir)
 			if err != nil {
-				return err
+				return nil, fmt.Errorf("replacing non-vendor module:%s %w", module, err)
+			}
+		}
+	}
+	var goWorkFile string
+	if opts.UseGoWork {
+		goWorkFile = opts.GoWorkFile
+		if goWorkFile == "" {
+			// a cached host dir is shared by targets, each needs its own go.work
+			goWorkDir := tmpVendorDir
+			if cached {
+				goWorkDir = lockDir
+			}
+			goWorkFile = filepath.Join(goWorkDir, "go.work")
+		}
+		goWorkFile, err = filepath.Abs(goWorkFile)
+		if err != nil {
+			return nil, err
+		}
+		newLock.GoWork = goWorkFile
+	}
+	state.removedFiles, err = removeStaleFiles(wfs, lockDir, oldLock, newLock)
+	if err != nil {
+		return nil, fmt.Errorf("removing stale files: %w", err)
+	}
+	if opts.UseGoWork {
+		changed, err := addGoWork(wfs, dir, goWorkFile, goVersionStr, goWorkUses, oldLock, newLock)
+		if err != nil {
+			return nil, err
+		}
+		state.env = goWorkEnv(goWorkFile, hasVendorDir)
+		state.goWorkChanged = changed
+	}
+	err = writeLock(wfs, lockDir, newLock)
+	if err != nil {
+		return nil, fmt.Errorf("writing unpack lock: %w", err)
+	}
+	return state, nil
+}
+
+// stableCopyOptions uses PackTimeUTC for files without time in the pack
+func stableCopyOptions(packTime string) (*helper.CopyOptions, error) {
+	defaultModTime := time.Unix(0, 0)
+	if packTime != "" {
+		t, err := time.Parse("2006-01-02 15:04:05", packTime)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PackTimeUTC: %w", err)
+		}
+		defaultModTime = t
+	}
+	return &helper.CopyOptions{
+		StableModTime:  true,
+		DefaultModTime: defaultModTime,
+	}, nil
+}
+
+func getGoVersion(version string) (*go_info.GoVersion, error) {
+	if version == "" {
+		goVersion, err := go_info.GetGoVersionCached()
+		if err != nil {
+			return nil, fmt.Errorf("get go version: %w", err)
+		}
+		return goVersion, nil
+	}
+	// 1.18, 1.18.1
+	list := strings.Split(strings.TrimPrefix(version, "go"), ".")
+	if len(list) < 2 {
+		return nil, fmt.Errorf("invalid go version, expect 1.x: %s", version)
+	}
+	major, err := strconv.Atoi(list[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid go version %s: %w", version, err)
+	}
+	minor, err := strconv.Atoi(list[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid go version %s: %w", version, err)
+	}
+	return &go_info.GoVersion{Major: major, Minor: minor}, nil
+}
+
+// mkdirTemp creates a temp dir like os.MkdirTemp, for
+// other FS, the dir is only created inside wfs
+func mkdirTemp(wfs writefs.FS, pattern string) (string, error) {
+	if isSysFS(wfs) {
+		return os.MkdirTemp(os.TempDir(), pattern)
+	}
+	dir := filepath.Join(os.TempDir(), pattern+strconv.FormatUint(uint64(rand.Uint32()), 10))
+	err := wfs.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// addGoWork uses the target dir and all unpacked modules in go.work, goWorkFile is absolute.
+// Uses added by the previous unpack and no longer needed are dropped, the edits
+// are recorded in newLock on top of the ones in oldLock, see Uninstall
+func addGoWork(wfs writefs.FS, dir string, goWorkFile string, goVersion string, uses []*helper.GoWorkUse, oldLock *Lock, newLock *Lock) (changed bool, err error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false, err
+	}
+	allUses := make([]*helper.GoWorkUse, 0, len(uses)+1)
+	allUses = append(allUses, &helper.GoWorkUse{Dir: absDir})
+	for _, use := range uses {
+		useDir, err := filepath.Abs(use.Dir)
+		if err != nil {
+			return false, err
+		}
+		allUses = append(allUses, &helper.GoWorkUse{Dir: useDir, Module: use.Module})
+	}
+	before, err := readMetaFiles(wfs, []string{goWorkFile})
+	if err != nil {
+		return false, err
+	}
+	beforeUses, beforeReplaces, exists, err := helper.ReadGoWorkFS(wfs, goWorkFile)
+	if err != nil {
+		return false, err
+	}
+	var prevUses map[string]bool
+	if oldLock != nil && oldLock.GoWork == goWorkFile {
+		newLock.GoWorkCreated = oldLock.GoWorkCreated
+		newLock.GoWorkReplaces = oldLock.GoWorkReplaces
+		prevUses = make(map[string]bool, len(oldLock.GoWorkUses))
+		for _, use := range oldLock.GoWorkUses {
+			prevUses[use] = true
+		}
+	}
+	if !exists {
+		newLock.GoWorkCreated = true
+	}
+	// e.g. module dirs of another cached host dir
+	var staleUses []string
+	for use := range prevUses {
+		if !containsGoWorkUse(allUses, use) {
+			staleUses = append(staleUses, use)
+		}
+	}
+	if len(staleUses) > 0 {
+		sort.Strings(staleUses)
+		_, err := helper.RevertGoWorkFS(wfs, goWorkFile, staleUses, nil)
+		if err != nil {
+			return false, fmt.Errorf("updating %s: %w", goWorkFile, err)
+		}
+	}
+	err = helper.AddGoWorkUseFS(wfs, goWorkFile, goVersion, allUses)
+	if err != nil {
+		return false, fmt.Errorf("updating %s: %w", goWorkFile, err)
+	}
+	for _, use := range allUses {
+		if !beforeUses[use.Dir] || prevUses[use.Dir] {
+			newLock.GoWorkUses = append(newLock.GoWorkUses, use.Dir)
+		}
+	}
+	_, afterReplaces, _, err := helper.ReadGoWorkFS(wfs, goWorkFile)
+	if err != nil {
+		return false, err
+	}
+	for _, replace := range beforeReplaces {
+		if !containsGoWorkReplace(afterReplaces, replace) {
+			newLock.GoWorkReplaces = append(newLock.GoWorkReplaces, replace)
+		}
+	}
+	after, err := readMetaFiles(wfs, []string{goWorkFile})
+	if err != nil {
+		return false, err
+	}
+	return before[goWorkFile] != after[goWorkFile], nil
+}
+
+func containsGoWorkUse(uses []*helper.GoWorkUse, dir string) bool {
+	for _, use := range uses {
+		if use.Dir == dir {
+			return true
+		}
+	}
+	return false
+}
+
+func containsGoWorkReplace(replaces []*helper.GoWorkReplace, replace *helper.GoWorkReplace) bool {
+	for _, r := range replaces {
+		if *r == *replace {
+			return true
+		}
+	}
+	return false
+}
+
+// goWorkEnv returns the env to build the target with goWorkFile
+func goWorkEnv(goWorkFile string, hasVendorDir bool) []string {
+	env := []string{"GOWORK=" + goWorkFile}
+	if hasVendorDir {
+		// the target's vendor/modules.txt does not know the workspace modules
+		env = append(env, "GOFLAGS="+appendGoFlag(os.Getenv("GOFLAGS"), "-mod=readonly"))
+	}
+	return env
+}
+
+// appendGoFlag appends flag to goFlags, dropping
+// earlier values of the same flag, e.g. -mod=vendor
+func appendGoFlag(goFlags string, flag string) string {
+	name := strings.TrimLeft(flag, "-")
+	if i := strings.Index(name, "="); i >= 0 {
+		name = name[:i]
+	}
+	var flags []string
+	for _, f := range strings.Fields(goFlags) {
+		fname := strings.TrimLeft(f, "-")
+		if fname == name || strings.HasPrefix(fname, name+"=") {
+			continue
+		}
+		flags = append(flags, f)
+	}
+	return strings.Join(append(flags, flag), " ")
+}
+
+// getModCacheDir returns modCacheDir, or `go env GOMODCACHE` if empty
+func getModCacheDir(modCacheDir string) (string, error) {
+	if modCacheDir != "" {
+		return modCacheDir, nil
+	}
+	modCacheDir, err := go_cmd.GoEnv("GOMODCACHE")
+	if err != nil {
+		return "", err
+	}
+	if modCacheDir == "" {
+		return "", fmt.Errorf("GOMODCACHE not set")
+	}
+	return modCacheDir, nil
+}
+
+func unpackModCache(fs packfs.FS, wfs writefs.FS, dir string, opts *Options, versionMapping map[string]string, goSumMapping map[string][]string, state *unpackState) error {
+	modCacheDir, err := getModCacheDir(opts.ModCacheDir)
+	if err != nil {
+		return err
+	}
+	state.hostDir = modCacheDir
+	var moduleTimes map[string]*time.Time
+	goList, err := ReadGoList(fs)
+	if err != nil {
+		if !packfs.IsNotExists(err) {
+			return err
+		}
+	} else {
+		moduleTimes = make(map[string]*time.Time, len(goList.Modules))
+		for _, mod := range goList.Modules {
+			if mod.ModulePublic != nil {
+				moduleTimes[mod.Path] = mod.Time
 			}
 		}
 	}
+
+	for _, decision := range state.decisions {
+		module := decision.Path
+		version := decision.PackVersion
+		if decision.Action == ConflictAction_KeepTarget {
+			state.modules = append(state.modules, &ModuleChange{
+				Path:    module,
+				Version: decision.TargetVersion,
+				Action:  ModuleAction_Skip,
+				Reason:  decision.Reason,
+			})
+			continue
+		}
+		optionalSum := opts.OptionalSumModules[module]
+		sums := goSumMapping[module]
+		if len(sums) == 0 && !optionalSum {
+			return fmt.Errorf("module %s does not appear in go.sum, check if it is replaced, if so add it to OptionalSumModules", module)
+		}
+		// nested modules are installed separately
+		excludeDirs := make(map[string]bool)
+		for other := range versionMapping {
+			if strings.HasPrefix(other, module+"/") {
+				excludeDirs[strings.TrimPrefix(other, module+"/")] = true
+			}
+		}
+		goMod, err := readPackGoMod(fs, module, version)
+		if err != nil {
+			return fmt.Errorf("unpacking %s: %w", module, err)
+		}
+		added, err := helper.AddModCacheFS(wfs, modCacheDir, module, version, fs, &helper.ModCacheOptions{
+			GoMod:       goMod,
+			Sums:        sums,
+			ExcludeDirs: excludeDirs,
+			Time:        moduleTimes[module],
+		})
+		if err != nil {
+			return fmt.Errorf("unpacking %s: add module cache: %w", module, err)
+		}
+		action := ModuleAction_Add
+		if !added {
+			action = ModuleAction_Reuse
+		}
+		state.modules = append(state.modules, &ModuleChange{
+			Path:    module,
+			Version: version,
+			Action:  action,
+		})
+		if !added && decision.TargetVersion == version {
+			continue
+		}
+		var modSums []string
+		if !(opts.IgnoreUpdatingSums || opts.IgnoreSums) {
+			modSums = make([]string, 0, len(sums))
+			for _, sum := range sums {
+				modSums = append(modSums, fmt.Sprintf("%s %s", module, sum))
+			}
+		}
+		_, err = helper.AddRequireAndSumFS(wfs, dir, module, version, strings.Join(modSums, "\n"), "")
+		if err != nil {
+			return fmt.Errorf("unpacking %s: add dep %v", module, err)
+		}
+	}
 	return nil
 }
 
-func goModEditReplace(oldpath string, newPath string) string {
-	return fmt.Sprintf("go mod edit -replace=%s=%s", sh.Quote(oldpath), sh.Quote(newPath))
+// listModules returns sorted modules to be unpacked, skipping
+// non-whitelist and versionless ones
+func listModules(versionMapping map[string]string, gomodWhitelist map[string]bool) []string {
+	modules := make([]string, 0, len(versionMapping))
+	for module, version := range versionMapping {
+		if len(gomodWhitelist) > 0 && !gomodWhitelist[module] {
+			continue
+		}
+		if version == "" {
+			continue
+		}
+		modules = append(modules, module)
+	}
+	sort.Strings(modules)
+	return modules
+}
+
+// listSkippedModules is the complement of listModules
+func listSkippedModules(versionMapping map[string]string, gomodWhitelist map[string]bool) []*ModuleChange {
+	var skipped []*ModuleChange
+	for module, version := range versionMapping {
+		var reason string
+		if len(gomodWhitelist) > 0 && !gomodWhitelist[module] {
+			reason = "not in go.mod.whitelist"
+		} else if version == "" {
+			reason = "no version"
+		} else {
+			continue
+		}
+		skipped = append(skipped, &ModuleChange{
+			Path:    module,
+			Version: version,
+			Action:  ModuleAction_Skip,
+			Reason:  reason,
+		})
+	}
+	sort.Slice(skipped, func(i, j int) bool {
+		return skipped[i].Path < skipped[j].Path
+	})
+	return skipped
+}
+
+// readPackGoMod reads the original go.mod saved by pack, returns nil if not packed
+func readPackGoMod(fs packfs.FS, mod string, version string) ([]byte, error) {
+	escPath, err := module.EscapePath(mod)
+	if err != nil {
+		return nil, err
+	}
+	escVersion, err := module.EscapeVersion(version)
+	if err != nil {
+		return nil, err
+	}
+	goMod, err := fs.ReadFile(path.Join(pack_model.DIR_MOD_CACHE, escPath, "@v", escVersion+".mod"))
+	if err != nil {
+		if packfs.IsNotExists(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return goMod, nil
 }
 
 func parseGoModWhitelist(s string) map[string]bool {
@@ -0,0 +1,143 @@
+package unpack
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestUnpackFSMemFS -v ./unpack
+func TestUnpackFSMemFS(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	err = mfs.MkdirAll("/target/vendor", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writefs.WriteFile(mfs, "/target/go.mod", []byte("module example\n\ngo 1.14\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writefs.WriteFile(mfs, "/target/vendor/modules.txt", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goMod, err := writefs.ReadFile(mfs, "/target/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectRequire := "golang.org/x/tools v0.8.0"
+	if !strings.Contains(string(goMod), expectRequire) {
+		t.Fatalf("expect go.mod contains %s, actual:%s", expectRequire, goMod)
+	}
+	modulesTxt, err := writefs.ReadFile(mfs, "/target/vendor/modules.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectModule := "# golang.org/x/tools v0.8.0\n## explicit; go1.14\n"
+	if !strings.Contains(string(modulesTxt), expectModule) {
+		t.Fatalf("expect modules.txt contains %q, actual:%s", expectModule, modulesTxt)
+	}
+	_, err = mfs.Stat("/target/vendor/golang.org/x/tools/cover/profile.go")
+	if err != nil {
+		t.Fatalf("expect vendor file unpacked, actual:%v", err)
+	}
+	sum, err := writefs.ReadFile(mfs, "/target/go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(sum), "golang.org/x/tools v0.8.0 h1:") {
+		t.Fatalf("expect go.sum contains golang.org/x/tools, actual:%s", sum)
+	}
+}
+
+// go test -run TestUnpackFSNonVendorMemFS -v ./unpack
+func TestUnpackFSNonVendorMemFS(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	err = mfs.MkdirAll("/target", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writefs.WriteFile(mfs, "/target/go.mod", []byte("module example\n\ngo 1.14\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18", NonVendorHostDir: "/host"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	goMod, err := writefs.ReadFile(mfs, "/target/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectReplace := "golang.org/x/tools => /host/vendor/golang.org/x/tools"
+	if !strings.Contains(string(goMod), expectReplace) {
+		t.Fatalf("expect go.mod contains %s, actual:%s", expectReplace, goMod)
+	}
+	hostGoMod, err := writefs.ReadFile(mfs, "/host/vendor/golang.org/x/tools/go.mod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectHostGoMod := "module golang.org/x/tools\n\ngo 1.18\n"
+	if string(hostGoMod) != expectHostGoMod {
+		t.Fatalf("expect host go.mod = %q, actual:%q", expectHostGoMod, hostGoMod)
+	}
+}
+
+// go test -run TestUnpackFSTwiceNoChange -v ./unpack
+func TestUnpackFSTwiceNoChange(t *testing.T) {
+	fs, err := NewTarFSWithBase64Decode(testPack)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mfs := memfs.New()
+	newTestTarget(t, mfs, "/target", true)
+	res, err := UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.GoModChanged || !res.GoSumChanged || !res.ModulesTxtChanged {
+		t.Fatalf("expect first unpack changes go.mod, go.sum and modules.txt, actual:%+v", res)
+	}
+	goSum, err := writefs.ReadFile(mfs, "/target/go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err = UnpackFS(fs, mfs, "/target", &Options{GoVersion: "1.18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Changed() {
+		t.Fatalf("expect second unpack changes nothing, actual:%+v", res)
+	}
+	for _, mod := range res.Modules {
+		if mod.Action != ModuleAction_Reuse && mod.Action != ModuleAction_Skip {
+			t.Fatalf("expect %s = %s, actual:%s", mod.Path, ModuleAction_Reuse, mod.Action)
+		}
+	}
+	newGoSum, err := writefs.ReadFile(mfs, "/target/go.sum")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(newGoSum) != string(goSum) {
+		t.Fatalf("expect go.sum = %q, actual:%q", goSum, newGoSum)
+	}
+}
@@ -13,7 +13,7 @@ func TestUnpack(t *testing.T) {
 	sh.RunBash([]string{
 		"cp -R ./testdata/target ./testdata/target_patched",
 	}, true)
-	err := UnpackFromBase64Decode(testPack, "./testdata/target_patched", &Options{
+	_, err := UnpackFromBase64Decode(testPack, "./testdata/target_patched", &Options{
 		ForceUpgradeModules: map[string]bool{
 			"github.com/xhd2015/go-inspect": true,
 		},
@@ -20,6 +20,9 @@ type MemFileInfo interface {
 	Parent() MemFileInfo
 	GetData() interface{}
 	SetData(data interface{})
+
+	// InBase reports whether the content is still read through from the base FS
+	InBase() bool
 }
 
 type entryType int
@@ -39,10 +42,17 @@ type dirEntry struct {
 	children    []*dirEntry
 	childrenMap map[string]*dirEntry
 
-	perm os.FileMode
+	perm    os.FileMode
+	modTime time.Time
 
 	buf  buf
 	data interface{} // associated data
+
+	// read through, see NewReadThrough
+	fromBase    bool  // exists in the base FS
+	baseContent bool  // file content is not written, read from the base FS
+	baseSize    int64 // size of baseContent
+	loaded      bool  // dir children of the base FS are added
 }
 
 type buf struct {
@@ -58,7 +68,7 @@ func (c *dirEntry) IsDir() bool {
 }
 
 func (c *dirEntry) ModTime() time.Time {
-	return time.Time{}
+	return c.modTime
 }
 
 func (c *dirEntry) Mode() fs.FileMode {
@@ -70,6 +80,9 @@ func (c *dirEntry) Name() string {
 }
 
 func (c *dirEntry) Size() int64 {
+	if c.baseContent {
+		return c.baseSize
+	}
 	return int64(c.buf.Len())
 }
 
@@ -89,15 +102,21 @@ func (c *dirEntry) GetData() interface{} {
 func (c *dirEntry) SetData(data interface{}) {
 	c.data = data
 }
+func (c *dirEntry) InBase() bool {
+	return c.baseContent
+}
 
-func navParent(path string, root *dirEntry) (*dirEntry, string, error) {
-	return navPath(path, true, root, false)
+// loadFunc adds children of dir in the base FS, basePath is the path of dir
+type loadFunc func(dir *dirEntry, basePath string) error
+
+func navParent(path string, root *dirEntry, load loadFunc) (*dirEntry, string, error) {
+	return navPath(path, true, root, false, load)
 }
-func navDir(path string, root *dirEntry, create bool) (*dirEntry, error) {
-	entry, _, err := navPath(path, false, root, create)
+func navDir(path string, root *dirEntry, create bool, load loadFunc) (*dirEntry, error) {
+	entry, _, err := navPath(path, false, root, create, load)
 	return entry, err
 }
-func navPath(path string, parent bool, root *dirEntry, create bool) (entry *dirEntry, baseName string, err error) {
+func navPath(path string, parent bool, root *dirEntry, create bool, load loadFunc) (entry *dirEntry, baseName string, err error) {
 	names, err := splitPath(path)
 	if err != nil {
 		return nil, "", err
@@ -114,6 +133,10 @@ func navPath(path string, parent bool, root *dirEntry, create bool) (entry *dirE
 	}
 	for i := 0; i < n; i++ {
 		name := names[i]
+		err := load(p, basePath(path, names[:i]))
+		if err != nil {
+			return nil, "", err
+		}
 		p.mutex.Lock()
 		next := p.childrenMap[name]
 		p.mutex.Unlock()
@@ -133,6 +156,7 @@ func navPath(path string, parent bool, root *dirEntry, create bool) (entry *dirE
 					parent:      p,
 					entryType:   entryType_dir,
 					childrenMap: make(map[string]*dirEntry),
+					loaded:      true,
 				}
 				p.mutex.Lock()
 				p.children = append(p.children, next)
@@ -144,9 +168,25 @@ func navPath(path string, parent bool, root *dirEntry, create bool) (entry *dirE
 		}
 		p = next
 	}
+	err = load(p, basePath(path, names[:n]))
+	if err != nil {
+		return nil, "", err
+	}
 	return p, baseName, nil
 }
 
+// basePath joins names, keeping path absolute if it is
+func basePath(path string, names []string) string {
+	p := filepath.Join(names...)
+	if filepath.IsAbs(path) {
+		return string(filepath.Separator) + p
+	}
+	if p == "" {
+		return "."
+	}
+	return p
+}
+
 var pathSplitor = regexp.MustCompile(`[/\\]`)
 
 func splitPath(path string) ([]string, error) {
@@ -6,6 +6,9 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/xhd2015/go-vendor-pack/writefs"
 )
@@ -19,9 +22,16 @@ type MemFS struct {
 	// and later copy will just be a os.Move operation
 	fsDir string
 	root  *dirEntry
+
+	// base is read through for entries not in memory, and never modified
+	base    writefs.FS
+	mutex   sync.Mutex
+	removed map[string]bool
 }
 
 var _ writefs.FS = (*MemFS)(nil)
+var _ writefs.FSWithTime = (*MemFS)(nil)
+var _ writefs.FSWithMode = (*MemFS)(nil)
 
 func New() *MemFS {
 	return &MemFS{
@@ -43,13 +53,103 @@ func NewAtFs(fsDir string) *MemFS {
 	}
 }
 
+// NewReadThrough returns a MemFS reading dirs and files missing in memory
+// from base, all writes and removes are kept in memory, so base is never modified.
+// Paths should be either all absolute or all relative.
+// If fsDir is not empty, written content is stored there, see NewAtFs.
+func NewReadThrough(base writefs.FS, fsDir string) *MemFS {
+	fs := NewAtFs(fsDir)
+	fs.base = base
+	fs.removed = make(map[string]bool)
+	return fs
+}
+
+// Removed returns paths of the base FS removed, sorted
+func (c *MemFS) Removed() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	list := make([]string, 0, len(c.removed))
+	for name := range c.removed {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// load adds children of dir in base once
+func (c *MemFS) load(dir *dirEntry, basePath string) error {
+	if c.base == nil || dir.entryType != entryType_dir {
+		return nil
+	}
+	dir.mutex.Lock()
+	defer dir.mutex.Unlock()
+	if dir.loaded {
+		return nil
+	}
+	infos, err := c.base.ReadDir(basePath)
+	if err != nil && !writefs.IsNotExist(err) {
+		return err
+	}
+	for _, info := range infos {
+		if dir.childrenMap[info.Name()] != nil {
+			continue
+		}
+		child := &dirEntry{
+			name:     info.Name(),
+			parent:   dir,
+			perm:     info.Mode().Perm(),
+			modTime:  info.ModTime(),
+			fromBase: true,
+		}
+		if info.IsDir() {
+			child.entryType = entryType_dir
+			child.childrenMap = make(map[string]*dirEntry)
+		} else {
+			child.entryType = entryType_file
+			child.baseContent = true
+			child.baseSize = info.Size()
+		}
+		dir.children = append(dir.children, child)
+		dir.childrenMap[child.name] = child
+	}
+	dir.loaded = true
+	return nil
+}
+
+// copyUp keeps the content of f read through from base in memory, or in fsDir
+func (c *MemFS) copyUp(f *dirEntry, name string) error {
+	if !f.baseContent {
+		return nil
+	}
+	content, err := writefs.ReadFile(c.base, name)
+	if err != nil {
+		return err
+	}
+	if c.fsDir != "" {
+		fsFile := filepath.Join(c.fsDir, name)
+		err := os.MkdirAll(filepath.Dir(fsFile), 0755)
+		if err != nil {
+			return err
+		}
+		err = os.WriteFile(fsFile, content, 0644)
+		if err != nil {
+			return err
+		}
+	} else {
+		f.buf.Reset()
+		f.buf.Write(content)
+	}
+	f.baseContent = false
+	return nil
+}
+
 func (c *MemFS) FsDir() string {
 	return c.fsDir
 }
 
 // Stat implements FS.
 func (c *MemFS) Stat(name string) (fs.FileInfo, error) {
-	entry, err := navDir(name, c.root, false)
+	entry, err := navDir(name, c.root, false, c.load)
 	if err != nil {
 		return nil, err
 	}
@@ -57,7 +157,7 @@ func (c *MemFS) Stat(name string) (fs.FileInfo, error) {
 }
 
 func (c *MemFS) MkdirAll(name string, perm fs.FileMode) error {
-	entry, err := navDir(name, c.root, true)
+	entry, err := navDir(name, c.root, true, c.load)
 	if err != nil {
 		return err
 	}
@@ -73,7 +173,7 @@ func (c *MemFS) OpenFileAppend(name string) (io.WriteCloser, error) {
 	return c.openFileWrite(name, false)
 }
 func (c *MemFS) openFileWrite(name string, reset bool) (io.WriteCloser, error) {
-	entry, baseName, err := navParent(name, c.root)
+	entry, baseName, err := navParent(name, c.root, c.load)
 	if err != nil {
 		return nil, err
 	}
@@ -84,7 +184,7 @@ func (c *MemFS) openFileWrite(name string, reset bool) (io.WriteCloser, error) {
 			name:      baseName,
 			parent:    entry,
 			entryType: entryType_file,
-			perm:      0755,
+			perm:      0644,
 		}
 		entry.children = append(entry.children, f)
 		entry.childrenMap[baseName] = f
@@ -92,7 +192,16 @@ func (c *MemFS) openFileWrite(name string, reset bool) (io.WriteCloser, error) {
 	if reset && c.fsDir == "" {
 		f.buf.Reset()
 	}
+	if reset {
+		f.baseContent = false
+	}
 	entry.mutex.Unlock()
+	if !reset {
+		err := c.copyUp(f, name)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	if c.fsDir != "" {
 		fsFile := filepath.Join(c.fsDir, name)
@@ -106,7 +215,7 @@ func (c *MemFS) openFileWrite(name string, reset bool) (io.WriteCloser, error) {
 		} else {
 			flags = flags | os.O_APPEND
 		}
-		f, err := os.OpenFile(fsFile, flags, 0755)
+		f, err := os.OpenFile(fsFile, flags, 0644)
 		if err != nil {
 			return nil, err
 		}
@@ -115,12 +224,49 @@ func (c *MemFS) openFileWrite(name string, reset bool) (io.WriteCloser, error) {
 	return &f.buf, nil
 }
 
+// Chmod implements writefs.FSWithMode.
+func (c *MemFS) Chmod(name string, mode os.FileMode) error {
+	entry, err := navDir(name, c.root, false, c.load)
+	if err != nil {
+		return err
+	}
+	entry.perm = mode
+	if c.fsDir != "" && !entry.IsDir() {
+		err := c.copyUp(entry, name)
+		if err != nil {
+			return err
+		}
+		return os.Chmod(filepath.Join(c.fsDir, name), mode)
+	}
+	return nil
+}
+
+// Chtimes implements writefs.FSWithTime.
+func (c *MemFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	entry, err := navDir(name, c.root, false, c.load)
+	if err != nil {
+		return err
+	}
+	entry.modTime = mtime
+	if c.fsDir != "" && !entry.IsDir() {
+		err := c.copyUp(entry, name)
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(filepath.Join(c.fsDir, name), atime, mtime)
+	}
+	return nil
+}
+
 // OpenFileRead implements writefs.FS.
 func (c *MemFS) OpenFileRead(name string) (io.ReadCloser, error) {
-	entry, err := navDir(name, c.root, false)
+	entry, err := navDir(name, c.root, false, c.load)
 	if err != nil {
 		return nil, err
 	}
+	if entry.baseContent {
+		return c.base.OpenFileRead(name)
+	}
 	if c.fsDir != "" {
 		fsFile := filepath.Join(c.fsDir, name)
 		f, err := os.Open(fsFile)
@@ -173,7 +319,7 @@ func (c *MemFS) Traverse(initCtx interface{}, fn func(ctx interface{}, e MemFile
 
 // ReadDir implements FS.
 func (c *MemFS) ReadDir(name string) ([]fs.FileInfo, error) {
-	entry, err := navDir(name, c.root, false)
+	entry, err := navDir(name, c.root, false, c.load)
 	if err != nil {
 		return nil, err
 	}
@@ -203,7 +349,7 @@ const (
 )
 
 func (c *MemFS) remove(name string, removeMode removeMode) error {
-	entry, baseName, err := navParent(name, c.root)
+	entry, baseName, err := navParent(name, c.root, c.load)
 	if err != nil {
 		if writefs.IsNotExist(err) && removeMode == removeMode_all {
 			return nil
@@ -214,11 +360,20 @@ func (c *MemFS) remove(name string, removeMode removeMode) error {
 	defer entry.mutex.Unlock()
 	f := entry.childrenMap[baseName]
 	if f != nil {
-		if removeMode == removeMode_file {
-			if f.entryType == entryType_dir && len(f.children) > 0 {
+		if removeMode == removeMode_file && f.entryType == entryType_dir {
+			err := c.load(f, filepath.Clean(name))
+			if err != nil {
+				return err
+			}
+			if len(f.children) > 0 {
 				return fmt.Errorf("rm non-empty dir: %s", name)
 			}
 		}
+		if f.fromBase {
+			c.mutex.Lock()
+			c.removed[filepath.Clean(name)] = true
+			c.mutex.Unlock()
+		}
 		delete(entry.childrenMap, baseName)
 		n := len(entry.children)
 		for i := 0; i < n; i++ {
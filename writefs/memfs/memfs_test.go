@@ -7,7 +7,7 @@ import (
 
 // go test -run TestCreateAndList -v ./writefs/memfs
 func TestCreateAndList(t *testing.T) {
-	fs := NewMemFS()
+	fs := New()
 
 	err := fs.MkdirAll("a/b/c", 0755)
 	if err != nil {
@@ -0,0 +1,120 @@
+package memfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// go test -run TestReadThroughDoesNotTouchBase -v ./writefs/memfs
+func TestReadThroughDoesNotTouchBase(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "a"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "a", "x.txt"), []byte("x"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "a", "y.txt"), []byte("y"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewReadThrough(writefs.SysFS{}, "")
+	// write into an existing base dir
+	err = writefs.WriteFile(fs, filepath.Join(dir, "a", "z.txt"), []byte("z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// append copies up
+	w, err := fs.OpenFileAppend(filepath.Join(dir, "a", "x.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = w.Write([]byte("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	err = fs.RemoveFile(filepath.Join(dir, "a", "y.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	infos, err := fs.ReadDir(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	if len(names) != 2 || names[0] != "x.txt" || names[1] != "z.txt" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `names`, []string{"x.txt", "z.txt"}, names)
+	}
+	content, err := writefs.ReadFile(fs, filepath.Join(dir, "a", "x.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "xx" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `content`, "xx", string(content))
+	}
+	_, err = fs.Stat(filepath.Join(dir, "a", "y.txt"))
+	if !writefs.IsNotExist(err) {
+		t.Fatalf("expect y.txt removed, actual err: %v", err)
+	}
+
+	// base untouched
+	baseContent, err := os.ReadFile(filepath.Join(dir, "a", "x.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(baseContent) != "x" {
+		t.Fatalf("expect %s = %+v, actual:%+v", `baseContent`, "x", string(baseContent))
+	}
+	_, err = os.Stat(filepath.Join(dir, "a", "y.txt"))
+	if err != nil {
+		t.Fatalf("expect base y.txt kept: %v", err)
+	}
+	_, err = os.Stat(filepath.Join(dir, "a", "z.txt"))
+	if !os.IsNotExist(err) {
+		t.Fatalf("expect base z.txt not created: %v", err)
+	}
+}
+
+// go test -run TestReadThroughRemoveAllThenRecreate -v ./writefs/memfs
+func TestReadThroughRemoveAllThenRecreate(t *testing.T) {
+	dir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(dir, "a"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = os.WriteFile(filepath.Join(dir, "a", "x.txt"), []byte("x"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs := NewReadThrough(writefs.SysFS{}, "")
+	err = fs.RemoveAll(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = fs.MkdirAll(filepath.Join(dir, "a"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := fs.ReadDir(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 0 {
+		t.Fatalf("expect %s = %+v, actual:%+v", `len(infos)`, 0, len(infos))
+	}
+	removed := fs.Removed()
+	if len(removed) != 1 || removed[0] != filepath.Join(dir, "a") {
+		t.Fatalf("expect %s = %+v, actual:%+v", `removed`, []string{filepath.Join(dir, "a")}, removed)
+	}
+}
@@ -9,7 +9,11 @@ import (
 
 var _ FS = NoopFS{}
 var _ FSWithTime = NoopFS{}
+var _ FSWithMode = NoopFS{}
 
+// NoopFS discards all writes. Like other FS that cannot hold
+// symlinks it does not implement FSWithSymlink, so symlinks
+// are counted as the content copied in their place.
 type NoopFS struct {
 }
 
@@ -64,3 +68,8 @@ func (NoopFS) ReadDir(name string) ([]fs.FileInfo, error) {
 func (NoopFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
 	return nil
 }
+
+// Chmod implements FSWithMode.
+func (NoopFS) Chmod(name string, mode os.FileMode) error {
+	return nil
+}
@@ -13,6 +13,8 @@ type SysFS struct {
 
 var _ FS = SysFS{}
 var _ FSWithTime = SysFS{}
+var _ FSWithMode = SysFS{}
+var _ FSWithSymlink = SysFS{}
 
 func (SysFS) Stat(name string) (fs.FileInfo, error) {
 	return os.Stat(name)
@@ -33,17 +35,33 @@ func (SysFS) OpenFileRead(name string) (io.ReadCloser, error) {
 }
 
 func (SysFS) OpenFileWrite(name string) (io.WriteCloser, error) {
-	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	return os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
 }
 
 func (SysFS) OpenFileAppend(name string) (io.WriteCloser, error) {
-	return os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0755)
+	return os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0644)
 }
 
 func (SysFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
 	return os.Chtimes(name, atime, mtime)
 }
 
+func (SysFS) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (SysFS) Symlink(oldname string, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (SysFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(name)
+}
+
+func (SysFS) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
 // ReadDir implements TreeFS.
 func (SysFS) ReadDir(name string) ([]fs.FileInfo, error) {
 	return ioutil.ReadDir(name)
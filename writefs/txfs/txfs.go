@@ -0,0 +1,365 @@
+package txfs
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+)
+
+// FS writes through to a base FS, and snapshots every path
+// before its first change, so that all changes can be rolled back.
+type FS struct {
+	base writefs.FS
+
+	mutex     sync.Mutex
+	snapshots map[string]*snapshot
+	order     []string // snapshot order, parent before children
+}
+
+type snapshot struct {
+	exists  bool
+	isDir   bool
+	mode    os.FileMode
+	modTime time.Time
+	content []byte
+	link    string // target if name is a symlink
+}
+
+var _ writefs.FSWithTime = (*FS)(nil)
+var _ writefs.FSWithMode = (*FS)(nil)
+var _ writefs.FSWithSymlink = (*FS)(nil)
+
+func New(base writefs.FS) *FS {
+	return &FS{
+		base:      base,
+		snapshots: make(map[string]*snapshot),
+	}
+}
+
+// Base returns the wrapped FS
+func (c *FS) Base() writefs.FS {
+	return c.base
+}
+
+// Changed returns paths that have been snapshotted, in change order
+func (c *FS) Changed() []string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	list := make([]string, len(c.order))
+	copy(list, c.order)
+	return list
+}
+
+// Commit drops all snapshots, changes are kept
+func (c *FS) Commit() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.snapshots = make(map[string]*snapshot)
+	c.order = nil
+}
+
+// Rollback restores every changed path to its snapshot,
+// paths created are removed, paths modified or removed are restored.
+func (c *FS) Rollback() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// remove created paths, children first
+	for i := len(c.order) - 1; i >= 0; i-- {
+		name := c.order[i]
+		if c.snapshots[name].exists {
+			continue
+		}
+		err := c.base.RemoveAll(name)
+		if err != nil {
+			return fmt.Errorf("rollback %s: %w", name, err)
+		}
+	}
+	// restore existing paths, parent first
+	for _, name := range c.order {
+		snap := c.snapshots[name]
+		if !snap.exists {
+			continue
+		}
+		err := c.restore(name, snap)
+		if err != nil {
+			return fmt.Errorf("rollback %s: %w", name, err)
+		}
+	}
+	// times last, children first, restoring changes times of dirs
+	if tfs, ok := c.base.(writefs.FSWithTime); ok {
+		for i := len(c.order) - 1; i >= 0; i-- {
+			name := c.order[i]
+			snap := c.snapshots[name]
+			if !snap.exists || snap.link != "" || snap.modTime.IsZero() {
+				continue
+			}
+			err := tfs.Chtimes(name, snap.modTime, snap.modTime)
+			if err != nil {
+				return fmt.Errorf("rollback %s: %w", name, err)
+			}
+		}
+	}
+	c.snapshots = make(map[string]*snapshot)
+	c.order = nil
+	return nil
+}
+
+func (c *FS) restore(name string, snap *snapshot) error {
+	if snap.isDir {
+		return c.base.MkdirAll(name, 0755)
+	}
+	err := c.base.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	// never write through a symlink or into a dir created in place of name
+	stat := c.base.Stat
+	sfs, hasLinks := c.base.(writefs.FSWithSymlink)
+	if hasLinks {
+		stat = sfs.Lstat
+	}
+	info, err := stat(name)
+	if err != nil && !writefs.IsNotExist(err) {
+		return err
+	}
+	if err == nil && (info.IsDir() || info.Mode()&os.ModeSymlink != 0 || snap.link != "") {
+		err := c.base.RemoveAll(name)
+		if err != nil {
+			return err
+		}
+	}
+	if snap.link != "" {
+		return sfs.Symlink(snap.link, name)
+	}
+	err = writefs.WriteFile(c.base, name, snap.content)
+	if err != nil {
+		return err
+	}
+	if mfs, ok := c.base.(writefs.FSWithMode); ok {
+		return mfs.Chmod(name, snap.mode)
+	}
+	return nil
+}
+
+// save snapshots name, and all its children if it is a dir.
+// must be called before name is changed.
+func (c *FS) save(name string, recursive bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.saveLocked(name, recursive)
+}
+
+func (c *FS) saveLocked(name string, recursive bool) error {
+	if snap, ok := c.snapshots[name]; ok {
+		// paths under a created dir are removed with it
+		if !recursive || !snap.exists || !snap.isDir {
+			return nil
+		}
+		return c.saveChildrenLocked(name)
+	}
+	// a symlink is saved as itself, not what it points to
+	stat := c.base.Stat
+	sfs, hasLinks := c.base.(writefs.FSWithSymlink)
+	if hasLinks {
+		stat = sfs.Lstat
+	}
+	info, err := stat(name)
+	if err != nil {
+		if !writefs.IsNotExist(err) {
+			return err
+		}
+		c.add(name, &snapshot{})
+		return nil
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		link, err := sfs.Readlink(name)
+		if err != nil {
+			return err
+		}
+		c.add(name, &snapshot{exists: true, link: link})
+		return nil
+	}
+	if !info.IsDir() {
+		content, err := writefs.ReadFile(c.base, name)
+		if err != nil {
+			return err
+		}
+		c.add(name, &snapshot{exists: true, mode: info.Mode().Perm(), modTime: info.ModTime(), content: content})
+		return nil
+	}
+	c.add(name, &snapshot{exists: true, isDir: true, modTime: info.ModTime()})
+	if !recursive {
+		return nil
+	}
+	return c.saveChildrenLocked(name)
+}
+
+func (c *FS) saveChildrenLocked(dir string) error {
+	entries, err := c.base.ReadDir(dir)
+	if err != nil {
+		if writefs.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, entry := range entries {
+		err := c.saveLocked(filepath.Join(dir, entry.Name()), true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *FS) add(name string, snap *snapshot) {
+	if _, ok := c.snapshots[name]; ok {
+		return
+	}
+	c.snapshots[name] = snap
+	c.order = append(c.order, name)
+}
+
+// saveMissingDirs snapshots dir and its parents that do not exist yet
+func (c *FS) saveMissingDirs(dir string) error {
+	var missing []string
+	for p := dir; ; {
+		_, err := c.base.Stat(p)
+		if err == nil {
+			break
+		}
+		if !writefs.IsNotExist(err) {
+			return err
+		}
+		missing = append(missing, p)
+		parent := filepath.Dir(p)
+		if parent == p {
+			break
+		}
+		p = parent
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := len(missing) - 1; i >= 0; i-- {
+		c.add(missing[i], &snapshot{})
+	}
+	return nil
+}
+
+func (c *FS) Stat(name string) (fs.FileInfo, error) {
+	return c.base.Stat(name)
+}
+
+func (c *FS) RemoveFile(name string) error {
+	name = filepath.Clean(name)
+	err := c.save(name, false)
+	if err != nil {
+		return err
+	}
+	return c.base.RemoveFile(name)
+}
+
+func (c *FS) MkdirAll(name string, perm os.FileMode) error {
+	name = filepath.Clean(name)
+	err := c.saveMissingDirs(name)
+	if err != nil {
+		return err
+	}
+	return c.base.MkdirAll(name, perm)
+}
+
+func (c *FS) OpenFileRead(name string) (io.ReadCloser, error) {
+	return c.base.OpenFileRead(name)
+}
+
+func (c *FS) OpenFileWrite(name string) (io.WriteCloser, error) {
+	name = filepath.Clean(name)
+	err := c.save(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return c.base.OpenFileWrite(name)
+}
+
+func (c *FS) OpenFileAppend(name string) (io.WriteCloser, error) {
+	name = filepath.Clean(name)
+	err := c.save(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return c.base.OpenFileAppend(name)
+}
+
+func (c *FS) RemoveAll(name string) error {
+	name = filepath.Clean(name)
+	err := c.save(name, true)
+	if err != nil {
+		return err
+	}
+	return c.base.RemoveAll(name)
+}
+
+func (c *FS) ReadDir(name string) ([]fs.FileInfo, error) {
+	return c.base.ReadDir(name)
+}
+
+func (c *FS) Chmod(name string, mode os.FileMode) error {
+	mfs, ok := c.base.(writefs.FSWithMode)
+	if !ok {
+		return nil
+	}
+	name = filepath.Clean(name)
+	err := c.save(name, false)
+	if err != nil {
+		return err
+	}
+	return mfs.Chmod(name, mode)
+}
+
+// Symlink returns writefs.ErrSymlinkNotSupported if base cannot hold symlinks
+func (c *FS) Symlink(oldname string, newname string) error {
+	if _, ok := c.base.(writefs.FSWithSymlink); !ok {
+		return writefs.ErrSymlinkNotSupported
+	}
+	newname = filepath.Clean(newname)
+	err := c.save(newname, false)
+	if err != nil {
+		return err
+	}
+	return writefs.Symlink(c.base, oldname, newname)
+}
+
+func (c *FS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	tfs, ok := c.base.(writefs.FSWithTime)
+	if !ok {
+		return nil
+	}
+	name = filepath.Clean(name)
+	err := c.save(name, false)
+	if err != nil {
+		return err
+	}
+	return tfs.Chtimes(name, atime, mtime)
+}
+
+func (c *FS) Lstat(name string) (fs.FileInfo, error) {
+	sfs, ok := c.base.(writefs.FSWithSymlink)
+	if !ok {
+		return c.base.Stat(name)
+	}
+	return sfs.Lstat(name)
+}
+
+func (c *FS) Readlink(name string) (string, error) {
+	sfs, ok := c.base.(writefs.FSWithSymlink)
+	if !ok {
+		return "", writefs.ErrSymlinkNotSupported
+	}
+	return sfs.Readlink(name)
+}
@@ -0,0 +1,166 @@
+package txfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xhd2015/go-vendor-pack/writefs"
+	"github.com/xhd2015/go-vendor-pack/writefs/memfs"
+)
+
+// go test -run TestRollback -v ./writefs/txfs
+func TestRollback(t *testing.T) {
+	base := memfs.New()
+	mustWrite(t, base, "/a/go.mod", "module a\n")
+	mustWrite(t, base, "/a/vendor/x/x.go", "package x\n")
+	mustWrite(t, base, "/a/vendor/x/sub/sub.go", "package sub\n")
+
+	tfs := New(base)
+	mustWrite(t, tfs, "/a/go.mod", "module a\n\nrequire x v1\n")
+	err := tfs.RemoveAll("/a/vendor/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tfs.MkdirAll("/a/vendor/x/new", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, tfs, "/a/vendor/x/new/new.go", "package new\n")
+	mustWrite(t, tfs, "/a/vendor/x/x.go", "package x // v2\n")
+	err = tfs.MkdirAll("/b/c", 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tfs.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectContent(t, base, "/a/go.mod", "module a\n")
+	expectContent(t, base, "/a/vendor/x/x.go", "package x\n")
+	expectContent(t, base, "/a/vendor/x/sub/sub.go", "package sub\n")
+	for _, removed := range []string{"/a/vendor/x/new", "/b"} {
+		_, err := base.Stat(removed)
+		if !writefs.IsNotExist(err) {
+			t.Fatalf("expect %s removed, actual:%v", removed, err)
+		}
+	}
+}
+
+// go test -run TestCommit -v ./writefs/txfs
+func TestCommit(t *testing.T) {
+	base := memfs.New()
+	mustWrite(t, base, "/a/go.mod", "module a\n")
+
+	tfs := New(base)
+	mustWrite(t, tfs, "/a/go.mod", "module b\n")
+	tfs.Commit()
+	err := tfs.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectContent(t, base, "/a/go.mod", "module b\n")
+}
+
+func mustWrite(t *testing.T, fs writefs.FS, name string, content string) {
+	err := fs.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writefs.WriteFile(fs, name, []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectContent(t *testing.T, fs writefs.FS, name string, expect string) {
+	content, err := writefs.ReadFile(fs, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != expect {
+		t.Fatalf("expect %s = %q, actual:%q", name, expect, content)
+	}
+}
+
+// go test -run TestRollbackSymlink -v ./writefs/txfs
+func TestRollbackSymlink(t *testing.T) {
+	dir := t.TempDir()
+	base := writefs.SysFS{}
+	mustWrite(t, base, filepath.Join(dir, "a.go"), "package a\n")
+	err := base.MkdirAll(filepath.Join(dir, "assets"), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, base, filepath.Join(dir, "assets", "x.txt"), "x\n")
+	for name, target := range map[string]string{"file_link": "a.go", "dir_link": "assets", "replaced": "a.go"} {
+		err := os.Symlink(target, filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	err = os.Chtimes(filepath.Join(dir, "a.go"), mtime, mtime)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tfs := New(base)
+	for _, name := range []string{"file_link", "dir_link"} {
+		err := tfs.RemoveAll(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = tfs.RemoveFile(filepath.Join(dir, "replaced"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mustWrite(t, tfs, filepath.Join(dir, "replaced"), "replaced\n")
+	// a file replaced by a link is never written through
+	err = tfs.RemoveFile(filepath.Join(dir, "assets", "x.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = tfs.Symlink("../a.go", filepath.Join(dir, "assets", "x.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	err = tfs.Chtimes(filepath.Join(dir, "a.go"), now, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tfs.Rollback()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expect := range map[string]string{"file_link": "a.go", "dir_link": "assets", "replaced": "a.go"} {
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if target != expect {
+			t.Fatalf("expect %s = %+v, actual:%+v", name, expect, target)
+		}
+	}
+	expectContent(t, base, filepath.Join(dir, "a.go"), "package a\n")
+	expectContent(t, base, filepath.Join(dir, "assets", "x.txt"), "x\n")
+	info, err := os.Lstat(filepath.Join(dir, "assets", "x.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Mode().IsRegular() {
+		t.Fatalf("expect %s = %+v, actual:%+v", "x.txt mode", "regular", info.Mode())
+	}
+	info, err = os.Stat(filepath.Join(dir, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(mtime) {
+		t.Fatalf("expect %s = %+v, actual:%+v", "a.go mtime", mtime, info.ModTime())
+	}
+}
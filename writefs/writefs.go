@@ -29,6 +29,31 @@ type FSWithTime interface {
 	Chtimes(name string, atime time.Time, mtime time.Time) error
 }
 
+type FSWithMode interface {
+	FS
+	Chmod(name string, mode os.FileMode) error
+}
+
+type FSWithSymlink interface {
+	FS
+	Symlink(oldname string, newname string) error
+	// Lstat and Readlink do not follow the last symlink
+	Lstat(name string) (fs.FileInfo, error)
+	Readlink(name string) (string, error)
+}
+
+var ErrSymlinkNotSupported = errors.New("symlink not supported")
+
+// Symlink creates newname as a symlink to oldname,
+// returns ErrSymlinkNotSupported if fs cannot hold symlinks
+func Symlink(fs FS, oldname string, newname string) error {
+	sfs, ok := fs.(FSWithSymlink)
+	if !ok {
+		return ErrSymlinkNotSupported
+	}
+	return sfs.Symlink(oldname, newname)
+}
+
 func IsNotExist(err error) bool {
 	// return os.IsNotExist(err) : not work for wrapping
 	return errors.Is(err, os.ErrNotExist)